@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/thinkingojha/go-htmx/internal/config"
+)
+
+// Run executes the command named by args[0] and returns the process exit code
+func Run(cfg *config.Config, args []string) int {
+	switch args[0] {
+	case "preview":
+		return runPreview(cfg, args[1:])
+	case "content":
+		return runContent(cfg, args[1:])
+	case "help", "-h", "--help":
+		usage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage(os.Stderr)
+		return 2
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage: gohtmx [command]
+
+Without a command the web server is started.
+
+Commands:
+  preview [-ttl 72h] [-base-url URL] <slug>   print a signed preview link for a draft post
+  content check                               validate posts, blogs.yaml and the experience file
+  help                                        show this message
+`)
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/thinkingojha/go-htmx/internal/config"
+	"github.com/thinkingojha/go-htmx/internal/handlers"
+)
+
+// runContent runs the content subcommands
+func runContent(cfg *config.Config, args []string) int {
+	if len(args) != 1 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: gohtmx content check")
+		return 2
+	}
+
+	issues := handlers.CheckContent(cfg.App.BlogDir, cfg.App.ExperienceFile, cfg.App.StaticDir)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(issues))
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "content ok")
+	return 0
+}
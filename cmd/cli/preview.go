@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/thinkingojha/go-htmx/internal/config"
+	"github.com/thinkingojha/go-htmx/internal/handlers"
+)
+
+// runPreview mints a signed, expiring link to a draft or scheduled post
+func runPreview(cfg *config.Config, args []string) int {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+	ttl := fs.Duration("ttl", 72*time.Hour, "how long the link stays valid")
+	baseURL := fs.String("base-url", "https://ankush.fyi", "site URL the link points at")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gohtmx preview [-ttl 72h] [-base-url URL] <slug>")
+		return 2
+	}
+	slug := fs.Arg(0)
+
+	store, err := handlers.NewContentStore(cfg.App.BlogDir, cfg.App.ExperienceFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load content: %v\n", err)
+		return 1
+	}
+	blogData, err := store.Blog()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load content: %v\n", err)
+		return 1
+	}
+
+	expires := time.Now().Add(*ttl)
+	path, err := handlers.PreviewPath(blogData, slug, expires)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create preview link: %v\n", err)
+		return 1
+	}
+
+	fmt.Println(strings.TrimSuffix(*baseURL, "/") + path)
+	fmt.Fprintf(os.Stderr, "valid until %s\n", expires.Format(time.RFC1123))
+	return 0
+}
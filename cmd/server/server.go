@@ -43,8 +43,20 @@ func (s *Server) setupRoutes() {
 	api.Use(middleware.RequestLogger)
 	api.Use(middleware.RateLimiter(s.config.Security.RateLimitRPM))
 	api.Use(middleware.CORS(s.config))
+	api.Use(middleware.PageViews(handlers.RecordPageView))
 	api.Use(middleware.Timeout(30 * time.Second))
 
+	// Moved and removed pages, ahead of every other route so they win over
+	// the pages and 404s that would otherwise answer
+	redirects := middleware.Redirects(handlers.FindRedirect, http.HandlerFunc(s.goneHandler))
+	api.MatcherFunc(func(r *http.Request, _ *mux.RouteMatch) bool {
+		_, _, ok := handlers.FindRedirect(r.URL.Path)
+		return ok
+	}).Methods("GET", "HEAD").Handler(redirects(http.HandlerFunc(s.notFoundHandler)))
+
+	// Generated stylesheet for highlighted code, ahead of the static files
+	api.HandleFunc("/static/css/highlight.css", s.makeHTTPHandlerFunc(handlers.HighlightCSSHandler)).Methods("GET")
+
 	staticHandler := http.StripPrefix("/static/",
 		http.FileServer(http.Dir(s.config.App.StaticDir)))
 	api.PathPrefix("/static/").Handler(staticHandler)
@@ -53,9 +65,7 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, filepath.Join(s.config.App.StaticDir, "robots.txt"))
 	}).Methods("GET")
-	api.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join(s.config.App.StaticDir, "sitemap.xml"))
-	}).Methods("GET")
+	api.HandleFunc("/sitemap.xml", s.makeHTTPHandlerFunc(handlers.SitemapHandler)).Methods("GET")
 	api.HandleFunc("/llms.txt", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain")
 		http.ServeFile(w, r, filepath.Join(s.config.App.StaticDir, "llms.txt"))
@@ -68,9 +78,8 @@ func (s *Server) setupRoutes() {
 	// Application routes
 	api.HandleFunc("/", s.makeHTTPHandlerFunc(handlers.HomeHandler)).Methods("GET")
 
-	// About page (new: /about, legacy: /info)
+	// About page (the legacy /info redirects here)
 	api.HandleFunc("/about", s.makeHTTPHandlerFunc(handlers.ExpHandler)).Methods("GET")
-	api.Handle("/info", http.RedirectHandler("/about", http.StatusMovedPermanently)).Methods("GET")
 
 	// Products
 	api.HandleFunc("/products", s.makeHTTPHandlerFunc(handlers.ProductHandler)).Methods("GET")
@@ -78,20 +87,63 @@ func (s *Server) setupRoutes() {
 	// Contact page (new)
 	api.HandleFunc("/contact", s.makeHTTPHandlerFunc(handlers.ContactHandler)).Methods("GET")
 
-	// Writings/Blog routes (new: /writings, legacy: /blog)
-	api.HandleFunc("/writings", s.makeHTTPHandlerFunc(handlers.WritingsHandler)).Methods("GET")
-	api.HandleFunc("/writings/{slug}", s.makeHTTPHandlerFunc(handlers.BlogPostHandler)).Methods("GET")
-	api.Handle("/blog", http.RedirectHandler("/writings", http.StatusMovedPermanently)).Methods("GET")
+	// Writings/Blog routes (the legacy /blog redirects to /writings)
+	s.writingsRoutes(api)
+	api.HandleFunc("/writings/{slug}/og.png", s.makeHTTPHandlerFunc(handlers.BlogOGImageHandler)).Methods("GET")
+	api.HandleFunc("/writings/{slug}/comments", s.makeHTTPHandlerFunc(handlers.BlogCommentsHandler)).Methods("GET")
+	api.HandleFunc("/writings/{slug}/comments", s.makeHTTPHandlerFunc(handlers.BlogCommentSubmitHandler)).Methods("POST")
 	api.HandleFunc("/blog/filter", s.makeHTTPHandlerFunc(handlers.BlogFilterHandler)).Methods("GET")
 	api.HandleFunc("/blog/rss", s.makeHTTPHandlerFunc(handlers.BlogRSSHandler)).Methods("GET")
 	api.HandleFunc("/blog/{slug}", s.makeHTTPHandlerFunc(handlers.BlogPostHandler)).Methods("GET")
 
 	api.HandleFunc("/write", s.makeHTTPHandlerFunc(handlers.MarkdownHandler)).Methods("GET", "POST")
 
+	// Newsletter sign up, with double opt-in by email
+	api.HandleFunc("/newsletter/subscribe", s.makeHTTPHandlerFunc(handlers.NewsletterSubscribeHandler)).Methods("POST")
+	api.HandleFunc("/newsletter/confirm", s.makeHTTPHandlerFunc(handlers.NewsletterConfirmHandler)).Methods("GET", "POST")
+	api.HandleFunc("/newsletter/unsubscribe", s.makeHTTPHandlerFunc(handlers.NewsletterUnsubscribeHandler)).Methods("GET", "POST")
+
+	// Comment moderation, analytics and bounce reports, behind basic auth with security.admin_token
+	api.HandleFunc("/admin/comments", s.makeHTTPHandlerFunc(handlers.AdminCommentsHandler)).Methods("GET")
+	api.HandleFunc("/admin/comments/{id}/{action:approve|reject}", s.makeHTTPHandlerFunc(handlers.AdminCommentActionHandler)).Methods("POST")
+	api.HandleFunc("/admin/analytics", s.makeHTTPHandlerFunc(handlers.AdminAnalyticsHandler)).Methods("GET")
+	api.HandleFunc("/newsletter/bounce", s.makeHTTPHandlerFunc(handlers.NewsletterBounceHandler)).Methods("POST")
+
+	// The writings again under a language prefix, such as /hi/writings, for
+	// each language of the blog besides the default
+	lang := api.PathPrefix("/{lang:[a-z]{2,3}}").Subrouter()
+	lang.Use(middleware.Languages(handlers.IsLanguagePrefix, http.HandlerFunc(s.notFoundHandler)))
+	s.writingsRoutes(lang)
+
 	// Add 404 handler
 	api.NotFoundHandler = http.HandlerFunc(s.notFoundHandler)
 }
 
+// writingsRoutes registers the post listings, feeds and posts on r
+func (s *Server) writingsRoutes(r *mux.Router) {
+	r.HandleFunc("/writings", s.makeHTTPHandlerFunc(handlers.WritingsHandler)).Methods("GET")
+	r.HandleFunc("/writings/search", s.makeHTTPHandlerFunc(handlers.BlogSearchHandler)).Methods("GET")
+
+	// Feeds: RSS, Atom and JSON Feed for the whole blog and per tag or category
+	for _, prefix := range []string{"/writings", "/writings/tag/{tag}", "/writings/category/{category}"} {
+		r.HandleFunc(prefix+"/feed.xml", s.makeHTTPHandlerFunc(handlers.BlogRSSHandler)).Methods("GET")
+		r.HandleFunc(prefix+"/atom.xml", s.makeHTTPHandlerFunc(handlers.BlogAtomHandler)).Methods("GET")
+		r.HandleFunc(prefix+"/feed.json", s.makeHTTPHandlerFunc(handlers.BlogJSONFeedHandler)).Methods("GET")
+	}
+
+	r.HandleFunc("/writings/series/{slug}", s.makeHTTPHandlerFunc(handlers.BlogSeriesHandler)).Methods("GET")
+	r.HandleFunc("/writings/tags", s.makeHTTPHandlerFunc(handlers.BlogTagsHandler)).Methods("GET")
+	r.HandleFunc("/writings/tag/{tag}", s.makeHTTPHandlerFunc(handlers.BlogTagHandler)).Methods("GET")
+	r.HandleFunc("/writings/category/{category}", s.makeHTTPHandlerFunc(handlers.BlogCategoryHandler)).Methods("GET")
+	r.HandleFunc("/writings/author/{author}", s.makeHTTPHandlerFunc(handlers.BlogAuthorHandler)).Methods("GET")
+
+	// Date archives; registered before /writings/{slug} so years aren't read as slugs
+	r.HandleFunc("/writings/archive", s.makeHTTPHandlerFunc(handlers.BlogArchiveHandler)).Methods("GET")
+	r.HandleFunc("/writings/{year:[0-9]{4}}", s.makeHTTPHandlerFunc(handlers.BlogArchiveHandler)).Methods("GET")
+	r.HandleFunc("/writings/{year:[0-9]{4}}/{month:[0-9]{2}}", s.makeHTTPHandlerFunc(handlers.BlogArchiveHandler)).Methods("GET")
+	r.HandleFunc("/writings/{slug}", s.makeHTTPHandlerFunc(handlers.BlogPostHandler)).Methods("GET")
+}
+
 func (s *Server) makeHTTPHandlerFunc(handlerFunc HTTPHandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := handlerFunc(w, r); err != nil {
@@ -138,6 +190,20 @@ func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
 </html>`)
 }
 
+func (s *Server) goneHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusGone)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head><title>Page Removed</title></head>
+<body>
+	<h1>Page Removed</h1>
+	<p>The page you're looking for has been removed.</p>
+	<a href="/">Go Home</a>
+</body>
+</html>`)
+}
+
 func (s *Server) Run() error {
 	s.setupRoutes()
 
@@ -3,14 +3,19 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	App      AppConfig      `mapstructure:"app"`
-	Security SecurityConfig `mapstructure:"security"`
+	Server     ServerConfig     `mapstructure:"server"`
+	App        AppConfig        `mapstructure:"app"`
+	Security   SecurityConfig   `mapstructure:"security"`
+	Markdown   MarkdownConfig   `mapstructure:"markdown"`
+	Comments   CommentsConfig   `mapstructure:"comments"`
+	Analytics  AnalyticsConfig  `mapstructure:"analytics"`
+	Newsletter NewsletterConfig `mapstructure:"newsletter"`
 }
 
 type ServerConfig struct {
@@ -23,17 +28,69 @@ type ServerConfig struct {
 }
 
 type AppConfig struct {
-	Name        string `mapstructure:"name"`
-	Version     string `mapstructure:"version"`
-	Environment string `mapstructure:"environment"`
-	LogLevel    string `mapstructure:"log_level"`
-	StaticDir   string `mapstructure:"static_dir"`
-	TemplateDir string `mapstructure:"template_dir"`
+	Name           string `mapstructure:"name"`
+	Version        string `mapstructure:"version"`
+	Environment    string `mapstructure:"environment"`
+	LogLevel       string `mapstructure:"log_level"`
+	StaticDir      string `mapstructure:"static_dir"`
+	TemplateDir    string `mapstructure:"template_dir"`
+	BlogDir        string `mapstructure:"blog_dir"`
+	ExperienceFile string `mapstructure:"experience_file"`
+	WatchContent   bool   `mapstructure:"watch_content"`
+	Timezone       string `mapstructure:"timezone"`
+	OGCacheDir     string `mapstructure:"og_cache_dir"`
 }
 
 type SecurityConfig struct {
 	TrustedProxies []string `mapstructure:"trusted_proxies"`
 	RateLimitRPM   int      `mapstructure:"rate_limit_rpm"`
+	PreviewSecret  string   `mapstructure:"preview_secret"`
+	AdminToken     string   `mapstructure:"admin_token"` // password for /admin; admin pages are off while empty
+}
+
+// MarkdownConfig toggles the optional extensions of the markdown pipeline
+type MarkdownConfig struct {
+	Footnotes       bool   `mapstructure:"footnotes"`
+	Sidenotes       bool   `mapstructure:"sidenotes"`
+	DefinitionLists bool   `mapstructure:"definition_lists"`
+	Math            bool   `mapstructure:"math"`
+	Smartypants     bool   `mapstructure:"smartypants"`
+	TaskLists       bool   `mapstructure:"task_lists"`
+	Highlight       bool   `mapstructure:"highlight"`
+	LineNumbers     bool   `mapstructure:"line_numbers"`
+	HighlightStyle  string `mapstructure:"highlight_style"`
+}
+
+// CommentsConfig controls reader comments on posts
+type CommentsConfig struct {
+	Enabled   bool   `mapstructure:"enabled"`
+	Dir       string `mapstructure:"dir"`        // where the comment log is kept
+	Moderate  bool   `mapstructure:"moderate"`   // hold new comments until approved
+	MaxLength int    `mapstructure:"max_length"` // longest comment accepted, in characters
+}
+
+// AnalyticsConfig controls the built-in page view counter
+type AnalyticsConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	Dir           string `mapstructure:"dir"`            // where daily counts are kept
+	FlushInterval int    `mapstructure:"flush_interval"` // seconds between writes to disk
+}
+
+// NewsletterConfig controls email subscriptions to new posts
+type NewsletterConfig struct {
+	Enabled       bool       `mapstructure:"enabled"`
+	Dir           string     `mapstructure:"dir"`            // where the subscriber log is kept
+	From          string     `mapstructure:"from"`           // sender, such as "Ankush <hello@ankush.fyi>"
+	CheckInterval int        `mapstructure:"check_interval"` // seconds between looks for new posts
+	SMTP          SMTPConfig `mapstructure:"smtp"`
+}
+
+// SMTPConfig is the mail server the newsletter sends through
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
 }
 
 func Load() (*Config, error) {
@@ -67,6 +124,17 @@ func Load() (*Config, error) {
 	return &config, nil
 }
 
+// Validate rejects settings the server cannot start with
+func (c *Config) Validate() error {
+	if c.Analytics.Enabled && c.Analytics.FlushInterval <= 0 {
+		return fmt.Errorf("invalid analytics.flush_interval %d: must be at least 1 second", c.Analytics.FlushInterval)
+	}
+	if c.Newsletter.Enabled && c.Newsletter.CheckInterval <= 0 {
+		return fmt.Errorf("invalid newsletter.check_interval %d: must be at least 1 second", c.Newsletter.CheckInterval)
+	}
+	return nil
+}
+
 func setDefaults() {
 	// Server defaults
 	viper.SetDefault("server.port", "3000")
@@ -83,10 +151,58 @@ func setDefaults() {
 	viper.SetDefault("app.log_level", "info")
 	viper.SetDefault("app.static_dir", "internal/static")
 	viper.SetDefault("app.template_dir", "internal/template")
+	viper.SetDefault("app.blog_dir", "blogs")
+	viper.SetDefault("app.experience_file", "experience.yaml")
+	viper.SetDefault("app.watch_content", true)
+	viper.SetDefault("app.timezone", "UTC")
+	viper.SetDefault("app.og_cache_dir", "cache/og")
 
 	// Security defaults
 	viper.SetDefault("security.trusted_proxies", []string{})
 	viper.SetDefault("security.rate_limit_rpm", 60)
+	viper.SetDefault("security.preview_secret", "")
+	viper.SetDefault("security.admin_token", "")
+
+	// Markdown defaults
+	viper.SetDefault("markdown.footnotes", true)
+	viper.SetDefault("markdown.sidenotes", true)
+	viper.SetDefault("markdown.definition_lists", true)
+	viper.SetDefault("markdown.math", true)
+	viper.SetDefault("markdown.smartypants", true)
+	viper.SetDefault("markdown.task_lists", true)
+	viper.SetDefault("markdown.highlight", true)
+	viper.SetDefault("markdown.line_numbers", false)
+	viper.SetDefault("markdown.highlight_style", "github-dark")
+
+	// Comments defaults
+	viper.SetDefault("comments.enabled", false)
+	viper.SetDefault("comments.dir", "data/comments")
+	viper.SetDefault("comments.moderate", true)
+	viper.SetDefault("comments.max_length", 5000)
+
+	// Analytics defaults
+	viper.SetDefault("analytics.enabled", false)
+	viper.SetDefault("analytics.dir", "data/analytics")
+	viper.SetDefault("analytics.flush_interval", 60)
+
+	// Newsletter defaults
+	viper.SetDefault("newsletter.enabled", false)
+	viper.SetDefault("newsletter.dir", "data/newsletter")
+	viper.SetDefault("newsletter.from", "")
+	viper.SetDefault("newsletter.check_interval", 60)
+	viper.SetDefault("newsletter.smtp.host", "localhost")
+	viper.SetDefault("newsletter.smtp.port", 587)
+	viper.SetDefault("newsletter.smtp.username", "")
+	viper.SetDefault("newsletter.smtp.password", "")
+}
+
+// Location returns the site timezone used for content dates
+func (c *Config) Location() (*time.Location, error) {
+	loc, err := time.LoadLocation(c.App.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid app.timezone %q: %w", c.App.Timezone, err)
+	}
+	return loc, nil
 }
 
 func (c *Config) IsProduction() bool {
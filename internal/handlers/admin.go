@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/logger"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// adminToken is the password of the admin pages; they answer 404 while it
+// is empty
+var adminToken []byte
+
+// SetAdminToken sets the password the admin pages ask for
+func SetAdminToken(token string) {
+	adminToken = []byte(token)
+}
+
+// requireAdmin checks the request's basic auth password against the admin
+// token, answering the request itself when it does not match
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if len(adminToken) == 0 {
+		http.NotFound(w, r)
+		return false
+	}
+	_, password, ok := r.BasicAuth()
+	if !ok || subtle.ConstantTimeCompare([]byte(password), adminToken) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="admin", charset="UTF-8"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	return true
+}
+
+// sameOriginHTMX reports whether a request was sent by htmx from this site.
+// Browsers only send the HX-Request header cross-site after a CORS preflight,
+// which does not allow credentials, so requiring it keeps other sites from
+// acting with the admin's saved password.
+func sameOriginHTMX(r *http.Request) bool {
+	if r.Header.Get("HX-Request") != "true" {
+		return false
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return false
+		}
+	}
+	return true
+}
+
+// AdminComment is a comment in the moderation queue
+type AdminComment struct {
+	Comment
+	PostTitle    string
+	ParentAuthor string // author of the comment replied to
+}
+
+type AdminCommentsPageData struct {
+	PageName     string
+	Title        string
+	Description  string
+	CanonicalURL string
+	OgImage      string
+	Comments     []AdminComment
+	Enabled      bool
+}
+
+// AdminCommentsHandler shows the comments waiting for moderation
+func AdminCommentsHandler(w http.ResponseWriter, r *http.Request) error {
+	if !requireAdmin(w, r) {
+		return nil
+	}
+	templates, err := adminTemplates("comments.html")
+	if err != nil {
+		return err
+	}
+
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+	data := AdminCommentsPageData{
+		PageName:     "admin",
+		Title:        "Comments",
+		Description:  "Comments waiting for moderation.",
+		CanonicalURL: blogData.siteURL() + "/admin/comments",
+		Enabled:      commentStore != nil,
+	}
+	if commentStore != nil {
+		for _, c := range commentStore.Pending() {
+			data.Comments = append(data.Comments, adminComment(blogData, c))
+		}
+	}
+	return templates.ExecuteTemplate(w, "admin-comments", data)
+}
+
+// AdminCommentActionHandler approves or rejects a comment and returns its
+// updated row in the moderation queue
+func AdminCommentActionHandler(w http.ResponseWriter, r *http.Request) error {
+	if !requireAdmin(w, r) {
+		return nil
+	}
+	if !sameOriginHTMX(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil
+	}
+	if commentStore == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	vars := mux.Vars(r)
+	status := CommentApproved
+	if vars["action"] == "reject" {
+		status = CommentRejected
+	}
+	if _, ok := commentStore.Get(vars["id"]); !ok {
+		http.NotFound(w, r)
+		return nil
+	}
+	comment, err := commentStore.SetStatus(vars["id"], status)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Comment %s on %s %s", comment.ID, comment.Post, comment.Status)
+
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+	templates, err := adminTemplates("comments.html")
+	if err != nil {
+		return err
+	}
+	return templates.ExecuteTemplate(w, "admin-comment", adminComment(blogData, comment))
+}
+
+func adminComment(blogData *BlogData, c Comment) AdminComment {
+	ac := AdminComment{Comment: c, PostTitle: c.Post}
+	if post, _ := blogData.PreviewBySlug(c.Post); post != nil {
+		ac.PostTitle = post.Title
+	}
+	if parent, ok := commentStore.Get(c.Parent); ok {
+		ac.ParentAuthor = parent.Author
+	}
+	return ac
+}
+
+// adminTemplates parses one admin page; each defines its own content block
+func adminTemplates(page string) (*template.Template, error) {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return templates.ParseFiles(filepath.Join(utils.Templates.BasePath, "admin", page))
+}
@@ -0,0 +1,562 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/logger"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// DayStats is one day of page views, in the site timezone
+type DayStats struct {
+	Date      string                `json:"date"` // YYYY-MM-DD
+	Views     int                   `json:"views"`
+	Visitors  int                   `json:"visitors"`
+	Routes    map[string]*ViewCount `json:"routes"`    // by route, such as /writings/{slug}
+	Posts     map[string]*ViewCount `json:"posts"`     // by post slug
+	Referrers map[string]int        `json:"referrers"` // views by referring domain
+	Agents    map[string]int        `json:"agents"`    // views by user agent class
+}
+
+// clone copies the day so it can be read while counting goes on
+func (d *DayStats) clone() DayStats {
+	c := *newDayStats(d.Date)
+	c.Views, c.Visitors = d.Views, d.Visitors
+	for k, v := range d.Routes {
+		count := *v
+		c.Routes[k] = &count
+	}
+	for k, v := range d.Posts {
+		count := *v
+		c.Posts[k] = &count
+	}
+	for k, v := range d.Referrers {
+		c.Referrers[k] = v
+	}
+	for k, v := range d.Agents {
+		c.Agents[k] = v
+	}
+	return c
+}
+
+// ViewCount is the views and unique visitors of a route or post
+type ViewCount struct {
+	Views    int `json:"views"`
+	Visitors int `json:"visitors"`
+}
+
+func newDayStats(date string) *DayStats {
+	return &DayStats{
+		Date:      date,
+		Routes:    make(map[string]*ViewCount),
+		Posts:     make(map[string]*ViewCount),
+		Referrers: make(map[string]int),
+		Agents:    make(map[string]int),
+	}
+}
+
+// Analytics counts page views without cookies and without keeping IP
+// addresses. A visitor is known only by a hash of their address and user
+// agent salted with a random value that lives in memory for one day, so
+// visitors cannot be followed from one day to the next, or at all once the
+// day is over. Counts are kept in memory and written to one file per day.
+type Analytics struct {
+	mu      sync.Mutex
+	dir     string
+	trusted []*net.IPNet // proxies whose X-Forwarded-For is believed
+	now     func() time.Time
+
+	today *DayStats
+	salt  []byte
+	seen  map[string]map[uint64]bool // visitor hashes by counter: "", "route:…" or "post:…"
+	dirty bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenAnalytics opens the page view store in dir, creating the directory if
+// needed and picking up today's counts. trustedProxies are addresses or CIDR
+// ranges of proxies whose X-Forwarded-For header gives the client address.
+func OpenAnalytics(dir string, trustedProxies []string) (*Analytics, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create analytics directory: %w", err)
+	}
+	a := &Analytics{dir: dir, now: time.Now}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", proxy, err)
+		}
+		a.trusted = append(a.trusted, network)
+	}
+	if err := a.startDay(a.date(a.now())); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *Analytics) date(t time.Time) string {
+	return t.In(utils.SiteLocation()).Format("2006-01-02")
+}
+
+func (a *Analytics) path(date string) string {
+	return filepath.Join(a.dir, date+".json")
+}
+
+// startDay begins counting a new day with a fresh salt, carrying on from the
+// day's file if the server was restarted. Visitors seen before the restart
+// cannot be recognised, so they may be counted again.
+func (a *Analytics) startDay(date string) error {
+	day, err := a.readDay(date)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	a.today, a.salt, a.dirty = day, salt, false
+	a.seen = make(map[string]map[uint64]bool)
+	return nil
+}
+
+// readDay reads the counts of a day; days without a file had no views
+func (a *Analytics) readDay(date string) (*DayStats, error) {
+	data, err := os.ReadFile(a.path(date))
+	if errors.Is(err, os.ErrNotExist) {
+		return newDayStats(date), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analytics for %s: %w", date, err)
+	}
+	day := newDayStats(date)
+	if err := json.Unmarshal(data, day); err != nil {
+		return nil, fmt.Errorf("failed to parse analytics for %s: %w", date, err)
+	}
+	return day, nil
+}
+
+// Record counts a page view. Bots and draft previews are not counted.
+func (a *Analytics) Record(r *http.Request) {
+	ua := r.Header.Get("User-Agent")
+	agent := utils.UserAgentClass(ua)
+	if agent == utils.AgentBot || r.URL.Query().Get("preview") != "" {
+		return
+	}
+
+	route := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			route = template
+		}
+	}
+	slug := ""
+	// Posts are counted by slug whatever language prefix they are read under
+	if strings.HasSuffix(route, "/writings/{slug}") || route == "/blog/{slug}" {
+		slug = mux.Vars(r)["slug"]
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if date := a.date(a.now()); date != a.today.Date {
+		if err := a.flush(); err != nil {
+			logger.Errorf("Failed to save analytics for %s: %v", a.today.Date, err)
+		}
+		if err := a.startDay(date); err != nil {
+			logger.Errorf("Failed to start analytics for %s: %v", date, err)
+			return
+		}
+	}
+
+	visitor := a.visitorHash(a.clientIP(r), ua)
+	day := a.today
+	day.Views++
+	if a.firstVisit("", visitor) {
+		day.Visitors++
+	}
+	a.count(day.Routes, "route:", route, visitor)
+	if slug != "" {
+		a.count(day.Posts, "post:", slug, visitor)
+	}
+	if domain := referrerDomain(r); domain != "" {
+		// The referrer is whatever the client sends, so a day keeps a
+		// bounded number of domains and counts the rest together
+		if _, ok := day.Referrers[domain]; !ok && len(day.Referrers) >= analyticsMaxReferrers {
+			domain = analyticsOtherReferrer
+		}
+		day.Referrers[domain]++
+	}
+	day.Agents[agent]++
+	a.dirty = true
+}
+
+func (a *Analytics) count(counts map[string]*ViewCount, scope, key string, visitor uint64) {
+	c, ok := counts[key]
+	if !ok {
+		c = &ViewCount{}
+		counts[key] = c
+	}
+	c.Views++
+	if a.firstVisit(scope+key, visitor) {
+		c.Visitors++
+	}
+}
+
+// firstVisit reports whether the visitor is new to a counter today
+func (a *Analytics) firstVisit(counter string, visitor uint64) bool {
+	seen, ok := a.seen[counter]
+	if !ok {
+		seen = make(map[uint64]bool)
+		a.seen[counter] = seen
+	}
+	if seen[visitor] {
+		return false
+	}
+	seen[visitor] = true
+	return true
+}
+
+// visitorHash identifies a visitor for the day without keeping their address
+func (a *Analytics) visitorHash(ip, ua string) uint64 {
+	h := sha256.New()
+	h.Write(a.salt)
+	h.Write([]byte(ip))
+	h.Write([]byte{0})
+	h.Write([]byte(ua))
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+// clientIP returns the address of the client, reading X-Forwarded-For from
+// the right only while the request came through a trusted proxy
+func (a *Analytics) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0 && a.isTrusted(ip); i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+	}
+	return ip
+}
+
+func (a *Analytics) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range a.trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// analyticsMaxReferrers is how many referring domains a day keeps
+	analyticsMaxReferrers = 500
+	// analyticsOtherReferrer counts the referrers past the limit
+	analyticsOtherReferrer = "other"
+)
+
+// referrerDomain returns the domain a reader came from, or "" when they came
+// from this site or the browser sent no referrer
+func referrerDomain(r *http.Request) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || ref.Hostname() == "" {
+		return ""
+	}
+	domain := strings.TrimPrefix(strings.ToLower(ref.Hostname()), "www.")
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if domain == strings.TrimPrefix(strings.ToLower(host), "www.") {
+		return ""
+	}
+	return domain
+}
+
+// Flush writes today's counts to disk if they changed
+func (a *Analytics) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.flush()
+}
+
+func (a *Analytics) flush() error {
+	if !a.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(a.today, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(a.path(a.today.Date), data); err != nil {
+		return err
+	}
+	a.dirty = false
+	return nil
+}
+
+// Start flushes the counts to disk every interval until Close
+func (a *Analytics) Start(interval time.Duration) {
+	a.stop, a.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := a.Flush(); err != nil {
+					logger.Errorf("Failed to save analytics: %v", err)
+				}
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the flush loop and writes the last counts
+func (a *Analytics) Close() error {
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+		a.stop = nil
+	}
+	return a.Flush()
+}
+
+// Days returns the counts of the last n days, oldest first, today included
+func (a *Analytics) Days(n int) ([]DayStats, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	end := a.now().In(utils.SiteLocation())
+	days := make([]DayStats, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		date := a.date(end.AddDate(0, 0, -i))
+		if date == a.today.Date {
+			days = append(days, a.today.clone())
+			continue
+		}
+		day, err := a.readDay(date)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, *day)
+	}
+	return days, nil
+}
+
+// analytics counts page views; nil while analytics are disabled
+var analytics *Analytics
+
+// SetAnalytics sets where page views are counted
+func SetAnalytics(a *Analytics) {
+	analytics = a
+}
+
+// RecordPageView counts a page view, for the page view middleware
+func RecordPageView(r *http.Request) {
+	if analytics != nil {
+		analytics.Record(r)
+	}
+}
+
+// analyticsRanges are the periods the dashboard offers, in days
+var analyticsRanges = []int{7, 30, 90}
+
+// analyticsTopN is how many rows each dashboard table shows
+const analyticsTopN = 10
+
+type AnalyticsPageData struct {
+	PageName     string
+	Title        string
+	Description  string
+	CanonicalURL string
+	OgImage      string
+	Enabled      bool
+	Days         int
+	Ranges       []int
+	Daily        []AnalyticsDay
+	Views        int
+	Visitors     int // summed over days; a reader seen on two days counts twice
+	TopPosts     []AnalyticsRow
+	TopRoutes    []AnalyticsRow
+	TopReferrers []AnalyticsRow
+	Agents       []AnalyticsRow
+}
+
+// AnalyticsDay is one bar of the views-over-time chart
+type AnalyticsDay struct {
+	Date     time.Time
+	Views    int
+	Visitors int
+	Height   int // percent of the busiest day
+}
+
+// AnalyticsRow is one line of a dashboard table
+type AnalyticsRow struct {
+	Label    string
+	URL      string
+	Views    int
+	Visitors int
+	Share    int // percent of all views in the period
+}
+
+// AdminAnalyticsHandler shows page views over the last 7, 30 or 90 days
+func AdminAnalyticsHandler(w http.ResponseWriter, r *http.Request) error {
+	if !requireAdmin(w, r) {
+		return nil
+	}
+	templates, err := adminTemplates("analytics.html")
+	if err != nil {
+		return err
+	}
+
+	data := AnalyticsPageData{
+		PageName:    "admin",
+		Title:       "Analytics",
+		Description: "Page views, top posts and referrers.",
+		Enabled:     analytics != nil,
+		Days:        analyticsRanges[1],
+		Ranges:      analyticsRanges,
+	}
+	for _, n := range analyticsRanges {
+		if r.URL.Query().Get("days") == fmt.Sprint(n) {
+			data.Days = n
+		}
+	}
+	if analytics == nil {
+		return templates.ExecuteTemplate(w, "admin-analytics", data)
+	}
+
+	days, err := analytics.Days(data.Days)
+	if err != nil {
+		return err
+	}
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+	data.CanonicalURL = blogData.siteURL() + "/admin/analytics"
+
+	posts := make(map[string]*ViewCount)
+	routes := make(map[string]*ViewCount)
+	referrers := make(map[string]int)
+	agents := make(map[string]int)
+	peak := 0
+	for _, day := range days {
+		date, _ := time.ParseInLocation("2006-01-02", day.Date, utils.SiteLocation())
+		data.Daily = append(data.Daily, AnalyticsDay{Date: date, Views: day.Views, Visitors: day.Visitors})
+		data.Views += day.Views
+		data.Visitors += day.Visitors
+		if day.Views > peak {
+			peak = day.Views
+		}
+		addViewCounts(posts, day.Posts)
+		addViewCounts(routes, day.Routes)
+		for k, v := range day.Referrers {
+			referrers[k] += v
+		}
+		for k, v := range day.Agents {
+			agents[k] += v
+		}
+	}
+	for i := range data.Daily {
+		if peak > 0 {
+			data.Daily[i].Height = data.Daily[i].Views * 100 / peak
+		}
+	}
+
+	for slug, count := range posts {
+		row := AnalyticsRow{Label: slug, URL: "/writings/" + url.PathEscape(slug), Views: count.Views, Visitors: count.Visitors}
+		if post, _ := blogData.PreviewBySlug(slug); post != nil {
+			row.Label, row.URL = post.Title, post.URL()
+		}
+		data.TopPosts = append(data.TopPosts, row)
+	}
+	for route, count := range routes {
+		data.TopRoutes = append(data.TopRoutes, AnalyticsRow{Label: route, Views: count.Views, Visitors: count.Visitors})
+	}
+	for domain, views := range referrers {
+		row := AnalyticsRow{Label: domain, URL: "https://" + domain, Views: views}
+		if domain == analyticsOtherReferrer {
+			row.URL = ""
+		}
+		data.TopReferrers = append(data.TopReferrers, row)
+	}
+	for class, views := range agents {
+		data.Agents = append(data.Agents, AnalyticsRow{Label: class, Views: views})
+	}
+	data.TopPosts = topAnalyticsRows(data.TopPosts, data.Views)
+	data.TopRoutes = topAnalyticsRows(data.TopRoutes, data.Views)
+	data.TopReferrers = topAnalyticsRows(data.TopReferrers, data.Views)
+	data.Agents = topAnalyticsRows(data.Agents, data.Views)
+
+	return templates.ExecuteTemplate(w, "admin-analytics", data)
+}
+
+func addViewCounts(total, day map[string]*ViewCount) {
+	for k, v := range day {
+		c, ok := total[k]
+		if !ok {
+			c = &ViewCount{}
+			total[k] = c
+		}
+		c.Views += v.Views
+		c.Visitors += v.Visitors
+	}
+}
+
+// topAnalyticsRows sorts rows by views, keeps the top ones and works out
+// each row's share of all views
+func topAnalyticsRows(rows []AnalyticsRow, views int) []AnalyticsRow {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Views != rows[j].Views {
+			return rows[i].Views > rows[j].Views
+		}
+		return rows[i].Label < rows[j].Label
+	})
+	if len(rows) > analyticsTopN {
+		rows = rows[:analyticsTopN]
+	}
+	for i := range rows {
+		if views > 0 {
+			rows[i].Share = rows[i].Views * 100 / views
+		}
+	}
+	return rows
+}
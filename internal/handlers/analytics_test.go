@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/middleware"
+)
+
+const (
+	testDesktopUA = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Safari/605.1.15"
+	testMobileUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148 Safari/604.1"
+)
+
+func useTestAnalytics(t *testing.T, dir string, now time.Time) *Analytics {
+	t.Helper()
+
+	a, err := OpenAnalytics(dir, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	a.startDay(a.date(now))
+	previous := analytics
+	SetAnalytics(a)
+	t.Cleanup(func() { SetAnalytics(previous) })
+	return a
+}
+
+// analyticsTestRouter serves pages the way the site does, with page views
+// counted by the middleware
+func analyticsTestRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(middleware.PageViews(RecordPageView))
+	page := func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("<!DOCTYPE html><p>page</p>")) }
+	router.HandleFunc("/writings/{slug}", page)
+	router.HandleFunc("/about", page)
+	router.HandleFunc("/feed.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	})
+	router.HandleFunc("/missing", http.NotFound)
+	return router
+}
+
+func TestPageViewCounting(t *testing.T) {
+	a := useTestAnalytics(t, t.TempDir(), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	router := analyticsTestRouter()
+
+	visit := func(path, remoteAddr, forwarded, ua, referrer string, htmx bool) {
+		req := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("User-Agent", ua)
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		if referrer != "" {
+			req.Header.Set("Referer", referrer)
+		}
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Two readers behind the trusted proxy, one of them reading twice
+	visit("/writings/one", "10.0.0.2:1234", "203.0.113.7", testDesktopUA, "https://news.ycombinator.com/item?id=1", false)
+	visit("/writings/one", "10.0.0.2:1234", "203.0.113.7", testDesktopUA, "http://example.com/about", false)
+	visit("/writings/one", "10.0.0.2:1234", "203.0.113.8", testMobileUA, "https://www.google.com/", false)
+	visit("/about", "10.0.0.2:1234", "203.0.113.8", testMobileUA, "", false)
+	// An untrusted client cannot pose as another reader
+	visit("/about", "198.51.100.1:1234", "203.0.113.7", testDesktopUA, "", false)
+
+	// None of these are page views
+	visit("/writings/one", "10.0.0.2:1234", "203.0.113.9", "curl/8.0", "", false)
+	visit("/writings/one", "10.0.0.2:1234", "203.0.113.9", testDesktopUA, "", true)
+	visit("/feed.json", "10.0.0.2:1234", "203.0.113.9", testDesktopUA, "", false)
+	visit("/missing", "10.0.0.2:1234", "203.0.113.9", testDesktopUA, "", false)
+
+	days, err := a.Days(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := days[0]
+	if day.Date != "2024-05-01" || day.Views != 5 || day.Visitors != 3 {
+		t.Errorf("Expected 5 views by 3 visitors on 2024-05-01, got %d by %d on %s", day.Views, day.Visitors, day.Date)
+	}
+	if post := day.Posts["one"]; post == nil || post.Views != 3 || post.Visitors != 2 {
+		t.Errorf("Expected 3 views by 2 visitors of post one, got %+v", post)
+	}
+	if route := day.Routes["/writings/{slug}"]; route == nil || route.Views != 3 {
+		t.Errorf("Expected views counted by route, got %+v", day.Routes)
+	}
+	if len(day.Referrers) != 2 || day.Referrers["news.ycombinator.com"] != 1 || day.Referrers["google.com"] != 1 {
+		t.Errorf("Expected external referrer domains only, got %v", day.Referrers)
+	}
+	if day.Agents["desktop"] != 3 || day.Agents["mobile"] != 2 {
+		t.Errorf("Expected views by device class, got %v", day.Agents)
+	}
+}
+
+func TestReferrerLimit(t *testing.T) {
+	a := useTestAnalytics(t, t.TempDir(), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	visit := func(referrer string) {
+		req := httptest.NewRequest("GET", "/about", nil)
+		req.Header.Set("User-Agent", testDesktopUA)
+		req.Header.Set("Referer", referrer)
+		a.Record(req)
+	}
+
+	for i := 0; i < analyticsMaxReferrers+5; i++ {
+		visit(fmt.Sprintf("https://spam%d.example.net/", i))
+	}
+	// Domains seen before the limit was reached are still counted by name
+	visit("https://spam0.example.net/")
+
+	day := a.today
+	if len(day.Referrers) != analyticsMaxReferrers+1 {
+		t.Errorf("Expected %d referrers and other, got %d", analyticsMaxReferrers, len(day.Referrers))
+	}
+	if day.Referrers[analyticsOtherReferrer] != 5 || day.Referrers["spam0.example.net"] != 2 {
+		t.Errorf("Expected 5 views from other referrers and 2 from spam0, got %d and %d", day.Referrers[analyticsOtherReferrer], day.Referrers["spam0.example.net"])
+	}
+}
+
+func TestAnalyticsDays(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	a := useTestAnalytics(t, dir, now)
+	a.now = func() time.Time { return now }
+	router := analyticsTestRouter()
+	read := func() {
+		req := httptest.NewRequest("GET", "/writings/one", nil)
+		req.Header.Set("User-Agent", testDesktopUA)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	read()
+	// The first view after midnight writes out the day before
+	now = now.Add(2 * time.Hour)
+	read()
+	read()
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenAnalytics(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.now = func() time.Time { return now }
+	reopened.startDay(reopened.date(now))
+	days, err := reopened.Days(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, day := range days {
+		got = append(got, day.Date+":"+strings.Repeat("x", day.Views))
+	}
+	if strings.Join(got, " ") != "2024-04-30: 2024-05-01:x 2024-05-02:xx" {
+		t.Errorf("Expected one view on May 1 and two on May 2, got %v", got)
+	}
+	if days[2].Visitors != 1 {
+		t.Errorf("Expected one visitor on May 2, got %d", days[2].Visitors)
+	}
+}
+
+func TestAdminAnalyticsHandler(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01"),
+	})
+	loadTestBlog(t, dir)
+	useTestAnalytics(t, t.TempDir(), time.Now())
+	SetAdminToken("secret")
+	t.Cleanup(func() { SetAdminToken("") })
+
+	req := httptest.NewRequest("GET", "/writings/one", nil)
+	req.Header.Set("User-Agent", testDesktopUA)
+	req.Header.Set("Referer", "https://lobste.rs/")
+	analyticsTestRouter().ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("GET", "/admin/analytics?days=7", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	if err := AdminAnalyticsHandler(rr, req); err != nil {
+		t.Fatal(err)
+	}
+	body := rr.Body.String()
+	for _, want := range []string{`<a href="/writings/one">one</a>`, `<a href="https://lobste.rs">lobste.rs</a>`, `/writings/{slug}`, `aria-current="page">7 days`, `<link rel="canonical" href="https://example.com/admin/analytics">`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the dashboard to contain %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Log(body)
+	}
+
+	req = httptest.NewRequest("GET", "/admin/analytics", nil)
+	rr = httptest.NewRecorder()
+	AdminAnalyticsHandler(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a password, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// ArchiveMonth is a month with published posts
+type ArchiveMonth struct {
+	Year  int
+	Month time.Month
+	Count int
+}
+
+// URL returns the month's archive page, such as /writings/2024/03
+func (m ArchiveMonth) URL() string {
+	return fmt.Sprintf("/writings/%04d/%02d", m.Year, int(m.Month))
+}
+
+// Name returns the month and year, such as "March 2024"
+func (m ArchiveMonth) Name() string {
+	return fmt.Sprintf("%s %d", m.Month, m.Year)
+}
+
+// ArchiveYear is a year with published posts and its months, newest first
+type ArchiveYear struct {
+	Year   int
+	Count  int
+	Months []ArchiveMonth
+}
+
+// URL returns the year's archive page, such as /writings/2024
+func (y ArchiveYear) URL() string {
+	return fmt.Sprintf("/writings/%04d", y.Year)
+}
+
+// ArchivePeriod is the span of time an archive page lists: every post when
+// Year is zero, a year when Month is zero, otherwise a month
+type ArchivePeriod struct {
+	Year  int
+	Month time.Month
+}
+
+// URL returns the period's archive page
+func (p ArchivePeriod) URL() string {
+	switch {
+	case p.Year == 0:
+		return "/writings/archive"
+	case p.Month == 0:
+		return ArchiveYear{Year: p.Year}.URL()
+	}
+	return ArchiveMonth{Year: p.Year, Month: p.Month}.URL()
+}
+
+// PageURL returns the period's archive page with the given page number
+func (p ArchivePeriod) PageURL(page int) string {
+	if page > 1 {
+		return p.URL() + "?page=" + strconv.Itoa(page)
+	}
+	return p.URL()
+}
+
+// Name returns "Archive", the year or the month and year
+func (p ArchivePeriod) Name() string {
+	switch {
+	case p.Year == 0:
+		return "Archive"
+	case p.Month == 0:
+		return strconv.Itoa(p.Year)
+	}
+	return ArchiveMonth{Year: p.Year, Month: p.Month}.Name()
+}
+
+// Is reports whether the period is exactly the given year, or month when
+// month is not zero
+func (p ArchivePeriod) Is(year int, month time.Month) bool {
+	return p.Year == year && p.Month == month
+}
+
+// contains reports whether t falls in the period
+func (p ArchivePeriod) contains(t time.Time) bool {
+	return p.Year == 0 || (t.Year() == p.Year && (p.Month == 0 || t.Month() == p.Month))
+}
+
+// ArchiveGroup is the posts of one month on an archive page
+type ArchiveGroup struct {
+	ArchiveMonth
+	Posts []BlogPost
+}
+
+// buildArchiveIndex counts the published posts per year and month. Posts must
+// already be sorted newest first.
+func (b *BlogData) buildArchiveIndex() {
+	b.archiveYears = nil
+	for _, post := range b.Posts {
+		year, month := post.PublishDate.Year(), post.PublishDate.Month()
+
+		if n := len(b.archiveYears); n == 0 || b.archiveYears[n-1].Year != year {
+			b.archiveYears = append(b.archiveYears, ArchiveYear{Year: year})
+		}
+		current := &b.archiveYears[len(b.archiveYears)-1]
+		current.Count++
+
+		if n := len(current.Months); n == 0 || current.Months[n-1].Month != month {
+			current.Months = append(current.Months, ArchiveMonth{Year: year, Month: month})
+		}
+		current.Months[len(current.Months)-1].Count++
+	}
+}
+
+// PostsInPeriod returns the published posts in period, newest first
+func (b *BlogData) PostsInPeriod(period ArchivePeriod) []BlogPost {
+	var posts []BlogPost
+	for _, post := range b.Posts {
+		if period.contains(post.PublishDate) {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+// groupByMonth splits posts, newest first, into runs from the same month.
+// Each group carries the month's total count, not just the posts given.
+func (b *BlogData) groupByMonth(posts []BlogPost) []ArchiveGroup {
+	var groups []ArchiveGroup
+	for _, post := range posts {
+		year, month := post.PublishDate.Year(), post.PublishDate.Month()
+		if n := len(groups); n == 0 || groups[n-1].Year != year || groups[n-1].Month != month {
+			groups = append(groups, ArchiveGroup{ArchiveMonth: b.archiveMonth(year, month)})
+		}
+		group := &groups[len(groups)-1]
+		group.Posts = append(group.Posts, post)
+	}
+	return groups
+}
+
+func (b *BlogData) archiveMonth(year int, month time.Month) ArchiveMonth {
+	for _, y := range b.archiveYears {
+		if y.Year != year {
+			continue
+		}
+		for _, m := range y.Months {
+			if m.Month == month {
+				return m
+			}
+		}
+	}
+	return ArchiveMonth{Year: year, Month: month}
+}
+
+// parseArchivePeriod reads the {year} and {month} route variables. It
+// reports false for year 0, which would be the whole archive, and for months
+// outside 1–12.
+func parseArchivePeriod(vars map[string]string) (ArchivePeriod, bool) {
+	var period ArchivePeriod
+	if value, ok := vars["year"]; ok {
+		year, err := strconv.Atoi(value)
+		if err != nil || year < 1 {
+			return period, false
+		}
+		period.Year = year
+	}
+	if value, ok := vars["month"]; ok {
+		month, err := strconv.Atoi(value)
+		if err != nil || month < 1 || month > 12 {
+			return period, false
+		}
+		period.Month = time.Month(month)
+	}
+	return period, true
+}
+
+// BlogArchiveHandler serves /writings/archive, /writings/{year} and
+// /writings/{year}/{month}, listing the posts of the period grouped by month.
+// HTMX requests get just the archive list.
+func BlogArchiveHandler(w http.ResponseWriter, r *http.Request) error {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return err
+	}
+	templates, err = templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "blog", "*.html"))
+	if err != nil {
+		return err
+	}
+
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	period, ok := parseArchivePeriod(mux.Vars(r))
+	if !ok {
+		http.NotFound(w, r)
+		return nil
+	}
+	posts := blogData.PostsInPeriod(period)
+	if len(posts) == 0 && period.Year != 0 {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	postsPerPage := blogData.Archive.PostsPerPage
+	paginatedPosts, page, totalPages := paginatePosts(posts, parseIntParam(r, "page", 1), postsPerPage)
+
+	pageData := BlogPageData{
+		BlogData:      *blogData,
+		PageName:      "writings",
+		CanonicalURL:  blogData.siteURL() + blogData.Prefix + period.PageURL(page),
+		CurrentPage:   page,
+		TotalPages:    totalPages,
+		PostsPerPage:  postsPerPage,
+		ArchivePeriod: &period,
+		ArchiveYears:  blogData.archiveYears,
+		ArchiveGroups: blogData.groupByMonth(paginatedPosts),
+		Feeds:         feedLinks(blogData, "", ""),
+	}
+	pageData.Posts = paginatedPosts
+	pageData.Title = period.Name()
+	pageData.Description = "Every post, by date"
+	if period.Year != 0 {
+		pageData.Description = "Posts from " + period.Name()
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		return templates.ExecuteTemplate(w, "archive-list", pageData)
+	}
+
+	return templates.ExecuteTemplate(w, "blog", pageData)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func loadArchiveTestBlog(t *testing.T) *BlogData {
+	t.Helper()
+
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml":   testPost("one", "2023-11-20"),
+		"two.yaml":   testPost("two", "2024-03-01"),
+		"three.yaml": testPost("three", "2024-03-15"),
+		"four.yaml":  testPost("four", "2024-05-02"),
+		"draft.yaml": "id: \"draft\"\nslug: \"draft\"\npublish_date: \"2024-05-03\"\npublished: false\n",
+	})
+	return loadTestBlog(t, dir)
+}
+
+func TestArchiveIndex(t *testing.T) {
+	blogData := loadArchiveTestBlog(t)
+
+	years := blogData.archiveYears
+	if len(years) != 2 || years[0].Year != 2024 || years[0].Count != 3 || years[1].Year != 2023 {
+		t.Fatalf("Unexpected archive years: %+v", years)
+	}
+	months := years[0].Months
+	if len(months) != 2 || months[0].Month != time.May || months[1].Month != time.March || months[1].Count != 2 {
+		t.Errorf("Unexpected months for 2024: %+v", months)
+	}
+	if url := months[1].URL(); url != "/writings/2024/03" {
+		t.Errorf("Expected /writings/2024/03, got %s", url)
+	}
+}
+
+func TestBlogArchiveHandler(t *testing.T) {
+	loadArchiveTestBlog(t)
+
+	tests := []struct {
+		name           string
+		path           string
+		vars           map[string]string
+		expectedStatus int
+		want           []string
+		notWant        []string
+	}{
+		{
+			name:           "all posts",
+			path:           "/writings/archive",
+			expectedStatus: http.StatusOK,
+			want:           []string{`href="/writings/one"`, `href="/writings/four"`, `<link rel="canonical" href="https://example.com/writings/archive">`},
+			notWant:        []string{`href="/writings/draft"`},
+		},
+		{
+			name:           "year",
+			path:           "/writings/2024",
+			vars:           map[string]string{"year": "2024"},
+			expectedStatus: http.StatusOK,
+			want:           []string{`href="/writings/two"`, "march 2024", `<link rel="canonical" href="https://example.com/writings/2024">`},
+			notWant:        []string{`href="/writings/one"`},
+		},
+		{
+			name:           "month",
+			path:           "/writings/2024/03",
+			vars:           map[string]string{"year": "2024", "month": "03"},
+			expectedStatus: http.StatusOK,
+			want:           []string{`href="/writings/two"`, `href="/writings/three"`, "2 posts"},
+			notWant:        []string{`href="/writings/four"`},
+		},
+		{name: "month without posts", path: "/writings/2024/04", vars: map[string]string{"year": "2024", "month": "04"}, expectedStatus: http.StatusNotFound},
+		{name: "invalid month", path: "/writings/2024/13", vars: map[string]string{"year": "2024", "month": "13"}, expectedStatus: http.StatusNotFound},
+		{name: "month zero", path: "/writings/2024/00", vars: map[string]string{"year": "2024", "month": "00"}, expectedStatus: http.StatusNotFound},
+		{name: "year zero", path: "/writings/0000", vars: map[string]string{"year": "0000"}, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.vars != nil {
+				req = mux.SetURLVars(req, tt.vars)
+			}
+			rr := httptest.NewRecorder()
+
+			if err := BlogArchiveHandler(rr, req); err != nil {
+				t.Fatalf("BlogArchiveHandler returned an error: %v", err)
+			}
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+
+			body := rr.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected %s to contain %s", tt.path, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("Expected %s not to contain %s", tt.path, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestBlogArchiveHandlerPagination(t *testing.T) {
+	blogData := loadArchiveTestBlog(t)
+	blogData.Archive.PostsPerPage = 2
+
+	req := httptest.NewRequest("GET", "/writings/archive?page=2", nil)
+	req.Header.Set("HX-Request", "true")
+	rr := httptest.NewRecorder()
+	if err := BlogArchiveHandler(rr, req); err != nil {
+		t.Fatalf("BlogArchiveHandler returned an error: %v", err)
+	}
+
+	body := rr.Body.String()
+	if strings.Contains(body, "<html") {
+		t.Error("Expected an HTMX request to get only the archive list")
+	}
+	if !strings.Contains(body, `href="/writings/one"`) || strings.Contains(body, `href="/writings/four"`) {
+		t.Error("Expected page 2 to hold the oldest posts")
+	}
+	if !strings.Contains(body, `hx-get="/writings/archive"`) || !strings.Contains(body, "page 2 of 2") {
+		t.Error("Expected a link back to page 1")
+	}
+}
+
+func TestSitemapHandler(t *testing.T) {
+	loadArchiveTestBlog(t)
+
+	rr := httptest.NewRecorder()
+	if err := SitemapHandler(rr, httptest.NewRequest("GET", "/sitemap.xml", nil)); err != nil {
+		t.Fatalf("SitemapHandler returned an error: %v", err)
+	}
+
+	var doc sitemapURLSet
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	locs := make(map[string]string)
+	for _, u := range doc.URLs {
+		locs[u.Loc] = u.LastMod
+	}
+
+	for loc, lastMod := range map[string]string{
+		"https://example.com/":                 "",
+		"https://example.com/writings/three":   "2024-03-15",
+		"https://example.com/writings/archive": "2024-05-02",
+		"https://example.com/writings/2023":    "2023-11-20",
+		"https://example.com/writings/2024/03": "2024-03-15",
+	} {
+		got, ok := locs[loc]
+		if !ok {
+			t.Errorf("Expected the sitemap to list %s", loc)
+		} else if got != lastMod {
+			t.Errorf("Expected %s to have lastmod %q, got %q", loc, lastMod, got)
+		}
+	}
+	if _, ok := locs["https://example.com/writings/draft"]; ok {
+		t.Error("Expected drafts to be left out of the sitemap")
+	}
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v3"
+)
+
+// Author is a writer from authors.yaml. Posts name their authors by ID.
+type Author struct {
+	ID     string       `json:"id,omitempty" yaml:"id"`
+	Name   string       `json:"name" yaml:"name"`
+	Bio    string       `json:"bio,omitempty" yaml:"bio"`
+	Avatar string       `json:"avatar,omitempty" yaml:"avatar"` // image path or URL
+	Links  []AuthorLink `json:"links,omitempty" yaml:"links"`
+}
+
+// AuthorLink is one of an author's profiles elsewhere
+type AuthorLink struct {
+	Name string `json:"name" yaml:"name"` // such as "GitHub"
+	URL  string `json:"url" yaml:"url"`
+}
+
+type authorsYAML struct {
+	Authors []Author `yaml:"authors"`
+}
+
+// AuthorURL returns the page of the author with the given ID
+func AuthorURL(id string) string {
+	return "/writings/author/" + url.PathEscape(id)
+}
+
+// URL returns the author's page, or "" for an author named by a post without
+// an authors.yaml entry
+func (a Author) URL() string {
+	if a.ID == "" {
+		return ""
+	}
+	return AuthorURL(a.ID)
+}
+
+// Initials stands in for the avatar of an author without one
+func (a Author) Initials() string {
+	var initials []rune
+	for _, word := range strings.Fields(a.Name) {
+		r, _ := utf8.DecodeRuneInString(word)
+		if unicode.IsLetter(r) {
+			initials = append(initials, unicode.ToUpper(r))
+		}
+		if len(initials) == 2 {
+			break
+		}
+	}
+	return string(initials)
+}
+
+// structuredData describes the author as a schema.org Person
+func (a Author) structuredData(siteURL string) map[string]any {
+	person := map[string]any{"@type": "Person", "name": a.Name}
+	if a.ID != "" {
+		person["url"] = siteURL + a.URL()
+	}
+	if a.Avatar != "" {
+		person["image"] = absoluteURL(siteURL, a.Avatar)
+	}
+	if len(a.Links) > 0 {
+		sameAs := make([]string, len(a.Links))
+		for i, link := range a.Links {
+			sameAs[i] = link.URL
+		}
+		person["sameAs"] = sameAs
+	}
+	return person
+}
+
+// readAuthors reads authors.yaml from the blog directory. The file is
+// optional; without it posts give their authors by name.
+func readAuthors(dir string) ([]Author, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "authors.yaml"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authors.yaml: %w", err)
+	}
+
+	var file authorsYAML
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal authors.yaml: %w", err)
+	}
+	ids := make(map[string]bool, len(file.Authors))
+	for _, author := range file.Authors {
+		switch {
+		case author.ID == "":
+			return nil, fmt.Errorf("authors.yaml: author %q: missing id", author.Name)
+		case strings.ContainsAny(author.ID, "/?#"):
+			return nil, fmt.Errorf("authors.yaml: author %q: id may not contain /, ? or #", author.ID)
+		case ids[author.ID]:
+			return nil, fmt.Errorf("authors.yaml: duplicate author id %q", author.ID)
+		case strings.TrimSpace(author.Name) == "":
+			return nil, fmt.Errorf("authors.yaml: author %q: missing name", author.ID)
+		}
+		ids[author.ID] = true
+	}
+	return file.Authors, nil
+}
+
+// authorIDs returns the authors a post names, with either author: for one
+// or authors: for several
+func (p BlogPostYAML) authorIDs() ([]string, error) {
+	if p.Author != "" && len(p.Authors) > 0 {
+		return nil, errors.New("author and authors: give one or the other")
+	}
+	if p.Author != "" {
+		return []string{p.Author}, nil
+	}
+	seen := make(map[string]bool, len(p.Authors))
+	for _, id := range p.Authors {
+		if seen[id] {
+			return nil, fmt.Errorf("authors: %q is listed twice", id)
+		}
+		seen[id] = true
+	}
+	return p.Authors, nil
+}
+
+// resolveAuthors looks up the given author IDs. Without authors.yaml there is
+// nothing to look them up in, so they are taken as names.
+func resolveAuthors(registry []Author, ids []string) ([]Author, error) {
+	var authors []Author
+	for _, id := range ids {
+		if len(registry) == 0 {
+			authors = append(authors, Author{Name: id})
+			continue
+		}
+		author := getAuthorByID(registry, id)
+		if author == nil {
+			return nil, fmt.Errorf("unknown author %q (not in authors.yaml)", id)
+		}
+		authors = append(authors, *author)
+	}
+	return authors, nil
+}
+
+func getAuthorByID(authors []Author, id string) *Author {
+	for i := range authors {
+		if authors[i].ID == id {
+			return &authors[i]
+		}
+	}
+	return nil
+}
+
+// SiteAuthor returns the author given by meta.author in blogs.yaml, who is
+// credited with the posts that name no author, or nil
+func (b *BlogData) SiteAuthor() *Author {
+	return b.siteAuthor
+}
+
+// PostsByAuthor returns the posts by the author with the given ID, newest
+// first
+func (b *BlogData) PostsByAuthor(id string) []BlogPost {
+	return b.postsByAuthor[id]
+}
+
+// AuthorNames lists the names of the post's authors for a byline, such as
+// "Ada, Bob and Eve"
+func (p BlogPost) AuthorNames() string {
+	names := make([]string, len(p.Authors))
+	for i, author := range p.Authors {
+		names[i] = author.Name
+	}
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// BlogAuthorHandler serves /writings/author/{author}, an author's profile and
+// posts
+func BlogAuthorHandler(w http.ResponseWriter, r *http.Request) error {
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	author := getAuthorByID(blogData.Authors, mux.Vars(r)["author"])
+	if author == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	pageData := BlogPageData{BlogData: *blogData, Author: author, ListingPath: blogData.Prefix + author.URL()}
+	pageData.Title = author.Name
+	pageData.Description = author.Bio
+	if author.Avatar != "" {
+		pageData.OgImage = absoluteURL(blogData.siteURL(), author.Avatar)
+	}
+	return serveLanding(w, r, blogData, blogData.PostsByAuthor(author.ID), pageData)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const testAuthorsYAML = `authors:
+  - id: "ada"
+    name: "Ada Lovelace"
+    bio: "Writes about engines."
+    avatar: "/static/ada.png"
+    links:
+      - name: "GitHub"
+        url: "https://github.com/ada"
+  - id: "bob"
+    name: "Bob"
+`
+
+func writeTestAuthors(t *testing.T, dir, body string) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, "authors.yaml"), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadAuthorsTestBlog(t *testing.T) *BlogData {
+	t.Helper()
+
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml":   testPost("one", "2024-01-01", `category: "engineering"`, `author: "ada"`, `content: "One."`),
+		"two.yaml":   testPost("two", "2024-02-01", `category: "engineering"`, `authors: ["ada", "bob"]`, `content: "Two."`),
+		"three.yaml": testPost("three", "2024-03-01", `content: "Three."`),
+	})
+	writeTestAuthors(t, dir, testAuthorsYAML)
+	return loadTestBlog(t, dir)
+}
+
+func TestLoadBlogDataAuthors(t *testing.T) {
+	blogData := loadAuthorsTestBlog(t)
+
+	two := blogData.PostBySlug("two")
+	if len(two.Authors) != 2 || two.Authors[0].Name != "Ada Lovelace" || two.Authors[1].ID != "bob" {
+		t.Fatalf("Expected two to be by Ada and Bob, got %+v", two.Authors)
+	}
+	if got := two.AuthorNames(); got != "Ada Lovelace and Bob" {
+		t.Errorf("AuthorNames() = %q", got)
+	}
+	if three := blogData.PostBySlug("three"); len(three.Authors) != 0 {
+		t.Errorf("Expected no authors without meta.author, got %+v", three.Authors)
+	}
+	var slugs []string
+	for _, post := range blogData.PostsByAuthor("ada") {
+		slugs = append(slugs, post.Slug)
+	}
+	if strings.Join(slugs, ",") != "two,one" {
+		t.Errorf("PostsByAuthor(ada) = %v, want newest first", slugs)
+	}
+
+	tests := []struct {
+		name    string
+		authors string // authors.yaml, or "" for none
+		post    string
+		want    string
+	}{
+		{"unknown author", testAuthorsYAML, "author: \"eve\"\n", `unknown author "eve"`},
+		{"author and authors", testAuthorsYAML, "author: \"ada\"\nauthors: [\"bob\"]\n", "give one or the other"},
+		{"listed twice", testAuthorsYAML, "authors: [\"ada\", \"ada\"]\n", `"ada" is listed twice`},
+		{"duplicate id", testAuthorsYAML + "  - id: \"ada\"\n    name: \"Another Ada\"\n", "", `duplicate author id "ada"`},
+		{"missing name", "authors:\n  - id: \"nameless\"\n", "", "missing name"},
+		{"name without registry", "", "author: \"Jane Doe\"\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestBlog(t, map[string]string{
+				"post.yaml": testPost("post", "2024-01-01", tt.post, `content: "Post."`),
+			})
+			if tt.authors != "" {
+				writeTestAuthors(t, dir, tt.authors)
+			}
+			blogData, err := loadBlogData(dir)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				author := blogData.PostBySlug("post").Authors[0]
+				if author.Name != "Jane Doe" || author.URL() != "" {
+					t.Errorf("Expected the name to be kept without a page, got %+v", author)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestBlogAuthorPages(t *testing.T) {
+	loadAuthorsTestBlog(t)
+
+	get := func(handler func(http.ResponseWriter, *http.Request) error, path string, vars map[string]string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", path, nil), vars)
+		rr := httptest.NewRecorder()
+		if err := handler(rr, req); err != nil {
+			t.Fatalf("GET %s returned an error: %v", path, err)
+		}
+		return rr
+	}
+
+	rr := get(BlogAuthorHandler, "/writings/author/ada", map[string]string{"author": "ada"})
+	body := rr.Body.String()
+	for _, want := range []string{
+		"ada lovelace", "2 posts", "Writes about engines.", `href="https://github.com/ada"`,
+		`<img class="author-avatar" src="/static/ada.png"`, `href="/writings/two"`, `href="/writings/one"`,
+		`<link rel="canonical" href="https://example.com/writings/author/ada">`, `"@type":"ProfilePage"`,
+		`<meta property="og:image" content="https://example.com/static/ada.png">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the author page to contain %s", want)
+		}
+	}
+	if strings.Contains(body, `href="/writings/three"`) {
+		t.Error("Expected the author page to list only their posts")
+	}
+	if rr := get(BlogAuthorHandler, "/writings/author/eve", map[string]string{"author": "eve"}); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown author, got %d", rr.Code)
+	}
+
+	body = get(BlogPostHandler, "/writings/two", map[string]string{"slug": "two"}).Body.String()
+	for _, want := range []string{
+		`by <a href="/writings/author/ada" rel="author">Ada Lovelace</a> and <a href="/writings/author/bob" rel="author">Bob</a>`,
+		`<span class="author-avatar" aria-hidden="true">B</span>`,
+		`"url":"https://example.com/writings/author/bob"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected the post to contain %s", want)
+		}
+	}
+
+	body = get(BlogRSSHandler, "/writings/feed.xml", nil).Body.String()
+	if !strings.Contains(body, "<dc:creator>Ada Lovelace</dc:creator>\n      <dc:creator>Bob</dc:creator>") {
+		t.Errorf("Expected an RSS creator for each author:\n%s", body)
+	}
+	body = get(BlogJSONFeedHandler, "/writings/feed.json", nil).Body.String()
+	if !strings.Contains(body, `"url": "https://example.com/writings/author/ada"`) || !strings.Contains(body, `"avatar": "https://example.com/static/ada.png"`) {
+		t.Errorf("Expected JSON Feed authors with their pages and avatars:\n%s", body)
+	}
+}
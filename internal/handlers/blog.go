@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"fmt"
+	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
@@ -18,37 +20,56 @@ import (
 
 // Blog data structures
 type BlogPost struct {
-	ID          string     `json:"id" yaml:"id"`
-	Title       string     `json:"title" yaml:"title"`
-	Slug        string     `json:"slug" yaml:"slug"`
-	Excerpt     string     `json:"excerpt" yaml:"excerpt"`
-	Content     string     `json:"content" yaml:"content"`
-	Author      string     `json:"author" yaml:"author"`
-	PublishDate time.Time  `json:"publish_date" yaml:"-"`
-	UpdatedDate *time.Time `json:"updated_date,omitempty" yaml:"-"`
-	Category    string     `json:"category" yaml:"category"`
-	Tags        []string   `json:"tags" yaml:"tags"`
-	ReadingTime int        `json:"reading_time" yaml:"reading_time"`
-	Featured    bool       `json:"featured" yaml:"featured"`
-	Published   bool       `json:"published" yaml:"published"`
-	Meta        PostMeta   `json:"meta" yaml:"meta"`
+	ID          string      `json:"id" yaml:"id"`
+	Title       string      `json:"title" yaml:"title"`
+	Slug        string      `json:"slug" yaml:"slug"`
+	Excerpt     string      `json:"excerpt" yaml:"excerpt"`
+	Content     string      `json:"content" yaml:"content"`
+	Authors     []Author    `json:"authors,omitempty" yaml:"-"`
+	PublishDate time.Time   `json:"publish_date" yaml:"-"`
+	UpdatedDate *time.Time  `json:"updated_date,omitempty" yaml:"-"`
+	Category    string      `json:"category" yaml:"category"`
+	Tags        []string    `json:"tags" yaml:"tags"`
+	Series      *PostSeries `json:"series,omitempty" yaml:"series"`
+	Related     []string    `json:"related,omitempty" yaml:"related"` // slugs overriding the computed related posts
+	Aliases     []string    `json:"aliases,omitempty" yaml:"aliases"` // old slugs or paths redirecting here
+	ReadingTime int         `json:"reading_time" yaml:"reading_time"`
+	WordCount   int         `json:"word_count" yaml:"-"`
+	Featured    bool        `json:"featured" yaml:"featured"`
+	Published   bool        `json:"published" yaml:"published"`
+	TOC         *bool       `json:"toc,omitempty" yaml:"toc"`
+	Meta        PostMeta    `json:"meta" yaml:"meta"`
+
+	Lang          string        `json:"lang" yaml:"lang"`
+	TranslationOf string        `json:"translation_of,omitempty" yaml:"translation_of"` // ID of the post this translates
+	Translations  []Translation `json:"translations,omitempty" yaml:"-"`                // visible versions in other languages
+
+	prefix string // path prefix of the post's language
 }
 
 type BlogPostYAML struct {
-	ID          string   `yaml:"id"`
-	Title       string   `yaml:"title"`
-	Slug        string   `yaml:"slug"`
-	Excerpt     string   `yaml:"excerpt"`
-	Content     string   `yaml:"content"`
-	Author      string   `yaml:"author"`
-	PublishDate string   `yaml:"publish_date"`
-	UpdatedDate *string  `yaml:"updated_date"`
-	Category    string   `yaml:"category"`
-	Tags        []string `yaml:"tags"`
-	ReadingTime int      `yaml:"reading_time"`
-	Featured    bool     `yaml:"featured"`
-	Published   bool     `yaml:"published"`
-	Meta        PostMeta `yaml:"meta"`
+	ID          string      `yaml:"id"`
+	Title       string      `yaml:"title"`
+	Slug        string      `yaml:"slug"`
+	Excerpt     string      `yaml:"excerpt"`
+	Content     string      `yaml:"content"`
+	Author      string      `yaml:"author"`  // ID of the author, when there is one
+	Authors     []string    `yaml:"authors"` // IDs of the authors, when there are several
+	PublishDate string      `yaml:"publish_date"`
+	UpdatedDate *string     `yaml:"updated_date"`
+	Category    string      `yaml:"category"`
+	Tags        []string    `yaml:"tags"`
+	Series      *PostSeries `yaml:"series"`
+	Related     []string    `yaml:"related"`
+	Aliases     []string    `yaml:"aliases"`
+	ReadingTime int         `yaml:"reading_time"`
+	Featured    bool        `yaml:"featured"`
+	Published   bool        `yaml:"published"`
+	TOC         *bool       `yaml:"toc"`
+	Meta        PostMeta    `yaml:"meta"`
+
+	Lang          string `yaml:"lang"`
+	TranslationOf string `yaml:"translation_of"`
 }
 
 type PostMeta struct {
@@ -66,25 +87,117 @@ type Category struct {
 
 type BlogMeta struct {
 	Keywords []string `json:"keywords" yaml:"keywords"`
-	Author   string   `json:"author" yaml:"author"`
+	Author   string   `json:"author" yaml:"author"` // ID of the default author
 	SiteURL  string   `json:"site_url" yaml:"site_url"`
 }
 
+// siteURL returns meta.site_url without a trailing slash, ready to have paths
+// appended
+func (b *BlogData) siteURL() string {
+	return strings.TrimSuffix(b.Meta.SiteURL, "/")
+}
+
+// RSSConfig is the rss: block of blogs.yaml. It applies to every feed format.
+type RSSConfig struct {
+	Enabled     *bool  `json:"enabled,omitempty" yaml:"enabled"`
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description" yaml:"description"`
+	Link        string `json:"link" yaml:"link"`
+	Language    string `json:"language" yaml:"language"`
+	Copyright   string `json:"copyright" yaml:"copyright"`
+}
+
+// IsEnabled reports whether feeds are served; feeds are on unless disabled
+func (c RSSConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Archive sort fields accepted in blogs.yaml and the ?sort= query parameter
+const (
+	SortByPublishDate = "publish_date"
+	SortByUpdatedDate = "updated_date"
+	SortByTitle       = "title"
+)
+
+// ArchiveConfig is the archive: block of blogs.yaml. It controls how every
+// post listing is paginated and ordered.
+type ArchiveConfig struct {
+	PostsPerPage int    `json:"posts_per_page" yaml:"posts_per_page"`
+	SortBy       string `json:"sort_by" yaml:"sort_by"`
+	SortOrder    string `json:"sort_order" yaml:"sort_order"`
+}
+
+// normalize fills in defaults and rejects unknown values
+func (c *ArchiveConfig) normalize() error {
+	if c.PostsPerPage <= 0 {
+		c.PostsPerPage = 10
+	}
+	if c.SortBy == "" {
+		c.SortBy = SortByPublishDate
+	}
+	if c.SortOrder == "" {
+		c.SortOrder = "desc"
+	}
+	if !validSortBy(c.SortBy) {
+		return fmt.Errorf("archive.sort_by: unknown field %q (expected publish_date, updated_date or title)", c.SortBy)
+	}
+	if !validSortOrder(c.SortOrder) {
+		return fmt.Errorf("archive.sort_order: unknown order %q (expected asc or desc)", c.SortOrder)
+	}
+	return nil
+}
+
+func validSortBy(field string) bool {
+	return field == SortByPublishDate || field == SortByUpdatedDate || field == SortByTitle
+}
+
+func validSortOrder(order string) bool {
+	return order == "asc" || order == "desc"
+}
+
 type BlogData struct {
-	Title       string     `json:"title" yaml:"title"`
-	Subtitle    string     `json:"subtitle" yaml:"subtitle"`
-	Description string     `json:"description" yaml:"description"`
-	Meta        BlogMeta   `json:"meta" yaml:"meta"`
-	Categories  []Category `json:"categories" yaml:"categories"`
-	Posts       []BlogPost `json:"posts" yaml:"-"`
+	Title       string        `json:"title" yaml:"title"`
+	Subtitle    string        `json:"subtitle" yaml:"subtitle"`
+	Description string        `json:"description" yaml:"description"`
+	Meta        BlogMeta      `json:"meta" yaml:"meta"`
+	Categories  []Category    `json:"categories" yaml:"categories"`
+	Archive     ArchiveConfig `json:"archive" yaml:"archive"`
+	RSS         RSSConfig     `json:"rss" yaml:"rss"`
+	Languages   []Language    `json:"languages" yaml:"languages"`
+	Authors     []Author      `json:"authors" yaml:"-"` // from authors.yaml
+	Posts       []BlogPost    `json:"posts" yaml:"-"`
+
+	// Set on the view of one language; see Language
+	Lang   string `json:"lang" yaml:"-"`
+	Prefix string `json:"-" yaml:"-"` // path prefix of the language, such as "/hi"
+
+	// Lookup indexes built once when the data is loaded
+	postsBySlug     map[string]*BlogPost
+	postsByTag      map[string][]BlogPost
+	postsByCategory map[string][]BlogPost
+	postsBySeries   map[string][]BlogPost
+	postsByAuthor   map[string][]BlogPost
+	siteAuthor      *Author
+	search          *searchIndex
+	archiveYears    []ArchiveYear
+	related         map[string][]BlogPost // precomputed related posts, by slug
+	relatedIndex    *relatedIndex
+	nextRelease     time.Time
+	hidden          map[string]*BlogPost // drafts and scheduled posts, by slug
+	redirectRules   []Redirect           // from redirects.yaml
+	redirects       map[string]Redirect  // rules and aliases of visible posts, by path
+	languageViews   map[string]*BlogData // by language code, for a multilingual blog
 }
 
 type BlogDataYAML struct {
-	Title       string     `yaml:"title"`
-	Subtitle    string     `yaml:"subtitle"`
-	Description string     `yaml:"description"`
-	Meta        BlogMeta   `yaml:"meta"`
-	Categories  []Category `yaml:"categories"`
+	Title       string        `yaml:"title"`
+	Subtitle    string        `yaml:"subtitle"`
+	Description string        `yaml:"description"`
+	Meta        BlogMeta      `yaml:"meta"`
+	Categories  []Category    `yaml:"categories"`
+	Archive     ArchiveConfig `yaml:"archive"`
+	RSS         RSSConfig     `yaml:"rss"`
+	Languages   []Language    `yaml:"languages"`
 }
 
 type BlogPageData struct {
@@ -93,15 +206,35 @@ type BlogPageData struct {
 	CanonicalURL     string
 	OgImage          string
 	Post             *BlogPost
+	PostHTML         template.HTML
+	TOC              []utils.TOCEntry
 	CurrentPage      int
 	TotalPages       int
 	PostsPerPage     int
 	SelectedTag      string
 	SelectedCategory string
+	Category         *Category  // metadata of the category landing page
+	Author           *Author    // profile on an author page
+	ListingPath      string     // path of a tag or category landing page
+	PostCount        int        // posts on a landing page, across all pages
+	TagIndex         []TagCount // every tag, on /writings/tags
+	SortBy           string     // empty for search results ranked by relevance
+	SortOrder        string
 	FeaturedPosts    []BlogPost
 	RecentPosts      []BlogPost
 	RelatedPosts     []BlogPost
+	Series           *SeriesNav
+	ArchivePeriod    *ArchivePeriod
+	ArchiveYears     []ArchiveYear
+	ArchiveGroups    []ArchiveGroup
 	AllTags          []string
+	Query            string
+	Snippets         map[string]template.HTML
+	Draft            bool
+	Feeds            []FeedLink
+	CommentForm      *CommentForm    // set when the post takes comments
+	NewsletterForm   *NewsletterForm // set while the newsletter is enabled
+	Alternates       []Alternate     // the page in other languages, for hreflang links
 }
 
 // Main blog listing handler
@@ -115,8 +248,7 @@ func WritingsHandler(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	// Load blog data
-	blogData, err := loadBlogData()
+	blogData, err := languageBlog(r)
 	if err != nil {
 		logger.Errorf("Failed to load blog data: %v", err)
 		return err
@@ -127,41 +259,47 @@ func WritingsHandler(w http.ResponseWriter, r *http.Request) error {
 	tag := r.URL.Query().Get("tag")
 	category := r.URL.Query().Get("category")
 
-	// Filter posts
-	posts := filterPosts(blogData.Posts, tag, category)
+	sortBy, sortOrder := archiveSort(r, blogData.Archive)
 
-	// Pagination
-	postsPerPage := 10
-	totalPages := (len(posts) + postsPerPage - 1) / postsPerPage
-	if page > totalPages && totalPages > 0 {
-		page = totalPages
-	}
-
-	start := (page - 1) * postsPerPage
-	end := start + postsPerPage
-	if end > len(posts) {
-		end = len(posts)
-	}
+	// Filter and order posts
+	posts := sortPosts(filterPosts(blogData, tag, category), sortBy, sortOrder)
 
-	var paginatedPosts []BlogPost
-	if start < len(posts) {
-		paginatedPosts = posts[start:end]
-	}
+	// Pagination
+	postsPerPage := blogData.Archive.PostsPerPage
+	paginatedPosts, page, totalPages := paginatePosts(posts, page, postsPerPage)
 
 	pageData := BlogPageData{
 		BlogData:         *blogData,
 		PageName:         "writings",
-		CanonicalURL:     "https://ankush.fyi/writings",
+		CanonicalURL:     blogData.siteURL() + blogData.Prefix + "/writings",
 		CurrentPage:      page,
 		TotalPages:       totalPages,
 		PostsPerPage:     postsPerPage,
 		SelectedTag:      tag,
 		SelectedCategory: category,
+		SortBy:           sortBy,
+		SortOrder:        sortOrder,
 		FeaturedPosts:    getFeaturedPosts(blogData.Posts),
 		RecentPosts:      getRecentPosts(blogData.Posts, 5),
 		AllTags:          getAllTags(blogData.Posts),
+		Feeds:            feedLinks(blogData, tag, category),
 	}
 	pageData.Posts = paginatedPosts
+	if newsletter != nil {
+		pageData.NewsletterForm = &NewsletterForm{}
+	}
+
+	// Filtered listings point search engines at the tag or category page
+	switch {
+	case tag != "" && category == "":
+		pageData.CanonicalURL = blogData.siteURL() + blogData.Prefix + TagURL(tag)
+	case category != "" && tag == "":
+		if cat := getCategoryBySlug(blogData.Categories, category); cat != nil {
+			pageData.CanonicalURL = blogData.siteURL() + blogData.Prefix + cat.URL()
+		}
+	case tag == "" && category == "":
+		pageData.Alternates = indexAlternates(blogData)
+	}
 
 	if r.Header.Get("HX-Request") == "true" {
 		return templates.ExecuteTemplate(w, "posts-list", pageData)
@@ -184,16 +322,18 @@ func BlogPostHandler(w http.ResponseWriter, r *http.Request) error {
 	vars := mux.Vars(r)
 	slug := vars["slug"]
 
-	blogData, err := loadBlogData()
+	blogData, err := content().Blog()
 	if err != nil {
 		return err
 	}
 
-	var post *BlogPost
-	for i := range blogData.Posts {
-		if blogData.Posts[i].Slug == slug && blogData.Posts[i].Published {
-			post = &blogData.Posts[i]
-			break
+	post := blogData.PostBySlug(slug)
+	var draft bool
+	if token := r.URL.Query().Get("preview"); token != "" {
+		if err := utils.VerifyPreviewToken(previewSecret, slug, token, time.Now()); err != nil {
+			logger.Debugf("Rejected preview of %s: %v", slug, err)
+		} else {
+			post, draft = blogData.PreviewBySlug(slug)
 		}
 	}
 
@@ -202,13 +342,51 @@ func BlogPostHandler(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
+	// A post is served under the prefix of its own language only
+	if post.Lang != blogData.Language(vars["lang"]).Lang {
+		target := post.URL()
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return nil
+	}
+	blogData = blogData.Language(post.Lang)
+	if !draft {
+		// The language view holds its own copy of the post, which its
+		// precomputed related posts are keyed on
+		post = blogData.PostBySlug(slug)
+	}
+
+	if draft {
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	}
+
+	postHTML, toc := utils.RenderMarkdownWithTOC(post.Content)
+	if !post.ShowTOC() || len(toc) < 2 {
+		toc = nil
+	}
+
 	pageData := BlogPageData{
 		BlogData:     *blogData,
 		PageName:     "writings",
-		CanonicalURL: "https://ankush.fyi/writings/" + post.Slug,
-		OgImage:      post.Meta.OGImage,
+		CanonicalURL: blogData.siteURL() + post.URL(),
+		PostHTML:     template.HTML(postHTML),
+		TOC:          toc,
+		OgImage:      absoluteURL(blogData.siteURL(), post.OGImageURL()),
 		Post:         post,
-		RelatedPosts: getRelatedPosts(blogData.Posts, *post, 3),
+		RelatedPosts: blogData.relatedTo(post),
+		Series:       blogData.seriesNav(post),
+		Draft:        draft,
+		Feeds:        feedLinks(blogData, "", ""),
+		Alternates:   postAlternates(blogData, post),
+	}
+	if commentStore != nil && !draft {
+		form := newCommentForm(post.Slug, "")
+		pageData.CommentForm = &form
+	}
+	if newsletter != nil && !draft {
+		pageData.NewsletterForm = &NewsletterForm{}
 	}
 
 	return templates.ExecuteTemplate(w, "blog", pageData)
@@ -225,7 +403,7 @@ func BlogFilterHandler(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	blogData, err := loadBlogData()
+	blogData, err := languageBlog(r)
 	if err != nil {
 		return err
 	}
@@ -234,30 +412,23 @@ func BlogFilterHandler(w http.ResponseWriter, r *http.Request) error {
 	category := r.URL.Query().Get("category")
 	page := parseIntParam(r, "page", 1)
 
-	posts := filterPosts(blogData.Posts, tag, category)
+	sortBy, sortOrder := archiveSort(r, blogData.Archive)
+	posts := sortPosts(filterPosts(blogData, tag, category), sortBy, sortOrder)
 
-	postsPerPage := 10
-	totalPages := (len(posts) + postsPerPage - 1) / postsPerPage
-	start := (page - 1) * postsPerPage
-	end := start + postsPerPage
-	if end > len(posts) {
-		end = len(posts)
-	}
-
-	var paginatedPosts []BlogPost
-	if start < len(posts) {
-		paginatedPosts = posts[start:end]
-	}
+	postsPerPage := blogData.Archive.PostsPerPage
+	paginatedPosts, page, totalPages := paginatePosts(posts, page, postsPerPage)
 
 	pageData := BlogPageData{
 		BlogData:         *blogData,
 		PageName:         "writings",
-		CanonicalURL:     "https://ankush.fyi/writings",
+		CanonicalURL:     blogData.siteURL() + blogData.Prefix + "/writings",
 		CurrentPage:      page,
 		TotalPages:       totalPages,
 		PostsPerPage:     postsPerPage,
 		SelectedTag:      tag,
 		SelectedCategory: category,
+		SortBy:           sortBy,
+		SortOrder:        sortOrder,
 		AllTags:          getAllTags(blogData.Posts),
 	}
 	pageData.Posts = paginatedPosts
@@ -265,63 +436,21 @@ func BlogFilterHandler(w http.ResponseWriter, r *http.Request) error {
 	return templates.ExecuteTemplate(w, "posts-list", pageData)
 }
 
-// RSS feed handler
-func BlogRSSHandler(w http.ResponseWriter, r *http.Request) error {
-	blogData, err := loadBlogData()
+// Helper functions
+func loadBlogData(dir string) (*BlogData, error) {
+	source, err := readBlogData(dir)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	w.Header().Set("Content-Type", "application/rss+xml")
-
-	// Get latest 20 posts
-	posts := getRecentPosts(blogData.Posts, 20)
-
-	rss := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<rss version="2.0">
-<channel>
-<title>%s</title>
-<description>%s</description>
-<link>%s</link>
-<language>en-us</language>
-<lastBuildDate>%s</lastBuildDate>`,
-		blogData.Title,
-		blogData.Description,
-		blogData.Meta.SiteURL,
-		time.Now().Format(time.RFC1123Z))
-
-	for _, post := range posts {
-		rss += fmt.Sprintf(`
-<item>
-<title>%s</title>
-<description><![CDATA[%s]]></description>
-<link>%s/blog/%s</link>
-<guid>%s/blog/%s</guid>
-<pubDate>%s</pubDate>
-<author>%s</author>
-</item>`,
-			post.Title,
-			post.Excerpt,
-			blogData.Meta.SiteURL,
-			post.Slug,
-			blogData.Meta.SiteURL,
-			post.Slug,
-			post.PublishDate.Format(time.RFC1123Z),
-			post.Author)
-	}
-
-	rss += `
-</channel>
-</rss>`
-
-	fmt.Fprint(w, rss)
-	return nil
+	return source.visibleAt(time.Now()), nil
 }
 
-// Helper functions
-func loadBlogData() (*BlogData, error) {
+// readBlogData reads blogs.yaml and every post, including drafts and posts
+// scheduled for a future publish date. The result is not indexed; use visibleAt
+// to get the data that may be served.
+func readBlogData(dir string) (*BlogData, error) {
 	// Load main blog config
-	configFile, err := os.ReadFile("blogs/blogs.yaml")
+	configFile, err := os.ReadFile(filepath.Join(dir, "blogs.yaml"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to read blogs.yaml: %w", err)
 	}
@@ -337,14 +466,37 @@ func loadBlogData() (*BlogData, error) {
 		Description: yamlData.Description,
 		Meta:        yamlData.Meta,
 		Categories:  yamlData.Categories,
+		Archive:     yamlData.Archive,
+		RSS:         yamlData.RSS,
+	}
+	if err := blogData.Archive.normalize(); err != nil {
+		return nil, fmt.Errorf("blogs.yaml: %w", err)
+	}
+	if blogData.Languages, err = normalizeLanguages(yamlData.Languages); err != nil {
+		return nil, fmt.Errorf("blogs.yaml: %w", err)
+	}
+	if blogData.Authors, err = readAuthors(dir); err != nil {
+		return nil, err
+	}
+	if blogData.Meta.Author != "" {
+		authors, err := resolveAuthors(blogData.Authors, []string{blogData.Meta.Author})
+		if err != nil {
+			return nil, fmt.Errorf("blogs.yaml: meta.author: %w", err)
+		}
+		blogData.siteAuthor = &authors[0]
 	}
 
-	// Load individual posts
-	postFiles, err := filepath.Glob("blogs/posts/*.yaml")
-	if err != nil {
-		return nil, fmt.Errorf("failed to glob posts: %w", err)
+	// Load individual posts, in either YAML or markdown with front matter
+	var postFiles []string
+	for _, ext := range postExtensions {
+		matches, err := filepath.Glob(filepath.Join(dir, "posts", "*"+ext))
+		if err != nil {
+			return nil, fmt.Errorf("failed to glob posts: %w", err)
+		}
+		postFiles = append(postFiles, matches...)
 	}
 
+	slugFiles := make(map[string]string)
 	for _, postFile := range postFiles {
 		postData, err := os.ReadFile(postFile)
 		if err != nil {
@@ -352,22 +504,47 @@ func loadBlogData() (*BlogData, error) {
 			continue
 		}
 
-		var postYAML BlogPostYAML
-		if err := yaml.Unmarshal(postData, &postYAML); err != nil {
+		postYAML, err := parsePostFile(postFile, postData)
+		if err != nil {
 			logger.Warnf("failed to unmarshal post file %s: %v", postFile, err)
 			continue
 		}
 
-		if !postYAML.Published {
-			continue
+		if existing, ok := slugFiles[postYAML.Slug]; ok {
+			return nil, fmt.Errorf("duplicate post slug %q in %s and %s", postYAML.Slug, existing, postFile)
+		}
+		slugFiles[postYAML.Slug] = postFile
+
+		// Drafts are kept so they can be previewed; they may not have a date yet
+		var publishDate time.Time
+		if postYAML.Published || postYAML.PublishDate != "" {
+			publishDate, err = utils.ParseSiteDate(postYAML.PublishDate)
+			if err != nil {
+				return nil, fmt.Errorf("%s: publish_date: %w", postFile, err)
+			}
 		}
-
-		publishDate, _ := time.Parse("2006-01-02", postYAML.PublishDate)
 		var updatedDate *time.Time
 		if postYAML.UpdatedDate != nil && *postYAML.UpdatedDate != "" {
-			if parsed, err := time.Parse("2006-01-02", *postYAML.UpdatedDate); err == nil {
-				updatedDate = &parsed
+			parsed, err := utils.ParseSiteDate(*postYAML.UpdatedDate)
+			if err != nil {
+				return nil, fmt.Errorf("%s: updated_date: %w", postFile, err)
 			}
+			updatedDate = &parsed
+		}
+		authorIDs, err := postYAML.authorIDs()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", postFile, err)
+		}
+		authors, err := resolveAuthors(blogData.Authors, authorIDs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", postFile, err)
+		}
+		if len(authors) == 0 && blogData.siteAuthor != nil {
+			authors = []Author{*blogData.siteAuthor}
+		}
+		lang, err := postLanguage(blogData.Languages, postYAML.Lang)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", postFile, err)
 		}
 
 		post := BlogPost{
@@ -376,19 +553,48 @@ func loadBlogData() (*BlogData, error) {
 			Slug:        postYAML.Slug,
 			Excerpt:     postYAML.Excerpt,
 			Content:     postYAML.Content,
-			Author:      postYAML.Author,
+			Authors:     authors,
 			PublishDate: publishDate,
 			UpdatedDate: updatedDate,
 			Category:    postYAML.Category,
 			Tags:        postYAML.Tags,
+			Series:      postYAML.Series,
+			Related:     postYAML.Related,
+			Aliases:     postYAML.Aliases,
 			ReadingTime: postYAML.ReadingTime,
 			Featured:    postYAML.Featured,
 			Published:   postYAML.Published,
+			TOC:         postYAML.TOC,
 			Meta:        postYAML.Meta,
+
+			Lang:          lang,
+			TranslationOf: postYAML.TranslationOf,
+			prefix:        languagePrefix(blogData.Languages, lang),
+		}
+		if err := utils.CheckShortcodes(post.Content, filepath.Join(dir, "snippets")); err != nil {
+			return nil, fmt.Errorf("%s: %w", postFile, err)
 		}
+		post.deriveText()
 		blogData.Posts = append(blogData.Posts, post)
 	}
 
+	if err := validateSeries(blogData.Posts, slugFiles); err != nil {
+		return nil, err
+	}
+	if err := validateRelated(blogData.Posts, slugFiles); err != nil {
+		return nil, err
+	}
+	if err := validateTranslations(blogData.Posts, slugFiles); err != nil {
+		return nil, err
+	}
+	blogData.redirectRules, err = readRedirects(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateRedirects(blogData.Posts, blogData.redirectRules, slugFiles); err != nil {
+		return nil, err
+	}
+
 	// Sort posts by publish date (newest first)
 	sort.Slice(blogData.Posts, func(i, j int) bool {
 		return blogData.Posts[i].PublishDate.After(blogData.Posts[j].PublishDate)
@@ -397,6 +603,104 @@ func loadBlogData() (*BlogData, error) {
 	return blogData, nil
 }
 
+// visibleAt returns a copy of b holding only the published posts whose publish
+// date has passed at now, with lookup and search indexes built. Drafts and
+// scheduled posts are only reachable through PreviewBySlug. The copy records
+// when the next scheduled post goes live so the store knows when to rebuild it.
+// It holds the posts of every language; Language narrows it to one.
+func (b *BlogData) visibleAt(now time.Time) *BlogData {
+	view := *b
+	view.Posts = nil
+	view.nextRelease = time.Time{}
+	view.hidden = make(map[string]*BlogPost)
+
+	for i, post := range b.Posts {
+		if !post.Published {
+			view.hidden[post.Slug] = &b.Posts[i]
+			continue
+		}
+		if post.PublishDate.After(now) {
+			if view.nextRelease.IsZero() || post.PublishDate.Before(view.nextRelease) {
+				view.nextRelease = post.PublishDate
+			}
+			view.hidden[post.Slug] = &b.Posts[i]
+			continue
+		}
+		view.Posts = append(view.Posts, post)
+	}
+
+	view.buildTranslations()
+	view.buildIndexes()
+	view.buildSeriesIndex()
+	view.buildArchiveIndex()
+	view.buildRelated()
+	view.buildRedirects()
+	view.search = buildSearchIndex(view.Posts)
+	view.buildLanguageViews()
+
+	return &view
+}
+
+// expired reports whether a scheduled post has gone live since the view was built
+func (b *BlogData) expired(now time.Time) bool {
+	return !b.nextRelease.IsZero() && !now.Before(b.nextRelease)
+}
+
+// buildIndexes populates the slug, tag and category lookups. Posts must already
+// be sorted so the indexed lists keep the listing order.
+func (b *BlogData) buildIndexes() {
+	b.postsBySlug = make(map[string]*BlogPost, len(b.Posts))
+	b.postsByTag = make(map[string][]BlogPost)
+	b.postsByCategory = make(map[string][]BlogPost)
+	b.postsByAuthor = make(map[string][]BlogPost)
+
+	for i := range b.Posts {
+		post := &b.Posts[i]
+		b.postsBySlug[post.Slug] = post
+		for _, tag := range post.Tags {
+			b.postsByTag[tag] = append(b.postsByTag[tag], *post)
+		}
+		if post.Category != "" {
+			b.postsByCategory[post.Category] = append(b.postsByCategory[post.Category], *post)
+		}
+		for _, author := range post.Authors {
+			if author.ID != "" {
+				b.postsByAuthor[author.ID] = append(b.postsByAuthor[author.ID], *post)
+			}
+		}
+	}
+}
+
+// PostBySlug returns the published post with the given slug, or nil
+func (b *BlogData) PostBySlug(slug string) *BlogPost {
+	if post, ok := b.postsBySlug[slug]; ok && post.Published {
+		return post
+	}
+	return nil
+}
+
+// PreviewBySlug returns the post with the given slug whether or not it is
+// visible yet. It reports whether the post is a draft or scheduled post.
+func (b *BlogData) PreviewBySlug(slug string) (*BlogPost, bool) {
+	if post := b.PostBySlug(slug); post != nil {
+		return post, false
+	}
+	if post, ok := b.hidden[slug]; ok {
+		return post, true
+	}
+	return nil, false
+}
+
+// PostsByTag returns the posts carrying tag, newest first
+func (b *BlogData) PostsByTag(tag string) []BlogPost {
+	return b.postsByTag[tag]
+}
+
+// PostsByCategory returns the posts in the category with the given slug
+func (b *BlogData) PostsByCategory(category string) []BlogPost {
+	return b.postsByCategory[category]
+}
+
 func getAllTags(posts []BlogPost) []string {
 	tagSet := make(map[string]struct{})
 	for _, post := range posts {
@@ -414,10 +718,18 @@ func getAllTags(posts []BlogPost) []string {
 	return tags
 }
 
-func filterPosts(posts []BlogPost, tag, category string) []BlogPost {
-	var filtered []BlogPost
+func filterPosts(blogData *BlogData, tag, category string) []BlogPost {
+	// Start from the narrowest index available
+	candidates := blogData.Posts
+	switch {
+	case tag != "":
+		candidates = blogData.PostsByTag(tag)
+	case category != "":
+		candidates = blogData.PostsByCategory(category)
+	}
 
-	for _, post := range posts {
+	var filtered []BlogPost
+	for _, post := range candidates {
 		if !post.Published {
 			continue
 		}
@@ -426,19 +738,6 @@ func filterPosts(posts []BlogPost, tag, category string) []BlogPost {
 			continue
 		}
 
-		if tag != "" {
-			hasTag := false
-			for _, postTag := range post.Tags {
-				if postTag == tag {
-					hasTag = true
-					break
-				}
-			}
-			if !hasTag {
-				continue
-			}
-		}
-
 		filtered = append(filtered, post)
 	}
 
@@ -467,18 +766,175 @@ func getRecentPosts(posts []BlogPost, limit int) []BlogPost {
 	return recent
 }
 
-func getRelatedPosts(posts []BlogPost, currentPost BlogPost, limit int) []BlogPost {
-	var related []BlogPost
-	count := 0
+// archiveSort returns the field and order listings are sorted by: the archive
+// config, overridden by valid ?sort= and ?order= query parameters
+func archiveSort(r *http.Request, archive ArchiveConfig) (string, string) {
+	sortBy, sortOrder := archive.SortBy, archive.SortOrder
+	if value := r.URL.Query().Get("sort"); validSortBy(value) {
+		sortBy = value
+	}
+	if value := r.URL.Query().Get("order"); validSortOrder(value) {
+		sortOrder = value
+	}
+	return sortBy, sortOrder
+}
 
-	for _, post := range posts {
-		if post.Published && post.ID != currentPost.ID && post.Category == currentPost.Category && count < limit {
-			related = append(related, post)
-			count++
+// sortPosts returns a copy of posts ordered by the given field, keeping the
+// input order between equal posts
+func sortPosts(posts []BlogPost, sortBy, sortOrder string) []BlogPost {
+	sorted := make([]BlogPost, len(posts))
+	copy(sorted, posts)
+
+	less := func(a, b BlogPost) bool { return a.PublishDate.Before(b.PublishDate) }
+	switch sortBy {
+	case SortByUpdatedDate:
+		less = func(a, b BlogPost) bool { return a.lastModified().Before(b.lastModified()) }
+	case SortByTitle:
+		less = func(a, b BlogPost) bool { return strings.ToLower(a.Title) < strings.ToLower(b.Title) }
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sortOrder == "desc" {
+			return less(sorted[j], sorted[i])
 		}
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// ListingURL returns the URL of the current listing with the given page and
+// sort, keeping the selected tag, category and search query
+func (d BlogPageData) ListingURL(page int, sortBy, sortOrder string) string {
+	path := d.Prefix + "/writings"
+	params := url.Values{}
+	switch {
+	case d.Query != "":
+		path = d.Prefix + "/writings/search"
+		params.Set("q", d.Query)
+	case d.ListingPath != "":
+		// The landing page path already names the tag or category
+		path = d.ListingPath
+	}
+	if d.SelectedTag != "" && d.ListingPath == "" {
+		params.Set("tag", d.SelectedTag)
+	}
+	if d.SelectedCategory != "" && d.ListingPath == "" {
+		params.Set("category", d.SelectedCategory)
+	}
+	switch {
+	case d.Query != "":
+		// Search results are ranked by relevance unless a sort was chosen,
+		// even the archive's default one
+		if sortBy != "" {
+			params.Set("sort", sortBy)
+			params.Set("order", sortOrder)
+		}
+	case sortBy != d.Archive.SortBy || sortOrder != d.Archive.SortOrder:
+		params.Set("sort", sortBy)
+		params.Set("order", sortOrder)
+	}
+	if page > 1 {
+		params.Set("page", strconv.Itoa(page))
 	}
 
-	return related
+	if len(params) == 0 {
+		return path
+	}
+	return path + "?" + params.Encode()
+}
+
+// StructuredData returns the schema.org JSON-LD describing a post, series or
+// author page, or nil for other listings. html/template JSON-encodes it inside the
+// application/ld+json script.
+func (d BlogPageData) StructuredData() map[string]any {
+	site := d.siteURL()
+
+	if d.Post != nil {
+		post := d.Post
+		data := map[string]any{
+			"@context":      "https://schema.org",
+			"@type":         "BlogPosting",
+			"headline":      post.Title,
+			"url":           site + post.URL(),
+			"datePublished": post.PublishDate.Format(time.RFC3339),
+			"dateModified":  post.lastModified().Format(time.RFC3339),
+		}
+		if len(post.Authors) > 0 {
+			authors := make([]map[string]any, len(post.Authors))
+			for i, author := range post.Authors {
+				authors[i] = author.structuredData(site)
+			}
+			data["author"] = authors
+		}
+		if post.Excerpt != "" {
+			data["description"] = post.Excerpt
+		}
+		if post.Lang != "" {
+			data["inLanguage"] = post.Lang
+		}
+		if len(post.Tags) > 0 {
+			data["keywords"] = strings.Join(post.Tags, ", ")
+		}
+		if post.Series != nil {
+			data["position"] = post.Series.Part
+			data["isPartOf"] = map[string]any{
+				"@type": "CreativeWorkSeries",
+				"name":  post.Series.Name,
+				"url":   site + d.Prefix + post.Series.URL(),
+			}
+		}
+		return data
+	}
+
+	if d.Series != nil {
+		parts := make([]map[string]any, len(d.Series.Parts))
+		for i, part := range d.Series.Parts {
+			parts[i] = map[string]any{
+				"@type":    "BlogPosting",
+				"headline": part.Title,
+				"url":      site + part.URL(),
+				"position": part.Series.Part,
+			}
+		}
+		return map[string]any{
+			"@context": "https://schema.org",
+			"@type":    "CreativeWorkSeries",
+			"name":     d.Series.Name,
+			"url":      site + d.Prefix + d.Series.URL(),
+			"hasPart":  parts,
+		}
+	}
+
+	if d.Author != nil {
+		return map[string]any{
+			"@context":   "https://schema.org",
+			"@type":      "ProfilePage",
+			"url":        site + d.Prefix + d.Author.URL(),
+			"mainEntity": d.Author.structuredData(site),
+		}
+	}
+
+	return nil
+}
+
+// paginatePosts returns the posts on the requested page, clamping page to the
+// last page, along with the total number of pages
+func paginatePosts(posts []BlogPost, page, postsPerPage int) ([]BlogPost, int, int) {
+	totalPages := (len(posts) + postsPerPage - 1) / postsPerPage
+	if page > totalPages && totalPages > 0 {
+		page = totalPages
+	}
+
+	start := (page - 1) * postsPerPage
+	end := start + postsPerPage
+	if end > len(posts) {
+		end = len(posts)
+	}
+
+	if start >= len(posts) {
+		return nil, page, totalPages
+	}
+	return posts[start:end], page, totalPages
 }
 
 func parseIntParam(r *http.Request, param string, defaultValue int) int {
@@ -509,9 +965,20 @@ func (p BlogPost) FormatDate() string {
 	return p.PublishDate.Format("January 2, 2006")
 }
 
-// Helper function to get reading time text
+// Helper function to get reading time text, in the post's language
 func (p BlogPost) ReadingTimeText() string {
-	return fmt.Sprintf("%d min read", p.ReadingTime)
+	return p.ReadingTimeIn(p.Lang)
+}
+
+// ShowTOC reports whether the post page shows a table of contents. Posts opt
+// out with toc: false.
+func (p BlogPost) ShowTOC() bool {
+	return p.TOC == nil || *p.TOC
+}
+
+// ReadingTimeIn formats the reading time for the given language tag
+func (p BlogPost) ReadingTimeIn(lang string) string {
+	return utils.ReadingTimeText(p.ReadingTime, lang)
 }
 
 // Helper function to get excerpt with fallback
@@ -519,10 +986,21 @@ func (p BlogPost) GetExcerpt() string {
 	if p.Excerpt != "" {
 		return p.Excerpt
 	}
-	// Simple truncation logic if no excerpt is provided
-	words := strings.Fields(p.Content)
-	if len(words) > 30 {
-		return strings.Join(words[:30], " ") + "..."
+	return utils.Excerpt(p.Content, excerptWords)
+}
+
+// excerptWords is the length of excerpts derived from post content
+const excerptWords = 30
+
+// deriveText fills in the word count, and the reading time and excerpt when
+// the post does not set them
+func (p *BlogPost) deriveText() {
+	prose, code := utils.WordCount(p.Content)
+	p.WordCount = prose + code
+	if p.ReadingTime == 0 {
+		p.ReadingTime = utils.ReadingMinutes(prose, code)
+	}
+	if p.Excerpt == "" {
+		p.Excerpt = utils.Excerpt(p.Content, excerptWords)
 	}
-	return p.Content
 }
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/logger"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// Comment moderation states
+const (
+	CommentPending  = "pending"
+	CommentApproved = "approved"
+	CommentRejected = "rejected"
+)
+
+// commentAuthorMax is the longest name a commenter may give, in characters
+const commentAuthorMax = 80
+
+// Comment is a reader's comment on a post, or a reply to another comment
+type Comment struct {
+	ID      string    `json:"id"`
+	Post    string    `json:"post"` // slug of the post
+	Parent  string    `json:"parent,omitempty"`
+	Author  string    `json:"author"`
+	Body    string    `json:"body"` // markdown, as submitted
+	Created time.Time `json:"created"`
+	Status  string    `json:"status"`
+}
+
+// HTML renders the comment body with the restricted comment markdown
+func (c Comment) HTML() template.HTML {
+	return template.HTML(utils.CommentMarkdownToHTML(c.Body))
+}
+
+// ReplyForm returns an empty form replying to the comment
+func (c Comment) ReplyForm() CommentForm {
+	return newCommentForm(c.Post, c.ID)
+}
+
+// CommentThread is an approved comment with its approved replies
+type CommentThread struct {
+	Comment
+	Replies []CommentThread
+}
+
+// commentRecord is one line of the comment log: a new comment, or a change
+// to the status of an earlier one
+type commentRecord struct {
+	Op      string    `json:"op"` // "add" or "status"
+	Comment *Comment  `json:"comment,omitempty"`
+	ID      string    `json:"id,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// CommentStore keeps comments in an append-only log, comments.jsonl, which
+// is replayed when the store is opened. Nothing is rewritten in place, so a
+// crash can at worst lose the line being written.
+type CommentStore struct {
+	mu       sync.RWMutex
+	path     string
+	moderate bool
+	comments map[string]*Comment
+	order    []string // comment ids in the order they were submitted
+}
+
+// OpenCommentStore opens the comment log in dir, creating the directory if
+// needed. When moderate is set new comments wait for approval.
+func OpenCommentStore(dir string, moderate bool) (*CommentStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create comment directory: %w", err)
+	}
+	s := &CommentStore{
+		path:     filepath.Join(dir, "comments.jsonl"),
+		moderate: moderate,
+		comments: make(map[string]*Comment),
+	}
+
+	err := replayJSONLines(s.path, func(line []byte) error {
+		var record commentRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		return s.apply(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// apply replays one record of the log
+func (s *CommentStore) apply(record commentRecord) error {
+	switch record.Op {
+	case "add":
+		if record.Comment == nil || record.Comment.ID == "" {
+			return errors.New("comment without an id")
+		}
+		c := *record.Comment
+		if _, ok := s.comments[c.ID]; !ok {
+			s.order = append(s.order, c.ID)
+		}
+		s.comments[c.ID] = &c
+	case "status":
+		c, ok := s.comments[record.ID]
+		if !ok {
+			return fmt.Errorf("status of unknown comment %q", record.ID)
+		}
+		c.Status = record.Status
+	default:
+		return fmt.Errorf("unknown op %q", record.Op)
+	}
+	return nil
+}
+
+// append writes a record to the end of the log and applies it
+func (s *CommentStore) append(record commentRecord) error {
+	if err := appendJSONLine(s.path, record); err != nil {
+		return err
+	}
+	return s.apply(record)
+}
+
+// Add stores a new comment, giving it an id, a creation time and a status:
+// pending when comments are moderated, otherwise approved
+func (s *CommentStore) Add(c Comment) (Comment, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return Comment{}, err
+	}
+	c.ID = hex.EncodeToString(id)
+	c.Created = time.Now().UTC()
+	c.Status = CommentApproved
+	if s.moderate {
+		c.Status = CommentPending
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.append(commentRecord{Op: "add", Comment: &c, At: c.Created}); err != nil {
+		return Comment{}, fmt.Errorf("failed to save comment: %w", err)
+	}
+	return c, nil
+}
+
+// SetStatus approves or rejects a comment
+func (s *CommentStore) SetStatus(id, status string) (Comment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.comments[id]; !ok {
+		return Comment{}, fmt.Errorf("no comment with id %q", id)
+	}
+	if err := s.append(commentRecord{Op: "status", ID: id, Status: status, At: time.Now().UTC()}); err != nil {
+		return Comment{}, fmt.Errorf("failed to save comment status: %w", err)
+	}
+	return *s.comments[id], nil
+}
+
+// Get returns the comment with the given id
+func (s *CommentStore) Get(id string) (Comment, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.comments[id]
+	if !ok {
+		return Comment{}, false
+	}
+	return *c, true
+}
+
+// Approved returns the approved comments on a post as threads, oldest first,
+// and how many there are. Replies to a comment that is not approved are
+// left out with it.
+func (s *CommentStore) Approved(slug string) ([]CommentThread, int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	children := make(map[string][]Comment)
+	for _, id := range s.order {
+		c := s.comments[id]
+		if c.Post == slug && c.Status == CommentApproved {
+			children[c.Parent] = append(children[c.Parent], *c)
+		}
+	}
+
+	count := 0
+	var thread func(parent string) []CommentThread
+	thread = func(parent string) []CommentThread {
+		var threads []CommentThread
+		for _, c := range children[parent] {
+			count++
+			threads = append(threads, CommentThread{Comment: c, Replies: thread(c.ID)})
+		}
+		return threads
+	}
+	return thread(""), count
+}
+
+// Pending returns the comments waiting for moderation, oldest first
+func (s *CommentStore) Pending() []Comment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var pending []Comment
+	for _, id := range s.order {
+		if c := s.comments[id]; c.Status == CommentPending {
+			pending = append(pending, *c)
+		}
+	}
+	return pending
+}
+
+var (
+	commentStore     *CommentStore // nil while comments are disabled
+	commentMaxLength = 5000
+)
+
+// SetCommentStore sets where comments are kept and the longest comment
+// accepted. Comments are disabled while the store is nil.
+func SetCommentStore(store *CommentStore, maxLength int) {
+	commentStore = store
+	if maxLength > 0 {
+		commentMaxLength = maxLength
+	}
+}
+
+// CommentForm is a comment form, as submitted when it is shown again with
+// the reason it was rejected
+type CommentForm struct {
+	Slug      string
+	Parent    string
+	Author    string
+	Body      string
+	Error     string
+	MaxLength int
+	Moderated bool // new comments wait for approval
+}
+
+func newCommentForm(slug, parent string) CommentForm {
+	return CommentForm{
+		Slug:      slug,
+		Parent:    parent,
+		MaxLength: commentMaxLength,
+		Moderated: commentStore != nil && commentStore.moderate,
+	}
+}
+
+// CommentsData is the list of approved comments on a post
+type CommentsData struct {
+	Slug    string
+	Threads []CommentThread
+	Count   int
+}
+
+// CommentResultData is what a reader sees after submitting a comment
+type CommentResultData struct {
+	Author    string
+	Moderated bool
+}
+
+// validate checks a submitted form, returning the problem to show the reader
+func (f CommentForm) validate(store *CommentStore) string {
+	switch {
+	case f.Author == "":
+		return "Please give a name."
+	case utf8.RuneCountInString(f.Author) > commentAuthorMax:
+		return fmt.Sprintf("Names are limited to %d characters.", commentAuthorMax)
+	case f.Body == "":
+		return "The comment is empty."
+	case utf8.RuneCountInString(f.Body) > commentMaxLength:
+		return fmt.Sprintf("Comments are limited to %d characters.", commentMaxLength)
+	}
+	if f.Parent != "" {
+		parent, ok := store.Get(f.Parent)
+		if !ok || parent.Post != f.Slug || parent.Status != CommentApproved {
+			return "The comment you are replying to is not available."
+		}
+	}
+	return ""
+}
+
+// blogTemplates parses the blog templates, for handlers that render a fragment
+func blogTemplates() (*template.Template, error) {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "blog", "*.html"))
+}
+
+// BlogCommentsHandler serves the approved comments on a post as a fragment,
+// which the post page loads once the reader scrolls to it
+func BlogCommentsHandler(w http.ResponseWriter, r *http.Request) error {
+	if commentStore == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+	post := blogData.PostBySlug(mux.Vars(r)["slug"])
+	if post == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	templates, err := blogTemplates()
+	if err != nil {
+		return err
+	}
+	threads, count := commentStore.Approved(post.Slug)
+	w.Header().Set("X-Robots-Tag", "noindex")
+	return templates.ExecuteTemplate(w, "comments-list", CommentsData{Slug: post.Slug, Threads: threads, Count: count})
+}
+
+// BlogCommentSubmitHandler accepts a comment or reply on a post. HTMX
+// requests get the form back with the problem when the comment is rejected,
+// or a thank-you note in its place; plain form posts are sent back to the
+// post.
+func BlogCommentSubmitHandler(w http.ResponseWriter, r *http.Request) error {
+	if commentStore == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+	post := blogData.PostBySlug(mux.Vars(r)["slug"])
+	if post == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return nil
+	}
+	form := newCommentForm(post.Slug, r.PostForm.Get("parent"))
+	form.Author = strings.TrimSpace(r.PostForm.Get("author"))
+	form.Body = strings.TrimSpace(strings.ReplaceAll(r.PostForm.Get("body"), "\r\n", "\n"))
+	htmx := r.Header.Get("HX-Request") == "true"
+
+	templates, err := blogTemplates()
+	if err != nil {
+		return err
+	}
+
+	// The website field is hidden from people; only bots fill it in. They
+	// are thanked as usual so they learn nothing.
+	if r.PostForm.Get("website") != "" {
+		logger.Debugf("Dropped a comment on %s that filled in the honeypot", post.Slug)
+		if !htmx {
+			http.Redirect(w, r, post.URL()+"#comments", http.StatusSeeOther)
+			return nil
+		}
+		return templates.ExecuteTemplate(w, "comment-result", CommentResultData{Author: form.Author, Moderated: true})
+	}
+
+	if form.Error = form.validate(commentStore); form.Error != "" {
+		if !htmx {
+			http.Error(w, form.Error, http.StatusBadRequest)
+			return nil
+		}
+		// htmx only swaps successful responses, so the form comes back as 200
+		return templates.ExecuteTemplate(w, "comment-form", form)
+	}
+
+	comment, err := commentStore.Add(Comment{
+		Post:   post.Slug,
+		Parent: form.Parent,
+		Author: form.Author,
+		Body:   form.Body,
+	})
+	if err != nil {
+		return err
+	}
+	logger.Infof("New %s comment %s on %s", comment.Status, comment.ID, post.Slug)
+
+	if !htmx {
+		http.Redirect(w, r, post.URL()+"#comments", http.StatusSeeOther)
+		return nil
+	}
+	if comment.Status == CommentApproved {
+		// Reload the comment list so the new comment shows
+		w.Header().Set("HX-Trigger", "comments-changed")
+	}
+	return templates.ExecuteTemplate(w, "comment-result", CommentResultData{
+		Author:    comment.Author,
+		Moderated: comment.Status == CommentPending,
+	})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func useTestComments(t *testing.T, moderate bool) (*CommentStore, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store, err := OpenCommentStore(dir, moderate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, previousMax := commentStore, commentMaxLength
+	SetCommentStore(store, 200)
+	t.Cleanup(func() { commentStore, commentMaxLength = previous, previousMax })
+	return store, dir
+}
+
+func postComment(t *testing.T, slug string, form url.Values, htmx bool) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/writings/"+slug+"/comments", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if htmx {
+		req.Header.Set("HX-Request", "true")
+	}
+	req = mux.SetURLVars(req, map[string]string{"slug": slug})
+	rr := httptest.NewRecorder()
+	if err := BlogCommentSubmitHandler(rr, req); err != nil {
+		t.Fatalf("BlogCommentSubmitHandler returned an error: %v", err)
+	}
+	return rr
+}
+
+func TestCommentStore(t *testing.T) {
+	store, dir := useTestComments(t, true)
+
+	first, err := store.Add(Comment{Post: "one", Author: "Ada", Body: "First."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != CommentPending || first.ID == "" {
+		t.Fatalf("Expected a pending comment with an id, got %+v", first)
+	}
+	if threads, _ := store.Approved("one"); len(threads) != 0 {
+		t.Errorf("Expected pending comments to be hidden, got %+v", threads)
+	}
+
+	reply, _ := store.Add(Comment{Post: "one", Parent: first.ID, Author: "Bob", Body: "Reply."})
+	rejected, _ := store.Add(Comment{Post: "one", Author: "Spam", Body: "Buy now."})
+	for _, id := range []string{first.ID, reply.ID} {
+		if _, err := store.SetStatus(id, CommentApproved); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.SetStatus(rejected.ID, CommentRejected)
+
+	// The log is replayed when the store is opened again
+	reopened, err := OpenCommentStore(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	threads, count := reopened.Approved("one")
+	if count != 2 || len(threads) != 1 || threads[0].ID != first.ID || len(threads[0].Replies) != 1 || threads[0].Replies[0].ID != reply.ID {
+		t.Fatalf("Expected the reply threaded under the first comment, got %d comments: %+v", count, threads)
+	}
+	if pending := reopened.Pending(); len(pending) != 0 {
+		t.Errorf("Expected nothing pending, got %+v", pending)
+	}
+
+	// Rejecting a comment hides its replies too
+	reopened.SetStatus(first.ID, CommentRejected)
+	if _, count := reopened.Approved("one"); count != 0 {
+		t.Errorf("Expected the reply to be hidden with its parent, got %d comments", count)
+	}
+}
+
+func TestBlogCommentSubmitHandler(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01"),
+	})
+	loadTestBlog(t, dir)
+	store, _ := useTestComments(t, true)
+	pending, _ := store.Add(Comment{Post: "one", Author: "Ada", Body: "Waiting."})
+
+	tests := []struct {
+		name    string
+		form    url.Values
+		want    string
+		created bool
+	}{
+		{"no name", url.Values{"body": {"Hi"}}, "Please give a name.", false},
+		{"empty body", url.Values{"author": {"Bob"}, "body": {"  "}}, "The comment is empty.", false},
+		{"too long", url.Values{"author": {"Bob"}, "body": {strings.Repeat("a", 201)}}, "limited to 200 characters", false},
+		{"reply to pending", url.Values{"author": {"Bob"}, "body": {"Hi"}, "parent": {pending.ID}}, "is not available", false},
+		{"honeypot", url.Values{"author": {"Bot"}, "body": {"Hi"}, "website": {"http://spam"}}, "once it has been read", false},
+		{"accepted", url.Values{"author": {"Bob"}, "body": {"Hi <script>x</script>"}}, "thanks, Bob", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(store.Pending())
+			rr := postComment(t, "one", tt.form, true)
+			if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), tt.want) {
+				t.Errorf("Expected status 200 containing %q, got %d:\n%s", tt.want, rr.Code, rr.Body.String())
+			}
+			if created := len(store.Pending()) > before; created != tt.created {
+				t.Errorf("Expected a comment to be stored: %v, got %v", tt.created, created)
+			}
+		})
+	}
+
+	rr := postComment(t, "one", url.Values{"author": {"Eve"}, "body": {"No script."}}, false)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/writings/one#comments" {
+		t.Errorf("Expected a plain form post to be sent back to the post, got %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	if rr := postComment(t, "missing", url.Values{"author": {"Eve"}, "body": {"Hi"}}, true); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown post, got %d", rr.Code)
+	}
+}
+
+func TestAdminCommentHandlers(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01"),
+	})
+	loadTestBlog(t, dir)
+	store, _ := useTestComments(t, true)
+	comment, _ := store.Add(Comment{Post: "one", Author: "Ada", Body: "Hello *there*."})
+	t.Cleanup(func() { SetAdminToken("") })
+
+	action := func(password string, htmx bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/admin/comments/"+comment.ID+"/approve", nil)
+		if password != "" {
+			req.SetBasicAuth("admin", password)
+		}
+		if htmx {
+			req.Header.Set("HX-Request", "true")
+		}
+		req = mux.SetURLVars(req, map[string]string{"id": comment.ID, "action": "approve"})
+		rr := httptest.NewRecorder()
+		if err := AdminCommentActionHandler(rr, req); err != nil {
+			t.Fatalf("AdminCommentActionHandler returned an error: %v", err)
+		}
+		return rr
+	}
+
+	if rr := action("secret", true); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 while no admin token is set, got %d", rr.Code)
+	}
+	SetAdminToken("secret")
+	if rr := action("wrong", true); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a wrong password, got %d", rr.Code)
+	}
+	if rr := action("secret", false); rr.Code != http.StatusForbidden {
+		t.Errorf("Expected 403 without the HX-Request header, got %d", rr.Code)
+	}
+
+	req := httptest.NewRequest("GET", "/admin/comments", nil)
+	req.SetBasicAuth("admin", "secret")
+	rr := httptest.NewRecorder()
+	if err := AdminCommentsHandler(rr, req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rr.Body.String(), "/admin/comments/"+comment.ID+"/approve") {
+		t.Errorf("Expected the pending comment in the moderation queue:\n%s", rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `<link rel="canonical" href="https://example.com/admin/comments">`) {
+		t.Error("Expected the moderation queue's canonical URL on the configured site")
+	}
+
+	if rr := action("secret", true); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "approved") {
+		t.Fatalf("Expected the comment to be approved, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+
+	// Approved comments show in the list loaded by the post page
+	req = mux.SetURLVars(httptest.NewRequest("GET", "/writings/one/comments", nil), map[string]string{"slug": "one"})
+	rr = httptest.NewRecorder()
+	if err := BlogCommentsHandler(rr, req); err != nil {
+		t.Fatal(err)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "Hello <em>there</em>.") || !strings.Contains(body, "1 comment<") {
+		t.Errorf("Expected the approved comment in the list:\n%s", body)
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/thinkingojha/go-htmx/internal/logger"
+)
+
+// reloadDebounce coalesces the burst of events editors emit when saving a file
+const reloadDebounce = 250 * time.Millisecond
+
+// ContentStore holds the blog and experience content in memory. Each source is
+// loaded once and then replaced atomically whenever its files change on disk, so
+// handlers always read a complete, consistent snapshot.
+type ContentStore struct {
+	blogDir        string
+	experienceFile string
+
+	// blogSource holds everything read from disk, including scheduled posts;
+	// blog is the view of it that is currently visible
+	blogSource atomic.Pointer[BlogData]
+	blog       atomic.Pointer[BlogData]
+	experience atomic.Pointer[ExperienceData]
+
+	viewMu sync.Mutex
+
+	mu      sync.Mutex
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+}
+
+var (
+	contentStore     *ContentStore
+	contentStoreOnce sync.Once
+)
+
+// NewContentStore loads the blog from blogDir and the experience data from
+// experienceFile. A broken blog is an error; a broken experience file falls back
+// to the built-in data, matching the behavior of the about page.
+func NewContentStore(blogDir, experienceFile string) (*ContentStore, error) {
+	s := &ContentStore{
+		blogDir:        blogDir,
+		experienceFile: experienceFile,
+	}
+
+	if err := s.reloadBlog(); err != nil {
+		return nil, err
+	}
+	s.loadExperienceOrFallback()
+
+	return s, nil
+}
+
+// SetContentStore makes s the store used by the handlers
+func SetContentStore(s *ContentStore) {
+	contentStoreOnce.Do(func() {})
+	contentStore = s
+}
+
+// content returns the active store, lazily loading the default content paths
+// when none has been configured (e.g. in tests).
+func content() *ContentStore {
+	contentStoreOnce.Do(func() {
+		s := &ContentStore{blogDir: "blogs", experienceFile: "experience.yaml"}
+		if err := s.reloadBlog(); err != nil {
+			logger.Errorf("Failed to load blog data: %v", err)
+		}
+		s.loadExperienceOrFallback()
+		contentStore = s
+	})
+	return contentStore
+}
+
+// Blog returns the current blog snapshot. The returned data is shared and must
+// not be modified. Posts scheduled for the future are left out until their
+// publish date passes.
+func (s *ContentStore) Blog() (*BlogData, error) {
+	data := s.blog.Load()
+	if data == nil {
+		return nil, errors.New("blog data is not loaded")
+	}
+	if now := time.Now(); data.expired(now) {
+		data = s.refreshBlogView(data, now)
+	}
+	return data, nil
+}
+
+// refreshBlogView rebuilds the visible view once a scheduled post goes live
+func (s *ContentStore) refreshBlogView(stale *BlogData, now time.Time) *BlogData {
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+
+	// Another request may already have rebuilt it
+	if current := s.blog.Load(); current != stale {
+		return current
+	}
+
+	source := s.blogSource.Load()
+	if source == nil {
+		return stale
+	}
+	view := source.visibleAt(now)
+	s.blog.Store(view)
+	return view
+}
+
+// Experience returns the current experience snapshot
+func (s *ContentStore) Experience() ExperienceData {
+	if data := s.experience.Load(); data != nil {
+		return *data
+	}
+	return getFallbackExperienceData()
+}
+
+func (s *ContentStore) reloadBlog() error {
+	source, err := readBlogData(s.blogDir)
+	if err != nil {
+		return err
+	}
+
+	s.viewMu.Lock()
+	defer s.viewMu.Unlock()
+	s.blogSource.Store(source)
+	s.blog.Store(source.visibleAt(time.Now()))
+	return nil
+}
+
+func (s *ContentStore) reloadExperience() error {
+	data, err := loadExperienceFromYAML(s.experienceFile)
+	if err != nil {
+		return err
+	}
+	s.experience.Store(&data)
+	return nil
+}
+
+func (s *ContentStore) loadExperienceOrFallback() {
+	if err := s.reloadExperience(); err != nil {
+		logger.Warnf("Could not load %s, using fallback data: %v", s.experienceFile, err)
+		fallback := getFallbackExperienceData()
+		s.experience.Store(&fallback)
+	}
+}
+
+// Watch starts reloading content whenever files under the blog directory or the
+// experience file change. Failed reloads are logged and the last good snapshot
+// stays in place.
+func (s *ContentStore) Watch() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watcher != nil {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create content watcher: %w", err)
+	}
+
+	// Directories are watched rather than files so that editors which save by
+	// renaming a temp file over the original keep triggering reloads.
+	dirs := []string{s.blogDir, filepath.Join(s.blogDir, "posts"), filepath.Dir(s.experienceFile)}
+	if info, err := os.Stat(filepath.Join(s.blogDir, "snippets")); err == nil && info.IsDir() {
+		dirs = append(dirs, filepath.Join(s.blogDir, "snippets"))
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	s.watcher = watcher
+	s.done = make(chan struct{})
+	go s.watchLoop(watcher, s.done)
+
+	logger.Infof("Watching %s and %s for content changes", s.blogDir, s.experienceFile)
+	return nil
+}
+
+// Close stops watching for changes
+func (s *ContentStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watcher == nil {
+		return nil
+	}
+	close(s.done)
+	err := s.watcher.Close()
+	s.watcher = nil
+	return err
+}
+
+func (s *ContentStore) watchLoop(watcher *fsnotify.Watcher, done <-chan struct{}) {
+	var (
+		timer           *time.Timer
+		blogDirty       bool
+		experienceDirty bool
+		pending         = make(chan struct{}, 1)
+	)
+
+	experiencePath := filepath.Clean(s.experienceFile)
+
+	for {
+		select {
+		case <-done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+
+			switch {
+			case filepath.Clean(event.Name) == experiencePath:
+				experienceDirty = true
+			case isBlogContentFile(s.blogDir, event.Name):
+				blogDirty = true
+			default:
+				continue
+			}
+
+			if timer == nil {
+				timer = time.AfterFunc(reloadDebounce, func() {
+					select {
+					case pending <- struct{}{}:
+					default:
+					}
+				})
+			} else {
+				timer.Reset(reloadDebounce)
+			}
+
+		case <-pending:
+			if blogDirty {
+				if err := s.reloadBlog(); err != nil {
+					logger.Errorf("Blog reload failed, keeping previous content: %v", err)
+				} else {
+					logger.Infof("Reloaded blog content from %s", s.blogDir)
+				}
+				blogDirty = false
+			}
+			if experienceDirty {
+				if err := s.reloadExperience(); err != nil {
+					logger.Errorf("Experience reload failed, keeping previous content: %v", err)
+				} else {
+					logger.Infof("Reloaded experience data from %s", s.experienceFile)
+				}
+				experienceDirty = false
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Errorf("Content watcher error: %v", err)
+		}
+	}
+}
+
+// isBlogContentFile reports whether name is a file the blog loader reads
+func isBlogContentFile(blogDir, name string) bool {
+	dir := filepath.Clean(filepath.Dir(name))
+	if dir == filepath.Join(blogDir, "snippets") {
+		return filepath.Ext(name) == ".md"
+	}
+	if dir != filepath.Clean(blogDir) && dir != filepath.Join(blogDir, "posts") {
+		return false
+	}
+	return isPostFile(name)
+}
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testBlogsYAML = `title: "test blog"
+meta:
+  site_url: "https://example.com"
+categories:
+  - name: "Engineering"
+    slug: "engineering"
+`
+
+// writeTestBlog creates a blog directory with the given post files
+func writeTestBlog(t *testing.T, posts map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "posts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "blogs.yaml"), []byte(testBlogsYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range posts {
+		if err := os.WriteFile(filepath.Join(dir, "posts", name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// testPost returns a published post titled by its slug, followed by the given
+// YAML fields, one per line
+func testPost(slug, date string, fields ...string) string {
+	post := `id: "` + slug + `"
+title: "` + slug + `"
+slug: "` + slug + `"
+publish_date: "` + date + `"
+published: true
+`
+	for _, field := range fields {
+		post += field + "\n"
+	}
+	return post
+}
+
+// loadTestBlog loads the blog in dir and serves it from the handlers' content
+// store for the rest of the test
+func loadTestBlog(t *testing.T, dir string) *BlogData {
+	t.Helper()
+
+	blogData, err := loadBlogData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTestBlog(t, blogData)
+	return blogData
+}
+
+// useTestBlog serves blogData from the handlers' content store for the rest of
+// the test
+func useTestBlog(t *testing.T, blogData *BlogData) {
+	t.Helper()
+
+	previous := content()
+	store := &ContentStore{}
+	store.blog.Store(blogData)
+	SetContentStore(store)
+	t.Cleanup(func() { SetContentStore(previous) })
+}
+
+func TestContentStoreIndexes(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"first.yaml": `id: "first"
+title: "First"
+slug: "first"
+publish_date: "2024-01-01"
+category: "engineering"
+tags: ["go"]
+published: true
+`,
+		"second.yaml": `id: "second"
+title: "Second"
+slug: "second"
+publish_date: "2024-02-01"
+tags: ["go", "htmx"]
+published: true
+`,
+	})
+
+	store, err := NewContentStore(dir, filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatalf("NewContentStore returned an error: %v", err)
+	}
+
+	blogData, err := store.Blog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post := blogData.PostBySlug("second"); post == nil || post.Title != "Second" {
+		t.Errorf("PostBySlug(second) = %v, want post titled Second", post)
+	}
+	if got := len(blogData.PostsByTag("go")); got != 2 {
+		t.Errorf("PostsByTag(go) returned %d posts, want 2", got)
+	}
+	if got := blogData.PostsByTag("go")[0].Slug; got != "second" {
+		t.Errorf("PostsByTag(go) not sorted newest first, got %s first", got)
+	}
+	if got := len(blogData.PostsByCategory("engineering")); got != 1 {
+		t.Errorf("PostsByCategory(engineering) returned %d posts, want 1", got)
+	}
+
+	// A missing experience file falls back to the built-in data
+	if store.Experience().Title == "" {
+		t.Error("Expected fallback experience data")
+	}
+}
+
+func TestContentStoreKeepsLastGoodSnapshot(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"first.yaml": `id: "first"
+title: "First"
+slug: "first"
+publish_date: "2024-01-01"
+published: true
+`,
+	})
+
+	store, err := NewContentStore(dir, filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatalf("NewContentStore returned an error: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "blogs.yaml"), []byte("title: [broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.reloadBlog(); err == nil {
+		t.Fatal("Expected reload of a broken blogs.yaml to fail")
+	}
+
+	blogData, err := store.Blog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blogData.PostBySlug("first") == nil {
+		t.Error("Expected the previous snapshot to remain after a failed reload")
+	}
+}
+
+func TestLoadBlogDataMarkdownPosts(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"notes.md": `---
+id: "notes"
+title: "Notes"
+slug: "notes"
+publish_date: "2024-03-01"
+tags: ["go"]
+published: true
+---
+
+## Heading
+
+Body text.
+`,
+		"first.yaml": `id: "first"
+title: "First"
+slug: "first"
+publish_date: "2024-01-01"
+published: true
+`,
+	})
+
+	blogData, err := loadBlogData(dir)
+	if err != nil {
+		t.Fatalf("loadBlogData returned an error: %v", err)
+	}
+	if len(blogData.Posts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d", len(blogData.Posts))
+	}
+
+	post := blogData.PostBySlug("notes")
+	if post == nil {
+		t.Fatal("Expected markdown post to be loaded")
+	}
+	if want := "## Heading\n\nBody text.\n"; post.Content != want {
+		t.Errorf("Content = %q, want %q", post.Content, want)
+	}
+}
+
+func TestLoadBlogDataDuplicateSlug(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"post.md": "---\nid: \"post\"\nslug: \"post\"\npublish_date: \"2024-01-01\"\npublished: true\n---\nbody\n",
+		"post.yaml": `id: "post"
+slug: "post"
+publish_date: "2024-01-01"
+published: true
+`,
+	})
+
+	if _, err := loadBlogData(dir); err == nil || !strings.Contains(err.Error(), "duplicate post slug") {
+		t.Errorf("Expected duplicate slug error, got %v", err)
+	}
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		header  string
+		body    string
+		wantErr bool
+	}{
+		{name: "header and body", input: "---\ntitle: x\n---\nbody\n", header: "title: x\n", body: "body\n"},
+		{name: "windows line endings", input: "---\r\ntitle: x\r\n---\r\nbody", header: "title: x\n", body: "body"},
+		{name: "empty body", input: "---\ntitle: x\n---", header: "title: x\n", body: ""},
+		{name: "missing header", input: "# just markdown\n", wantErr: true},
+		{name: "unterminated header", input: "---\ntitle: x\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, body, err := splitFrontMatter([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(header) != tt.header || string(body) != tt.body {
+				t.Errorf("got header %q body %q, want %q and %q", header, body, tt.header, tt.body)
+			}
+		})
+	}
+}
+
+func TestContentStoreScheduledPosts(t *testing.T) {
+	release := time.Now().Add(time.Hour)
+	dir := writeTestBlog(t, map[string]string{
+		"live.yaml": `id: "live"
+slug: "live"
+publish_date: "2024-01-01"
+published: true
+`,
+		"scheduled.yaml": `id: "scheduled"
+slug: "scheduled"
+publish_date: "` + release.UTC().Format(time.RFC3339) + `"
+published: true
+`,
+	})
+
+	store, err := NewContentStore(dir, filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatalf("NewContentStore returned an error: %v", err)
+	}
+
+	blogData, err := store.Blog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blogData.PostBySlug("scheduled") != nil || len(blogData.Posts) != 1 {
+		t.Fatal("Expected scheduled post to be hidden before its publish date")
+	}
+	if len(blogData.Search("scheduled")) != 0 {
+		t.Error("Expected scheduled post to be missing from search")
+	}
+
+	// Once the publish date passes the view is rebuilt without a reload
+	later := store.refreshBlogView(blogData, release.Add(time.Second))
+	if later.PostBySlug("scheduled") == nil {
+		t.Error("Expected scheduled post to appear after its publish date")
+	}
+	if !later.nextRelease.IsZero() {
+		t.Errorf("Expected no further releases, got %v", later.nextRelease)
+	}
+}
+
+func TestLoadBlogDataInvalidDate(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"broken.yaml": `id: "broken"
+slug: "broken"
+publish_date: "31/01/2024"
+published: true
+`,
+	})
+
+	_, err := loadBlogData(dir)
+	if err == nil || !strings.Contains(err.Error(), "publish_date") {
+		t.Errorf("Expected publish_date error, got %v", err)
+	}
+}
+
+func TestSortPosts(t *testing.T) {
+	updated := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	posts := []BlogPost{
+		{Slug: "c", Title: "charlie", PublishDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{Slug: "b", Title: "Bravo", PublishDate: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{Slug: "a", Title: "alpha", PublishDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), UpdatedDate: &updated},
+	}
+
+	tests := []struct {
+		sortBy, order string
+		want          string
+	}{
+		{sortBy: SortByPublishDate, order: "desc", want: "cba"},
+		{sortBy: SortByPublishDate, order: "asc", want: "abc"},
+		{sortBy: SortByUpdatedDate, order: "desc", want: "acb"},
+		{sortBy: SortByTitle, order: "asc", want: "abc"},
+		{sortBy: SortByTitle, order: "desc", want: "cba"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sortBy+" "+tt.order, func(t *testing.T) {
+			var got string
+			for _, post := range sortPosts(posts, tt.sortBy, tt.order) {
+				got += post.Slug
+			}
+			if got != tt.want {
+				t.Errorf("sortPosts() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if posts[0].Slug != "c" {
+		t.Error("sortPosts reordered its input")
+	}
+}
+
+func TestArchiveSortOverrides(t *testing.T) {
+	archive := ArchiveConfig{PostsPerPage: 5, SortBy: SortByTitle, SortOrder: "asc"}
+
+	tests := []struct {
+		query     string
+		wantBy    string
+		wantOrder string
+	}{
+		{query: "", wantBy: SortByTitle, wantOrder: "asc"},
+		{query: "?sort=updated_date&order=desc", wantBy: SortByUpdatedDate, wantOrder: "desc"},
+		{query: "?sort=views&order=sideways", wantBy: SortByTitle, wantOrder: "asc"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/writings"+tt.query, nil)
+		by, order := archiveSort(req, archive)
+		if by != tt.wantBy || order != tt.wantOrder {
+			t.Errorf("archiveSort(%q) = %s %s, want %s %s", tt.query, by, order, tt.wantBy, tt.wantOrder)
+		}
+	}
+
+	page := BlogPageData{BlogData: BlogData{Archive: archive}, SelectedTag: "go"}
+	if got := page.ListingURL(2, SortByTitle, "asc"); got != "/writings?page=2&tag=go" {
+		t.Errorf("ListingURL with the default sort = %q", got)
+	}
+	if got := page.ListingURL(1, SortByPublishDate, "desc"); got != "/writings?order=desc&sort=publish_date&tag=go" {
+		t.Errorf("ListingURL with an override = %q", got)
+	}
+}
+
+func TestLoadBlogDataArchiveConfig(t *testing.T) {
+	dir := writeTestBlog(t, nil)
+
+	blogData, err := loadBlogData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blogData.Archive != (ArchiveConfig{PostsPerPage: 10, SortBy: SortByPublishDate, SortOrder: "desc"}) {
+		t.Errorf("Expected archive defaults, got %+v", blogData.Archive)
+	}
+
+	config := testBlogsYAML + "archive:\n  sort_by: \"views\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "blogs.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBlogData(dir); err == nil || !strings.Contains(err.Error(), "archive.sort_by") {
+		t.Errorf("Expected an archive.sort_by error, got %v", err)
+	}
+}
+
+func TestLoadBlogDataDerivedText(t *testing.T) {
+	prose := strings.Repeat("word ", 459)
+	code := strings.Repeat("x := y\n", 40)
+	dir := writeTestBlog(t, map[string]string{
+		"derived.md": "---\nid: \"derived\"\ntitle: \"Derived\"\nslug: \"derived\"\npublish_date: \"2024-01-01\"\npublished: true\n---\n" +
+			"## A heading\n\nThe **first** paragraph, with [a link](https://example.com) and `code`.\n\n" +
+			prose + "\n\n```go\n" + code + "```\n",
+		"authored.yaml": "id: \"authored\"\ntitle: \"Authored\"\nslug: \"authored\"\npublish_date: \"2024-01-02\"\npublished: true\nreading_time: 12\nexcerpt: \"Hand written.\"\ncontent: \"Short.\"\n",
+	})
+
+	blogData, err := loadBlogData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	derived := blogData.PostBySlug("derived")
+	if derived.Excerpt != "The first paragraph, with a link and code." {
+		t.Errorf("Excerpt = %q", derived.Excerpt)
+	}
+	// 2 heading words, 8 in the first paragraph, 459 prose and 120 code words:
+	// 469/230 + 120/80 minutes, rounded
+	if derived.WordCount != 589 {
+		t.Errorf("WordCount = %d, want 589", derived.WordCount)
+	}
+	if derived.ReadingTime != 4 {
+		t.Errorf("ReadingTime = %d, want 4", derived.ReadingTime)
+	}
+	if got := derived.ReadingTimeText(); got != "4 min read" {
+		t.Errorf("ReadingTimeText() = %q", got)
+	}
+
+	authored := blogData.PostBySlug("authored")
+	if authored.ReadingTime != 12 || authored.Excerpt != "Hand written." {
+		t.Errorf("Expected authored values to be kept, got %d and %q", authored.ReadingTime, authored.Excerpt)
+	}
+	if got := authored.ReadingTimeIn("fr-FR"); got != "12 minutes de lecture" {
+		t.Errorf("ReadingTimeIn(fr-FR) = %q", got)
+	}
+	if got := (BlogPost{ReadingTime: 1}).ReadingTimeIn("de"); got != "1 Minute Lesezeit" {
+		t.Errorf("ReadingTimeIn(de) = %q", got)
+	}
+}
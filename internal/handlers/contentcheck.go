@@ -0,0 +1,624 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thinkingojha/go-htmx/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// ContentIssue is a problem found by CheckContent. Line is zero when the
+// problem is with the file as a whole.
+type ContentIssue struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (i ContentIssue) String() string {
+	switch {
+	case i.File == "":
+		return i.Message
+	case i.Line == 0:
+		return i.File + ": " + i.Message
+	}
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// yamlErrorPattern matches the line number yaml.v3 puts in syntax errors
+var yamlErrorPattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// sitePaths are the pages outside the blog that post content may link to
+var sitePaths = map[string]bool{
+	"/": true, "/about": true, "/products": true, "/contact": true, "/write": true,
+	"/writings": true, "/writings/search": true, "/writings/tags": true, "/writings/archive": true,
+	"/writings/feed.xml": true, "/writings/atom.xml": true, "/writings/feed.json": true,
+	"/sitemap.xml": true, "/robots.txt": true, "/llms.txt": true, "/static/css/highlight.css": true,
+}
+
+// checkedPost is a post file as read by the content check
+type checkedPost struct {
+	file        string
+	fields      *yaml.Node // the post's YAML mapping
+	headerLine  int        // file line of the first YAML line
+	contentLine int        // file line of the first content line
+	post        BlogPost
+	authorIDs   []string
+}
+
+// line returns the file line of a post field, following nested keys such as
+// "meta", "og_image", or of the header when the field is not set
+func (p checkedPost) line(keys ...string) int {
+	value := p.fields
+	for _, key := range keys {
+		value = yamlField(value, key)
+	}
+	if value != nil {
+		return p.headerLine + value.Line - 1
+	}
+	return p.headerLine
+}
+
+// contentChecker collects the issues found while checking content
+type contentChecker struct {
+	blogDir   string
+	staticDir string
+	redirects []Redirect // rules from redirects.yaml
+	authors   []Author   // from authors.yaml
+	languages []Language // from blogs.yaml
+	issues    []ContentIssue
+}
+
+func (c *contentChecker) report(file string, line int, format string, args ...any) {
+	c.issues = append(c.issues, ContentIssue{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// CheckContent validates the blog posts, blogs.yaml and the experience file
+// the server loads, and returns every problem found rather than stopping at
+// the first. Where the server skips a broken post or falls back to built-in
+// experience data, this reports it. Local images are looked up in staticDir.
+func CheckContent(blogDir, experienceFile, staticDir string) []ContentIssue {
+	c := &contentChecker{blogDir: blogDir, staticDir: staticDir, languages: defaultLanguages}
+
+	var err error
+	if c.authors, err = readAuthors(blogDir); err != nil {
+		c.report(filepath.Join(blogDir, "authors.yaml"), 0, "%v", err)
+	}
+	categories := c.checkBlogConfig(filepath.Join(blogDir, "blogs.yaml"))
+	posts := c.readPosts()
+	c.checkPosts(posts, categories)
+	c.checkLinks(posts, categories)
+	c.checkExperience(experienceFile)
+
+	return c.issues
+}
+
+// checkBlogConfig checks blogs.yaml and returns its categories. Its languages
+// are kept for checking the posts.
+func (c *contentChecker) checkBlogConfig(path string) []Category {
+	root, ok := c.readYAML(path, 0, c.readFile(path))
+	if !ok {
+		return nil
+	}
+
+	var config BlogDataYAML
+	if err := root.Decode(&config); err != nil {
+		c.reportYAMLError(path, 0, err)
+		return nil
+	}
+	if err := config.Archive.normalize(); err != nil {
+		c.report(path, fieldLine(root, "archive"), "%v", err)
+	}
+	if languages, err := normalizeLanguages(config.Languages); err != nil {
+		c.report(path, fieldLine(root, "languages"), "%v", err)
+	} else {
+		c.languages = languages
+	}
+	if config.Meta.Author != "" {
+		if _, err := resolveAuthors(c.authors, []string{config.Meta.Author}); err != nil {
+			c.report(path, fieldLine(yamlField(root, "meta"), "author"), "meta.author: %v", err)
+		}
+	}
+
+	slugs := make(map[string]int)
+	if seq := yamlField(root, "categories"); seq != nil {
+		for i, item := range seq.Content {
+			if i >= len(config.Categories) {
+				break
+			}
+			category := config.Categories[i]
+			switch {
+			case category.Slug == "":
+				c.report(path, item.Line, "category %q: missing slug", category.Name)
+			case slugs[category.Slug] != 0:
+				c.report(path, item.Line, "category %q: duplicate slug, first used on line %d", category.Slug, slugs[category.Slug])
+			default:
+				slugs[category.Slug] = item.Line
+			}
+			if category.Name == "" {
+				c.report(path, item.Line, "category %q: missing name", category.Slug)
+			}
+		}
+	}
+	return config.Categories
+}
+
+// readPosts parses every post file, reporting the ones that cannot be read
+func (c *contentChecker) readPosts() []checkedPost {
+	var files []string
+	for _, ext := range postExtensions {
+		matches, _ := filepath.Glob(filepath.Join(c.blogDir, "posts", "*"+ext))
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var posts []checkedPost
+	for _, file := range files {
+		if post, ok := c.readPost(file); ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts
+}
+
+func (c *contentChecker) readPost(file string) (checkedPost, bool) {
+	data := c.readFile(file)
+	if data == nil {
+		return checkedPost{}, false
+	}
+	checked := checkedPost{file: file, headerLine: 1}
+
+	header := data
+	var body []byte
+	if filepath.Ext(file) == ".md" {
+		var err error
+		header, body, err = splitFrontMatter(data)
+		if err != nil {
+			c.report(file, 1, "%v", err)
+			return checkedPost{}, false
+		}
+		// The header starts below the opening ---; the body follows the
+		// closing one, after any blank lines
+		normalized := bytes.ReplaceAll(bytes.TrimPrefix(data, []byte("\ufeff")), []byte("\r\n"), []byte("\n"))
+		checked.headerLine = 2
+		checked.contentLine = bytes.Count(normalized, []byte("\n")) - bytes.Count(body, []byte("\n")) + 1
+	}
+
+	root, ok := c.readYAML(file, checked.headerLine-1, header)
+	if !ok {
+		return checkedPost{}, false
+	}
+	checked.fields = root
+
+	var postYAML BlogPostYAML
+	if err := root.Decode(&postYAML); err != nil {
+		c.reportYAMLError(file, checked.headerLine-1, err)
+		return checkedPost{}, false
+	}
+	if body != nil {
+		postYAML.Content = string(body)
+	} else if content := yamlField(root, "content"); content != nil {
+		checked.contentLine = content.Line
+		if content.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			checked.contentLine++
+		}
+	}
+
+	checked.post = BlogPost{
+		ID:        postYAML.ID,
+		Title:     postYAML.Title,
+		Slug:      postYAML.Slug,
+		Excerpt:   postYAML.Excerpt,
+		Content:   postYAML.Content,
+		Category:  postYAML.Category,
+		Tags:      postYAML.Tags,
+		Series:    postYAML.Series,
+		Related:   postYAML.Related,
+		Published: postYAML.Published,
+		Meta:      postYAML.Meta,
+	}
+
+	ids, err := postYAML.authorIDs()
+	if err != nil {
+		c.report(file, checked.line("authors"), "%v", err)
+	}
+	checked.authorIDs = ids
+
+	lang, err := postLanguage(c.languages, postYAML.Lang)
+	if err != nil {
+		c.report(file, checked.line("lang"), "%v", err)
+		lang = c.languages[0].Code
+	}
+	checked.post.Lang = lang
+	checked.post.TranslationOf = postYAML.TranslationOf
+	checked.post.prefix = languagePrefix(c.languages, lang)
+
+	if postYAML.PublishDate != "" {
+		date, err := utils.ParseSiteDate(postYAML.PublishDate)
+		if err != nil {
+			c.report(file, checked.line("publish_date"), "publish_date: %v", err)
+		}
+		checked.post.PublishDate = date
+	} else if postYAML.Published {
+		c.report(file, checked.line("published"), "publish_date: required for published posts")
+	}
+	if postYAML.UpdatedDate != nil && *postYAML.UpdatedDate != "" {
+		date, err := utils.ParseSiteDate(*postYAML.UpdatedDate)
+		if err != nil {
+			c.report(file, checked.line("updated_date"), "updated_date: %v", err)
+		} else if date.Before(checked.post.PublishDate) {
+			c.report(file, checked.line("updated_date"), "updated_date: before publish_date")
+		}
+	}
+
+	return checked, true
+}
+
+// checkPosts checks the fields of every post and the references between them
+func (c *contentChecker) checkPosts(posts []checkedPost, categories []Category) {
+	ids := make(map[string]string)
+	slugFiles := make(map[string]string)
+	var blogPosts []BlogPost
+
+	for _, checked := range posts {
+		post, file := checked.post, checked.file
+
+		if post.ID == "" {
+			c.report(file, checked.line("id"), "id: missing")
+		} else if other, ok := ids[post.ID]; ok {
+			c.report(file, checked.line("id"), "id: %q is also used by %s", post.ID, other)
+		} else {
+			ids[post.ID] = file
+		}
+
+		if post.Slug == "" {
+			c.report(file, checked.line("slug"), "slug: missing")
+		} else if other, ok := slugFiles[post.Slug]; ok {
+			c.report(file, checked.line("slug"), "slug: %q is also used by %s", post.Slug, other)
+		} else {
+			slugFiles[post.Slug] = file
+			blogPosts = append(blogPosts, post)
+		}
+
+		if strings.TrimSpace(post.Title) == "" {
+			c.report(file, checked.line("title"), "title: missing")
+		}
+		if post.Published && strings.TrimSpace(post.Excerpt) == "" && utils.Excerpt(post.Content, excerptWords) == "" {
+			c.report(file, checked.line("excerpt"), "excerpt: missing, and there is no content to derive one from")
+		}
+		if post.Category != "" && getCategoryBySlug(categories, post.Category) == nil {
+			c.report(file, checked.line("category"), "category: %q is not defined in blogs.yaml", post.Category)
+		}
+		for _, id := range checked.authorIDs {
+			if _, err := resolveAuthors(c.authors, []string{id}); err != nil {
+				key := "authors"
+				if len(checked.authorIDs) == 1 && yamlField(checked.fields, "author") != nil {
+					key = "author"
+				}
+				c.report(file, checked.line(key), "%s: %v", key, err)
+			}
+		}
+
+		if err := utils.CheckShortcodes(post.Content, filepath.Join(c.blogDir, "snippets")); err != nil {
+			var scErr *utils.ShortcodeError
+			if errors.As(err, &scErr) {
+				c.report(file, checked.contentLine+scErr.Line-1, "%v", scErr.Err)
+			} else {
+				c.report(file, checked.contentLine, "%v", err)
+			}
+		}
+	}
+
+	// Series, related posts, translations and redirects are checked the way
+	// the server checks them, which stops at the first problem
+	if err := validateSeries(blogPosts, slugFiles); err != nil {
+		c.report("", 0, "%v", err)
+	}
+	if err := validateRelated(blogPosts, slugFiles); err != nil {
+		c.report("", 0, "%v", err)
+	}
+	if err := validateTranslations(blogPosts, slugFiles); err != nil {
+		c.report("", 0, "%v", err)
+	}
+	var err error
+	if c.redirects, err = readRedirects(c.blogDir); err != nil {
+		c.report(filepath.Join(c.blogDir, "redirects.yaml"), 0, "%v", err)
+	}
+	if err := validateRedirects(blogPosts, c.redirects, slugFiles); err != nil {
+		c.report("", 0, "%v", err)
+	}
+}
+
+// checkLinks checks that site links and local images in post content resolve
+func (c *contentChecker) checkLinks(posts []checkedPost, categories []Category) {
+	blogData := &BlogData{Categories: categories, Languages: c.languages, Authors: c.authors, redirectRules: c.redirects}
+	for _, checked := range posts {
+		if checked.post.Slug != "" {
+			blogData.Posts = append(blogData.Posts, checked.post)
+		}
+	}
+	sort.SliceStable(blogData.Posts, func(i, j int) bool {
+		return blogData.Posts[i].PublishDate.After(blogData.Posts[j].PublishDate)
+	})
+	// Scheduled posts count as published: links to them work once they are
+	site := blogData.visibleAt(time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	for _, checked := range posts {
+		for _, link := range utils.ContentLinks(checked.post.Content) {
+			if !utils.IsSiteLink(link.URL) {
+				continue
+			}
+			if problem := c.resolve(site, link.URL, checked.post.Published); problem != "" {
+				kind := "link"
+				if link.Image {
+					kind = "image"
+				}
+				c.report(checked.file, checked.contentLine+link.Line-1, "%s %s: %s", kind, link.URL, problem)
+			}
+		}
+		if image := checked.post.Meta.OGImage; utils.IsSiteLink(image) {
+			if problem := c.resolve(site, image, checked.post.Published); problem != "" {
+				c.report(checked.file, checked.line("meta", "og_image"), "meta.og_image %s: %s", image, problem)
+			}
+		}
+	}
+}
+
+// resolve returns why a site path does not lead to a page or file, or "" when
+// it does. Links from published posts to drafts are reported, since they only
+// work in preview.
+func (c *contentChecker) resolve(site *BlogData, target string, published bool) string {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "invalid URL"
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	// Writings under a language prefix resolve in that language
+	if code, rest, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/"); ok && languagePrefix(site.Languages, code) != "" && getLanguage(site.Languages, code) != nil {
+		if rest == "writings" || strings.HasPrefix(rest, "writings/") {
+			site, path = site.Language(code), "/"+rest
+		}
+	}
+	if path == "" || sitePaths[path] {
+		return ""
+	}
+	if r, ok := site.redirects[path]; ok {
+		if r.Status == http.StatusGone {
+			return "the page was removed"
+		}
+		return ""
+	}
+
+	if file, ok := strings.CutPrefix(path, "/static/"); ok {
+		if _, err := os.Stat(filepath.Join(c.staticDir, filepath.FromSlash(file))); err != nil {
+			return "no such file in " + c.staticDir
+		}
+		return ""
+	}
+
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if parts[0] == "blog" {
+		parts[0] = "writings"
+	}
+	if parts[0] != "writings" || len(parts) < 2 {
+		return "no such page"
+	}
+	name := parts[1]
+	if len(parts) > 2 {
+		name = parts[2]
+	}
+
+	switch {
+	case len(parts) == 2:
+		if year, err := strconv.Atoi(name); err == nil && len(name) == 4 {
+			return emptyPeriod(site, ArchivePeriod{Year: year})
+		}
+		if site.PostBySlug(name) != nil {
+			return ""
+		}
+		if _, ok := site.hidden[name]; ok {
+			if published {
+				return "links to a draft"
+			}
+			return ""
+		}
+		return "no such post"
+	case parts[1] == "tag" && (len(parts) == 3 || isFeedPath(parts[3:])):
+		if len(site.PostsByTag(name)) == 0 {
+			return "no posts are tagged " + strconv.Quote(name)
+		}
+	case parts[1] == "category" && (len(parts) == 3 || isFeedPath(parts[3:])):
+		if getCategoryBySlug(site.Categories, name) == nil {
+			return "no such category"
+		}
+	case len(parts) == 3 && parts[2] == "og.png":
+		if site.PostBySlug(parts[1]) == nil {
+			return "no such post"
+		}
+	case parts[1] == "author" && len(parts) == 3:
+		if getAuthorByID(site.Authors, name) == nil {
+			return "no such author"
+		}
+	case parts[1] == "series" && len(parts) == 3:
+		if len(site.postsBySeries[name]) == 0 {
+			return "no such series"
+		}
+	case len(parts) == 3:
+		year, yearErr := strconv.Atoi(parts[1])
+		month, monthErr := strconv.Atoi(parts[2])
+		if yearErr != nil || monthErr != nil || month < 1 || month > 12 {
+			return "no such page"
+		}
+		return emptyPeriod(site, ArchivePeriod{Year: year, Month: time.Month(month)})
+	default:
+		return "no such page"
+	}
+	return ""
+}
+
+func emptyPeriod(site *BlogData, period ArchivePeriod) string {
+	if len(site.PostsInPeriod(period)) == 0 {
+		return "no posts in " + period.Name()
+	}
+	return ""
+}
+
+func isFeedPath(rest []string) bool {
+	return len(rest) == 1 && (rest[0] == "feed.xml" || rest[0] == "atom.xml" || rest[0] == "feed.json")
+}
+
+// checkExperience checks the entries of the experience file, which the about
+// page otherwise replaces with built-in data when it fails to load
+func (c *contentChecker) checkExperience(path string) {
+	root, ok := c.readYAML(path, 0, c.readFile(path))
+	if !ok {
+		return
+	}
+
+	var data ExperienceYAML
+	if err := root.Decode(&data); err != nil {
+		c.reportYAMLError(path, 0, err)
+		return
+	}
+
+	items := yamlItems(root, "experiences")
+	for i, exp := range data.Experiences {
+		item := items[i]
+		name := exp.Company
+		if name == "" {
+			c.report(path, item.Line, "experience: missing company")
+			name = strconv.Itoa(i + 1)
+		}
+		if exp.Position == "" {
+			c.report(path, fieldLine(item, "position"), "experience %q: missing position", name)
+		}
+		start := c.checkDate(path, item, "experience", name, "start_date", exp.StartDate)
+		if exp.EndDate != nil && *exp.EndDate != "" {
+			end := c.checkDate(path, item, "experience", name, "end_date", *exp.EndDate)
+			if !start.IsZero() && !end.IsZero() && end.Before(start) {
+				c.report(path, fieldLine(item, "end_date"), "experience %q: end_date is before start_date", name)
+			}
+		}
+	}
+
+	items = yamlItems(root, "education")
+	for i, edu := range data.Education {
+		item := items[i]
+		name := edu.Institution
+		if name == "" {
+			c.report(path, item.Line, "education: missing institution")
+			name = strconv.Itoa(i + 1)
+		}
+		start := c.checkDate(path, item, "education", name, "start_date", edu.StartDate)
+		end := c.checkDate(path, item, "education", name, "end_date", edu.EndDate)
+		if !start.IsZero() && !end.IsZero() && end.Before(start) {
+			c.report(path, fieldLine(item, "end_date"), "education %q: end_date is before start_date", name)
+		}
+	}
+
+	items = yamlItems(root, "skills")
+	for i, skill := range data.Skills {
+		if skill.Category == "" {
+			c.report(path, items[i].Line, "skills: missing category")
+		} else if len(skill.Items) == 0 {
+			c.report(path, items[i].Line, "skills %q: no items", skill.Category)
+		}
+	}
+}
+
+// checkDate parses a date field of an experience or education entry
+func (c *contentChecker) checkDate(path string, item *yaml.Node, kind, name, key, value string) time.Time {
+	if value == "" {
+		c.report(path, fieldLine(item, key), "%s %q: missing %s", kind, name, key)
+		return time.Time{}
+	}
+	date, err := utils.ParseSiteDate(value)
+	if err != nil {
+		c.report(path, fieldLine(item, key), "%s %q: %s: %v", kind, name, key, err)
+	}
+	return date
+}
+
+// readYAML parses data into a node tree, reporting syntax errors with their
+// line shifted by offset. It returns the top-level mapping.
+func (c *contentChecker) readYAML(path string, offset int, data []byte) (*yaml.Node, bool) {
+	if data == nil {
+		return nil, false
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		c.reportYAMLError(path, offset, err)
+		return nil, false
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		c.report(path, offset+1, "expected a YAML mapping")
+		return nil, false
+	}
+	return doc.Content[0], true
+}
+
+func (c *contentChecker) reportYAMLError(path string, offset int, err error) {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		for _, msg := range typeErr.Errors {
+			c.reportYAMLError(path, offset, errors.New(msg))
+		}
+		return
+	}
+	if m := yamlErrorPattern.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		c.report(path, offset+line, "%s", m[2])
+		return
+	}
+	c.report(path, 0, "%v", err)
+}
+
+// readFile reads a content file, reporting it when it cannot be read
+func (c *contentChecker) readFile(path string) []byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.report(path, 0, "%v", errors.Unwrap(err))
+		return nil
+	}
+	return data
+}
+
+// yamlField returns the value of key in a mapping node, or nil
+func yamlField(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlItems returns the items of the sequence under key
+func yamlItems(mapping *yaml.Node, key string) []*yaml.Node {
+	if seq := yamlField(mapping, key); seq != nil && seq.Kind == yaml.SequenceNode {
+		return seq.Content
+	}
+	return nil
+}
+
+// fieldLine returns the line of key in a mapping, or of the mapping itself
+func fieldLine(mapping *yaml.Node, key string) int {
+	if value := yamlField(mapping, key); value != nil {
+		return value.Line
+	}
+	return mapping.Line
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const checkTestExperience = `title: "experience"
+experiences:
+  - company: "Acme"
+    position: "Engineer"
+    start_date: "2022-01-01"
+    end_date: "2021-01-01"
+  - company: "Initech"
+    start_date: "sometime"
+education:
+  - institution: "University"
+    start_date: "2015-09-01"
+    end_date: "2019-06-30"
+skills:
+  - category: "Languages"
+`
+
+func TestCheckContent(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"good.yaml": `id: "good"
+title: "Good"
+slug: "good"
+publish_date: "2024-01-01"
+published: true
+tags: ["go"]
+content: |
+  See [the other post](/writings/also-good), [go posts](/writings/tag/go)
+  and [January](/writings/2024/01).
+`,
+		"also-good.md": `---
+id: "also-good"
+title: "Also good"
+slug: "also-good"
+publish_date: "2024-01-02"
+published: true
+category: "engineering"
+---
+
+Read [good](/writings/good#intro) or [elsewhere](https://example.org/x).
+
+` + "```" + `
+[ignored](/writings/missing-in-code)
+` + "```" + `
+`,
+		"broken.md": `---
+id: "good"
+slug: "good"
+publish_date: "2024-13-01"
+published: true
+category: "cooking"
+---
+
+A [missing post](/writings/nope) and a ![missing image](/static/nope.png).
+A [draft link](/writings/draft) and {{< callout type="shout" >}}hi{{< /callout >}}
+`,
+		"draft.yaml": `id: "draft"
+title: "Draft"
+slug: "draft"
+published: false
+`,
+		"unparseable.yaml": "id: [\n",
+	})
+	experienceFile := filepath.Join(t.TempDir(), "experience.yaml")
+	if err := os.WriteFile(experienceFile, []byte(checkTestExperience), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, issue := range CheckContent(dir, experienceFile, t.TempDir()) {
+		got = append(got, strings.TrimPrefix(issue.String(), dir+string(filepath.Separator)))
+	}
+
+	want := []string{
+		`posts/broken.md:2: title: missing`,
+		`posts/broken.md:4: publish_date: invalid date "2024-13-01"`,
+		`posts/broken.md:6: category: "cooking" is not defined in blogs.yaml`,
+		`posts/broken.md:9: link /writings/nope: no such post`,
+		`posts/broken.md:9: image /static/nope.png: no such file in `,
+		`posts/broken.md:10: callout: unknown callout type "shout"; use note, warning or tip`,
+		`posts/broken.md:10: link /writings/draft: links to a draft`,
+		`posts/good.yaml:1: id: "good" is also used by `,
+		`posts/good.yaml:3: slug: "good" is also used by `,
+		`posts/unparseable.yaml:1: did not find expected node content`,
+		`experience.yaml:6: experience "Acme": end_date is before start_date`,
+		`experience.yaml:7: experience "Initech": missing position`,
+		`experience.yaml:8: experience "Initech": start_date: invalid date "sometime"`,
+		`experience.yaml:14: skills "Languages": no items`,
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			if strings.Contains(g, w) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Expected an issue %q", w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("Expected %d issues, got %d", len(want), len(got))
+	}
+	if t.Failed() {
+		t.Logf("Issues:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestCheckContentClean(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01", `content: "Hello."`),
+	})
+	experienceFile := filepath.Join(t.TempDir(), "experience.yaml")
+	if err := os.WriteFile(experienceFile, []byte("title: \"experience\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if issues := CheckContent(dir, experienceFile, t.TempDir()); len(issues) != 0 {
+		t.Errorf("Expected no issues, got %v", issues)
+	}
+}
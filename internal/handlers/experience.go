@@ -1,12 +1,12 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
-	"github.com/thinkingojha/go-htmx/internal/logger"
 	"github.com/thinkingojha/go-htmx/internal/utils"
 	"gopkg.in/yaml.v3"
 )
@@ -103,7 +103,7 @@ func ExpHandler(w http.ResponseWriter, r *http.Request) error {
 		return err
 	}
 
-	expData := getExperienceData()
+	expData := content().Experience()
 	data := AboutPageData{
 		ExperienceData: expData,
 		PageName:       "about",
@@ -118,17 +118,6 @@ func ExpHandler(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
 
-func getExperienceData() ExperienceData {
-	// Try to load from YAML file first
-	if data, err := loadExperienceFromYAML("experience.yaml"); err == nil {
-		return data
-	}
-
-	// Fallback to hardcoded data if YAML file doesn't exist
-	logger.Warn("Could not load experience.yaml, using fallback data")
-	return getFallbackExperienceData()
-}
-
 func loadExperienceFromYAML(filename string) (ExperienceData, error) {
 	file, err := os.ReadFile(filename)
 	if err != nil {
@@ -149,12 +138,17 @@ func loadExperienceFromYAML(filename string) (ExperienceData, error) {
 
 	// Convert experiences
 	for _, exp := range yamlData.Experiences {
-		startDate, _ := time.Parse("2006-01-02", exp.StartDate)
+		startDate, err := utils.ParseSiteDate(exp.StartDate)
+		if err != nil {
+			return ExperienceData{}, fmt.Errorf("experience %q: start_date: %w", exp.Company, err)
+		}
 		var endDate *time.Time
 		if exp.EndDate != nil && *exp.EndDate != "" {
-			if parsed, err := time.Parse("2006-01-02", *exp.EndDate); err == nil {
-				endDate = &parsed
+			parsed, err := utils.ParseSiteDate(*exp.EndDate)
+			if err != nil {
+				return ExperienceData{}, fmt.Errorf("experience %q: end_date: %w", exp.Company, err)
 			}
+			endDate = &parsed
 		}
 
 		data.Experiences = append(data.Experiences, Experience{
@@ -172,8 +166,14 @@ func loadExperienceFromYAML(filename string) (ExperienceData, error) {
 
 	// Convert education
 	for _, edu := range yamlData.Education {
-		startDate, _ := time.Parse("2006-01-02", edu.StartDate)
-		endDate, _ := time.Parse("2006-01-02", edu.EndDate)
+		startDate, err := utils.ParseSiteDate(edu.StartDate)
+		if err != nil {
+			return ExperienceData{}, fmt.Errorf("education %q: start_date: %w", edu.Institution, err)
+		}
+		endDate, err := utils.ParseSiteDate(edu.EndDate)
+		if err != nil {
+			return ExperienceData{}, fmt.Errorf("education %q: end_date: %w", edu.Institution, err)
+		}
 
 		data.Education = append(data.Education, EducationItem{
 			Institution: edu.Institution,
@@ -0,0 +1,580 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// feedPostLimit caps the number of entries in every feed
+const feedPostLimit = 20
+
+// feed is the format-independent description of a blog feed
+type feed struct {
+	Title       string
+	Description string
+	SiteURL     string
+	Link        string  // absolute URL of the HTML page the feed mirrors
+	Path        string  // path of the feed without its file name, e.g. /writings/tag/go
+	Author      *Author // credited for the feed as a whole
+	Language    string
+	Copyright   string
+	Posts       []BlogPost
+}
+
+// FeedLink is an autodiscovery <link rel="alternate"> entry
+type FeedLink struct {
+	Title string
+	Type  string
+	Href  string
+}
+
+// feedFiles maps each feed file name to its media type
+var feedFiles = []struct {
+	name      string
+	mediaType string
+	label     string
+}{
+	{"feed.xml", "application/rss+xml", "RSS"},
+	{"atom.xml", "application/atom+xml", "Atom"},
+	{"feed.json", "application/feed+json", "JSON Feed"},
+}
+
+// feedLinks returns the autodiscovery links for the whole blog in the view's
+// language, plus the tag or category feed when a listing is narrowed to one
+func feedLinks(blogData *BlogData, tag, category string) []FeedLink {
+	if !blogData.RSS.IsEnabled() {
+		return nil
+	}
+
+	var links []FeedLink
+	add := func(path, title string) {
+		for _, file := range feedFiles {
+			links = append(links, FeedLink{
+				Title: title + " (" + file.label + ")",
+				Type:  file.mediaType,
+				Href:  path + "/" + file.name,
+			})
+		}
+	}
+
+	prefix := blogData.Prefix
+	if tag != "" {
+		add(prefix+"/writings/tag/"+url.PathEscape(tag), blogData.feedTitle()+" — "+tag)
+	}
+	if cat := getCategoryBySlug(blogData.Categories, category); cat != nil {
+		add(prefix+"/writings/category/"+url.PathEscape(cat.Slug), blogData.feedTitle()+" — "+cat.Name)
+	}
+	add(prefix+"/writings", blogData.feedTitle())
+
+	return links
+}
+
+// resolveFeed builds the feed selected by the {tag} or {category} route
+// variables, from the posts in the view's language. It returns nil when the
+// tag or category does not exist.
+func resolveFeed(r *http.Request, blogData *BlogData) *feed {
+	siteURL := blogData.siteURL()
+	prefix := blogData.Prefix
+	f := &feed{
+		Title:       blogData.feedTitle(),
+		Description: blogData.Description,
+		SiteURL:     siteURL,
+		Link:        siteURL + prefix + "/writings",
+		Path:        prefix + "/writings",
+		Author:      blogData.SiteAuthor(),
+		Language:    blogData.RSS.Language,
+		Copyright:   blogData.RSS.Copyright,
+		Posts:       blogData.Posts,
+	}
+	if blogData.RSS.Description != "" {
+		f.Description = blogData.RSS.Description
+	}
+	// rss.link and rss.language describe the feed in the default language
+	if blogData.RSS.Link != "" && prefix == "" {
+		f.Link = blogData.RSS.Link
+	}
+	if prefix != "" {
+		f.Language = blogData.Lang
+	} else if f.Language == "" {
+		f.Language = "en-us"
+	}
+
+	vars := mux.Vars(r)
+	if tag, ok := vars["tag"]; ok {
+		posts := blogData.PostsByTag(tag)
+		if len(posts) == 0 {
+			return nil
+		}
+		f.Title = f.Title + " — " + tag
+		f.Description = "Writings tagged " + tag
+		f.Path = prefix + "/writings/tag/" + url.PathEscape(tag)
+		f.Link = siteURL + prefix + TagURL(tag)
+		f.Posts = posts
+	} else if slug, ok := vars["category"]; ok {
+		category := getCategoryBySlug(blogData.Categories, slug)
+		if category == nil {
+			return nil
+		}
+		f.Title = f.Title + " — " + category.Name
+		f.Description = category.Description
+		f.Path = prefix + "/writings/category/" + url.PathEscape(category.Slug)
+		f.Link = siteURL + prefix + category.URL()
+		f.Posts = blogData.PostsByCategory(category.Slug)
+	}
+
+	f.Posts = getRecentPosts(f.Posts, feedPostLimit)
+	return f
+}
+
+// postURL returns the canonical URL of a post
+func (f *feed) postURL(post BlogPost) string {
+	return f.SiteURL + post.URL()
+}
+
+// feedTitle is the rss: title when configured, otherwise the blog title
+func (b *BlogData) feedTitle() string {
+	if b.RSS.Title != "" {
+		return b.RSS.Title
+	}
+	return b.Title
+}
+
+// updated returns the most recent publish or update time across the feed, or
+// the zero time for an empty feed
+func (f *feed) updated() time.Time {
+	var latest time.Time
+	for _, post := range f.Posts {
+		if modified := post.lastModified(); modified.After(latest) {
+			latest = modified
+		}
+	}
+	return latest
+}
+
+// etag identifies the feed contents: it changes whenever a post is added,
+// removed or updated, or the feed metadata changes
+func (f *feed) etag(format string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s\n%s\n", format, f.Path, f.Title, f.Description, f.Link)
+	for _, post := range f.Posts {
+		fmt.Fprintf(h, "%s %d\n", post.Slug, post.lastModified().Unix())
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// lastModified returns the updated date when set, otherwise the publish date
+func (p BlogPost) lastModified() time.Time {
+	if p.UpdatedDate != nil && p.UpdatedDate.After(p.PublishDate) {
+		return *p.UpdatedDate
+	}
+	return p.PublishDate
+}
+
+// serveFeed resolves the requested feed and renders it with write. Feeds carry
+// Last-Modified and ETag headers derived from the newest post so readers can
+// poll with conditional requests.
+func serveFeed(w http.ResponseWriter, r *http.Request, format string, write func(http.ResponseWriter, *feed) error) error {
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	if !blogData.RSS.IsEnabled() {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	f := resolveFeed(r, blogData)
+	if f == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	etag := f.etag(format)
+	w.Header().Set("ETag", etag)
+	lastModified := f.updated()
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	return write(w, f)
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == etag || candidate == "W/"+etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since := r.Header.Get("If-Modified-Since"); since != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(since); err == nil {
+			return !lastModified.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
+
+// RSS feed handler
+func BlogRSSHandler(w http.ResponseWriter, r *http.Request) error {
+	return serveFeed(w, r, "rss", writeRSS)
+}
+
+// BlogAtomHandler serves the Atom 1.0 feed
+func BlogAtomHandler(w http.ResponseWriter, r *http.Request) error {
+	return serveFeed(w, r, "atom", writeAtom)
+}
+
+// BlogJSONFeedHandler serves the JSON Feed 1.1 feed
+func BlogJSONFeedHandler(w http.ResponseWriter, r *http.Request) error {
+	return serveFeed(w, r, "json", writeJSONFeed)
+}
+
+// RSS 2.0 document model (https://www.rssboard.org/rss-specification) with
+// the content and atom extensions
+type rssDocument struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	ContentNS string     `xml:"xmlns:content,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	DCNS      string     `xml:"xmlns:dc,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language,omitempty"`
+	Copyright     string    `xml:"copyright,omitempty"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Creators    []string      `xml:"dc:creator"`
+	Description string        `xml:"description,omitempty"`
+	Content     rssCDATA      `xml:"content:encoded"`
+	Categories  []rssCategory `xml:"category"`
+}
+
+type rssCategory struct {
+	Domain string `xml:"domain,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+func writeRSS(w http.ResponseWriter, f *feed) error {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+		Language:    f.Language,
+		Copyright:   f.Copyright,
+		AtomLink:    atomLink{Href: f.SiteURL + f.Path + "/feed.xml", Rel: "self", Type: "application/rss+xml"},
+	}
+	if updated := f.updated(); !updated.IsZero() {
+		channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+
+	for _, post := range f.Posts {
+		link := f.postURL(post)
+		item := rssItem{
+			Title:       post.Title,
+			Link:        link,
+			GUID:        rssGUID{IsPermaLink: true, Value: link},
+			PubDate:     post.PublishDate.Format(time.RFC1123Z),
+			Description: post.Excerpt,
+			Content:     rssCDATA{Value: utils.MarkdownToHTML(post.Content)},
+		}
+		for _, author := range post.Authors {
+			item.Creators = append(item.Creators, author.Name)
+		}
+		if post.Category != "" {
+			item.Categories = append(item.Categories, rssCategory{Value: post.Category})
+		}
+		for _, tag := range post.Tags {
+			item.Categories = append(item.Categories, rssCategory{Value: tag})
+		}
+		if post.Series != nil {
+			item.Categories = append(item.Categories, rssCategory{Domain: f.SiteURL + post.prefix + post.Series.URL(), Value: post.Series.Name})
+		}
+		channel.Items = append(channel.Items, item)
+	}
+
+	doc := rssDocument{
+		Version:   "2.0",
+		ContentNS: "http://purl.org/rss/1.0/modules/content/",
+		AtomNS:    "http://www.w3.org/2005/Atom",
+		DCNS:      "http://purl.org/dc/elements/1.1/",
+		Channel:   channel,
+	}
+
+	output, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(output)
+	return nil
+}
+
+// Atom 1.0 document model (RFC 4287)
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   *atomPerson `xml:"author,omitempty"`
+	Rights   string      `xml:"rights,omitempty"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term   string `xml:"term,attr"`
+	Scheme string `xml:"scheme,attr,omitempty"`
+	Label  string `xml:"label,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Links      []atomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Authors    []atomPerson   `xml:"author"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+	Categories []atomCategory `xml:"category"`
+}
+
+func writeAtom(w http.ResponseWriter, f *feed) error {
+	doc := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.SiteURL + f.Path + "/atom.xml",
+		Updated:  orNow(f.updated()).Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SiteURL + f.Path + "/atom.xml", Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+		},
+	}
+	if f.Author != nil {
+		person := f.atomPerson(*f.Author)
+		doc.Author = &person
+	}
+	if f.Copyright != "" {
+		doc.Rights = f.Copyright
+	}
+
+	for _, post := range f.Posts {
+		link := f.postURL(post)
+		entry := atomEntry{
+			Title:     post.Title,
+			ID:        link,
+			Links:     []atomLink{{Href: link, Rel: "alternate", Type: "text/html"}},
+			Published: post.PublishDate.Format(time.RFC3339),
+			Updated:   post.lastModified().Format(time.RFC3339),
+			Content:   atomText{Type: "html", Body: utils.MarkdownToHTML(post.Content)},
+		}
+		for _, author := range post.Authors {
+			entry.Authors = append(entry.Authors, f.atomPerson(author))
+		}
+		if post.Excerpt != "" {
+			entry.Summary = &atomText{Type: "text", Body: post.Excerpt}
+		}
+		if post.Category != "" {
+			entry.Categories = append(entry.Categories, atomCategory{Term: post.Category})
+		}
+		for _, tag := range post.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if post.Series != nil {
+			entry.Categories = append(entry.Categories, atomCategory{
+				Term:   post.Series.Slug,
+				Scheme: f.SiteURL + post.prefix + "/writings/series/",
+				Label:  fmt.Sprintf("%s, part %d", post.Series.Name, post.Series.Part),
+			})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	output, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(output)
+	return nil
+}
+
+// JSON Feed 1.1 document model (https://jsonfeed.org/version/1.1)
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description,omitempty"`
+	Language    string           `json:"language,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors,omitempty"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	Summary       string           `json:"summary,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+	Series        *jsonFeedSeries  `json:"_series,omitempty"`
+}
+
+// jsonFeedSeries is a JSON Feed extension object describing series membership
+type jsonFeedSeries struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	Part int    `json:"part"`
+}
+
+func writeJSONFeed(w http.ResponseWriter, f *feed) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.SiteURL + f.Path + "/feed.json",
+		Description: f.Description,
+		Language:    f.Language,
+		Items:       []jsonFeedItem{},
+	}
+	if f.Author != nil {
+		doc.Authors = []jsonFeedAuthor{f.jsonFeedAuthor(*f.Author)}
+	}
+
+	for _, post := range f.Posts {
+		item := jsonFeedItem{
+			ID:            f.postURL(post),
+			URL:           f.postURL(post),
+			Title:         post.Title,
+			ContentHTML:   utils.MarkdownToHTML(post.Content),
+			Summary:       post.Excerpt,
+			DatePublished: post.PublishDate.Format(time.RFC3339),
+			Tags:          post.Tags,
+		}
+		if post.UpdatedDate != nil {
+			item.DateModified = post.UpdatedDate.Format(time.RFC3339)
+		}
+		item.Image = absoluteURL(f.SiteURL, post.OGImageURL())
+		for _, author := range post.Authors {
+			item.Authors = append(item.Authors, f.jsonFeedAuthor(author))
+		}
+		if post.Series != nil {
+			item.Series = &jsonFeedSeries{Name: post.Series.Name, URL: f.SiteURL + post.prefix + post.Series.URL(), Part: post.Series.Part}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/feed+json; charset=utf-8")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// orNow substitutes the current time for a zero time
+func orNow(t time.Time) time.Time {
+	if t.IsZero() {
+		return time.Now()
+	}
+	return t
+}
+
+// atomPerson credits an author in an Atom feed, linking to their page
+func (f *feed) atomPerson(a Author) atomPerson {
+	person := atomPerson{Name: a.Name}
+	if a.ID != "" {
+		person.URI = f.SiteURL + a.URL()
+	}
+	return person
+}
+
+// jsonFeedAuthor credits an author in a JSON Feed
+func (f *feed) jsonFeedAuthor(a Author) jsonFeedAuthor {
+	author := jsonFeedAuthor{Name: a.Name, Avatar: absoluteURL(f.SiteURL, a.Avatar)}
+	if a.ID != "" {
+		author.URL = f.SiteURL + a.URL()
+	}
+	return author
+}
+
+// absoluteURL resolves site-relative paths such as /static/... against siteURL
+func absoluteURL(siteURL, ref string) string {
+	if strings.HasPrefix(ref, "/") && !strings.HasPrefix(ref, "//") {
+		return siteURL + ref
+	}
+	return ref
+}
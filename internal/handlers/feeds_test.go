@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func loadFeedTestBlog(t *testing.T) {
+	t.Helper()
+
+	dir := writeTestBlog(t, map[string]string{
+		"first.yaml": `id: "first"
+title: "Fish & <Chips>"
+slug: "first"
+author: "Ankush Ojha"
+publish_date: "2024-01-01"
+category: "engineering"
+tags: ["go"]
+published: true
+content: |
+  Hello **world**
+`,
+		"second.yaml": `id: "second"
+title: "Second"
+slug: "second"
+publish_date: "2024-02-01"
+tags: ["htmx"]
+published: true
+`,
+	})
+	loadTestBlog(t, dir)
+}
+
+func TestBlogAtomHandler(t *testing.T) {
+	loadFeedTestBlog(t)
+
+	req := httptest.NewRequest("GET", "/writings/tag/go/atom.xml", nil)
+	req = mux.SetURLVars(req, map[string]string{"tag": "go"})
+	rr := httptest.NewRecorder()
+
+	if err := BlogAtomHandler(rr, req); err != nil {
+		t.Fatalf("BlogAtomHandler returned an error: %v", err)
+	}
+
+	var doc atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Atom feed is not valid XML: %v\n%s", err, rr.Body.String())
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("Expected 1 entry in the go tag feed, got %d", len(doc.Entries))
+	}
+	for _, link := range doc.Links {
+		if link.Rel == "alternate" && link.Href != "https://example.com/writings/tag/go" {
+			t.Errorf("Expected the feed to link to the tag page, got %s", link.Href)
+		}
+	}
+	entry := doc.Entries[0]
+	if entry.Title != "Fish & <Chips>" {
+		t.Errorf("Entry title = %q", entry.Title)
+	}
+	if entry.ID != "https://example.com/writings/first" {
+		t.Errorf("Entry id = %q", entry.ID)
+	}
+	if entry.Content.Body != "<p>Hello <strong>world</strong></p>\n" {
+		t.Errorf("Expected full rendered content, got %q", entry.Content.Body)
+	}
+}
+
+func TestBlogJSONFeedHandler(t *testing.T) {
+	loadFeedTestBlog(t)
+
+	req := httptest.NewRequest("GET", "/writings/feed.json", nil)
+	rr := httptest.NewRecorder()
+
+	if err := BlogJSONFeedHandler(rr, req); err != nil {
+		t.Fatalf("BlogJSONFeedHandler returned an error: %v", err)
+	}
+
+	var doc jsonFeed
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("JSON feed is not valid JSON: %v", err)
+	}
+	if doc.Version != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("Version = %q", doc.Version)
+	}
+	if len(doc.Items) != 2 || doc.Items[0].ID != "https://example.com/writings/second" {
+		t.Errorf("Expected newest post first, got %+v", doc.Items)
+	}
+}
+
+func TestBlogFeedUnknownScope(t *testing.T) {
+	loadFeedTestBlog(t)
+
+	tests := []struct {
+		name string
+		vars map[string]string
+	}{
+		{name: "unknown tag", vars: map[string]string{"tag": "python"}},
+		{name: "unknown category", vars: map[string]string{"category": "cooking"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/", nil), tt.vars)
+			rr := httptest.NewRecorder()
+
+			if err := BlogRSSHandler(rr, req); err != nil {
+				t.Fatalf("BlogRSSHandler returned an error: %v", err)
+			}
+			if status := rr.Code; status != http.StatusNotFound {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestBlogRSSHandler(t *testing.T) {
+	loadFeedTestBlog(t)
+
+	req := httptest.NewRequest("GET", "/writings/feed.xml", nil)
+	rr := httptest.NewRecorder()
+
+	if err := BlogRSSHandler(rr, req); err != nil {
+		t.Fatalf("BlogRSSHandler returned an error: %v", err)
+	}
+
+	var doc struct {
+		Channel struct {
+			Title    string `xml:"title"`
+			Language string `xml:"language"`
+			Items    []struct {
+				Title      string   `xml:"title"`
+				Link       string   `xml:"link"`
+				GUID       string   `xml:"guid"`
+				Creator    string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Content    string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+				Categories []string `xml:"category"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("RSS feed is not valid XML: %v\n%s", err, rr.Body.String())
+	}
+	if doc.Channel.Language != "en-us" {
+		t.Errorf("Expected default language en-us, got %q", doc.Channel.Language)
+	}
+	if len(doc.Channel.Items) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(doc.Channel.Items))
+	}
+
+	item := doc.Channel.Items[1]
+	if item.Title != "Fish & <Chips>" {
+		t.Errorf("Item title = %q", item.Title)
+	}
+	if item.Link != "https://example.com/writings/first" || item.GUID != item.Link {
+		t.Errorf("Expected canonical /writings link and guid, got %q and %q", item.Link, item.GUID)
+	}
+	if item.Creator != "Ankush Ojha" {
+		t.Errorf("Item creator = %q", item.Creator)
+	}
+	if item.Content != "<p>Hello <strong>world</strong></p>\n" {
+		t.Errorf("Expected full rendered content, got %q", item.Content)
+	}
+	if len(item.Categories) != 2 || item.Categories[0] != "engineering" || item.Categories[1] != "go" {
+		t.Errorf("Item categories = %v", item.Categories)
+	}
+	if got := rr.Header().Get("Last-Modified"); got == "" {
+		t.Error("Expected a Last-Modified header")
+	}
+}
+
+func TestBlogFeedConditionalRequest(t *testing.T) {
+	loadFeedTestBlog(t)
+
+	rr := httptest.NewRecorder()
+	if err := BlogRSSHandler(rr, httptest.NewRequest("GET", "/writings/feed.xml", nil)); err != nil {
+		t.Fatal(err)
+	}
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag header")
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{name: "matching etag", header: "If-None-Match", value: etag, want: http.StatusNotModified},
+		{name: "stale etag", header: "If-None-Match", value: `"stale"`, want: http.StatusOK},
+		{name: "not modified since", header: "If-Modified-Since", value: rr.Header().Get("Last-Modified"), want: http.StatusNotModified},
+		{name: "modified since", header: "If-Modified-Since", value: "Mon, 01 Jan 2024 00:00:00 GMT", want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/writings/feed.xml", nil)
+			req.Header.Set(tt.header, tt.value)
+			rr := httptest.NewRecorder()
+
+			if err := BlogRSSHandler(rr, req); err != nil {
+				t.Fatal(err)
+			}
+			if rr.Code != tt.want {
+				t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlogFeedDisabled(t *testing.T) {
+	loadFeedTestBlog(t)
+
+	blogData, err := content().Blog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabled := false
+	blogData.RSS.Enabled = &disabled
+
+	rr := httptest.NewRecorder()
+	if err := BlogAtomHandler(rr, httptest.NewRequest("GET", "/writings/atom.xml", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	if links := feedLinks(blogData, "", ""); links != nil {
+		t.Errorf("Expected no autodiscovery links, got %v", links)
+	}
+}
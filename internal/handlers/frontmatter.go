@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatterDelimiter opens and closes the YAML header of a markdown post
+var frontMatterDelimiter = []byte("---")
+
+// postExtensions lists the post file formats the blog loader understands
+var postExtensions = []string{".yaml", ".md"}
+
+// parsePostFile decodes a post from either a YAML file or a markdown file with
+// a YAML front matter header. For markdown the body below the header becomes
+// the post content.
+func parsePostFile(path string, data []byte) (BlogPostYAML, error) {
+	var postYAML BlogPostYAML
+
+	if filepath.Ext(path) != ".md" {
+		if err := yaml.Unmarshal(data, &postYAML); err != nil {
+			return postYAML, err
+		}
+		return postYAML, nil
+	}
+
+	header, body, err := splitFrontMatter(data)
+	if err != nil {
+		return postYAML, err
+	}
+	if err := yaml.Unmarshal(header, &postYAML); err != nil {
+		return postYAML, fmt.Errorf("front matter: %w", err)
+	}
+	postYAML.Content = string(body)
+
+	return postYAML, nil
+}
+
+// splitFrontMatter separates the `---` delimited header from the markdown body
+func splitFrontMatter(data []byte) (header, body []byte, err error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	first, rest, found := bytes.Cut(data, []byte("\n"))
+	if !found || !bytes.Equal(bytes.TrimSpace(first), frontMatterDelimiter) {
+		return nil, nil, errors.New("missing front matter: file must start with ---")
+	}
+
+	for offset := 0; offset <= len(rest); {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		if bytes.Equal(bytes.TrimSpace(line), frontMatterDelimiter) {
+			header = rest[:offset]
+			body = rest[min(offset+len(line)+1, len(rest)):]
+			return header, bytes.TrimLeft(body, "\n"), nil
+		}
+		offset += len(line) + 1
+	}
+
+	return nil, nil, errors.New("unterminated front matter: missing closing ---")
+}
+
+// isPostFile reports whether path has one of the supported post extensions
+func isPostFile(path string) bool {
+	ext := filepath.Ext(path)
+	for _, supported := range postExtensions {
+		if ext == supported {
+			return true
+		}
+	}
+	return false
+}
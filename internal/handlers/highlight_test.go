@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+func TestMarkdownHighlighting(t *testing.T) {
+	source := "```go {2} title=\"main.go\" linenos\npackage main\nfunc main() {}\n```\n"
+
+	html := utils.MarkdownToHTML(source)
+	for _, want := range []string{
+		`<figure class="code-block" data-lang="go">`,
+		`<figcaption>main.go</figcaption>`,
+		`<pre class="chroma">`,
+		`<span class="kn">package</span>`,
+		`<span class="line hl">`,
+		`<span class="ln">2</span>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected highlighted code to contain %s, got: %s", want, html)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	if err := HighlightCSSHandler(rr, httptest.NewRequest("GET", "/static/css/highlight.css", nil)); err != nil {
+		t.Fatalf("HighlightCSSHandler returned an error: %v", err)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/css") {
+		t.Errorf("Expected a CSS content type, got %s", ct)
+	}
+	if !strings.Contains(rr.Body.String(), ".chroma .hl") {
+		t.Error("Expected the stylesheet to style highlighted lines")
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/gorilla/mux"
+)
+
+// Language is one of the languages the blog is written in, from the
+// languages: list of blogs.yaml. The first is the default, served without a
+// path prefix; the others are served under /{code}/writings.
+type Language struct {
+	Code string `json:"code" yaml:"code"` // such as "en" or "hi"
+	Name string `json:"name" yaml:"name"` // in the language itself, such as "हिन्दी"
+}
+
+// defaultLanguages applies when blogs.yaml lists none
+var defaultLanguages = []Language{{Code: "en", Name: "English"}}
+
+// languageCodePattern matches the codes the /{lang} routes accept
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// Translation links a post to its version in another language
+type Translation struct {
+	Lang  string `json:"lang"`
+	Name  string `json:"name"` // of the language
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// Alternate is a <link rel="alternate" hreflang> to the page in another
+// language
+type Alternate struct {
+	Lang string // hreflang, or "x-default"
+	Href string
+}
+
+// normalizeLanguages fills in English when blogs.yaml lists no languages and
+// rejects malformed or repeated codes
+func normalizeLanguages(languages []Language) ([]Language, error) {
+	if len(languages) == 0 {
+		return defaultLanguages, nil
+	}
+	seen := make(map[string]bool, len(languages))
+	for _, language := range languages {
+		switch {
+		case !languageCodePattern.MatchString(language.Code):
+			return nil, fmt.Errorf("languages: %q is not a two or three letter lowercase language code", language.Code)
+		case seen[language.Code]:
+			return nil, fmt.Errorf("languages: %q is listed twice", language.Code)
+		case language.Name == "":
+			return nil, fmt.Errorf("languages: %q: missing name", language.Code)
+		}
+		seen[language.Code] = true
+	}
+	return languages, nil
+}
+
+// postLanguage returns the language a post declares with lang:, or the
+// default language when it declares none
+func postLanguage(languages []Language, lang string) (string, error) {
+	if lang == "" {
+		return languages[0].Code, nil
+	}
+	if getLanguage(languages, lang) == nil {
+		return "", fmt.Errorf("lang: %q is not one of the languages in blogs.yaml", lang)
+	}
+	return lang, nil
+}
+
+func getLanguage(languages []Language, code string) *Language {
+	for i := range languages {
+		if languages[i].Code == code {
+			return &languages[i]
+		}
+	}
+	return nil
+}
+
+// languagePrefix returns the path prefix of a language: none for the default,
+// /{code} for the others
+func languagePrefix(languages []Language, code string) string {
+	if len(languages) == 0 || code == languages[0].Code {
+		return ""
+	}
+	return "/" + code
+}
+
+// URL returns the path of the post, under its language's prefix
+func (p BlogPost) URL() string {
+	return p.prefix + "/writings/" + url.PathEscape(p.Slug)
+}
+
+// translationGroup identifies the post and its translations: the ID of the
+// original post
+func (p BlogPost) translationGroup() string {
+	if p.TranslationOf != "" {
+		return p.TranslationOf
+	}
+	return p.ID
+}
+
+// validateTranslations checks that each translation_of names a post in
+// another language that is not itself a translation, and that a post has at
+// most one translation per language
+func validateTranslations(posts []BlogPost, files map[string]string) error {
+	byID := make(map[string]*BlogPost, len(posts))
+	for i := range posts {
+		if posts[i].ID != "" {
+			byID[posts[i].ID] = &posts[i]
+		}
+	}
+
+	groups := make(map[string]map[string]string) // original ID → language → slug
+	for _, post := range posts {
+		if post.TranslationOf == "" {
+			continue
+		}
+		original, ok := byID[post.TranslationOf]
+		switch {
+		case !ok:
+			return fmt.Errorf("%s: translation_of: no post has the id %q", files[post.Slug], post.TranslationOf)
+		case original.TranslationOf != "":
+			return fmt.Errorf("%s: translation_of: %q is itself a translation; name the original, %q", files[post.Slug], post.TranslationOf, original.TranslationOf)
+		case original.Lang == post.Lang:
+			return fmt.Errorf("%s: translation_of: %q is also in %q", files[post.Slug], post.TranslationOf, post.Lang)
+		}
+		langs := groups[original.ID]
+		if langs == nil {
+			langs = map[string]string{original.Lang: original.Slug}
+			groups[original.ID] = langs
+		}
+		if existing, ok := langs[post.Lang]; ok {
+			return fmt.Errorf("%s: translation_of: %q already has a %q translation in %s", files[post.Slug], original.ID, post.Lang, files[existing])
+		}
+		langs[post.Lang] = post.Slug
+	}
+	return nil
+}
+
+// buildTranslations links every visible post to its visible translations, in
+// the order of the languages in blogs.yaml. It runs before the indexes are
+// built, since they copy the posts.
+func (b *BlogData) buildTranslations() {
+	groups := make(map[string][]int)
+	for i, post := range b.Posts {
+		if group := post.translationGroup(); group != "" {
+			groups[group] = append(groups[group], i)
+		}
+	}
+
+	for i := range b.Posts {
+		b.Posts[i].Translations = nil
+		members := groups[b.Posts[i].translationGroup()]
+		if len(members) < 2 {
+			continue
+		}
+		for _, language := range b.Languages {
+			for _, j := range members {
+				if other := b.Posts[j]; j != i && other.Lang == language.Code {
+					b.Posts[i].Translations = append(b.Posts[i].Translations, Translation{
+						Lang:  other.Lang,
+						Name:  language.Name,
+						Title: other.Title,
+						URL:   other.URL(),
+					})
+				}
+			}
+		}
+	}
+}
+
+// buildLanguageViews gives each language of a multilingual blog a view holding
+// only its posts, with its own indexes, which the listings, feeds and sitemap
+// serve under the language's prefix. A blog in one language is its own view.
+func (b *BlogData) buildLanguageViews() {
+	b.languageViews = nil
+	if len(b.Languages) == 0 {
+		return
+	}
+	b.Lang = b.Languages[0].Code
+	if len(b.Languages) < 2 {
+		return
+	}
+
+	b.languageViews = make(map[string]*BlogData, len(b.Languages))
+	for _, language := range b.Languages {
+		view := *b
+		view.Lang = language.Code
+		view.Prefix = languagePrefix(b.Languages, language.Code)
+		view.Posts = nil
+		for _, post := range b.Posts {
+			if post.Lang == language.Code {
+				view.Posts = append(view.Posts, post)
+			}
+		}
+		view.buildIndexes()
+		view.buildSeriesIndex()
+		view.buildArchiveIndex()
+		view.buildRelated()
+		view.search = buildSearchIndex(view.Posts)
+		b.languageViews[language.Code] = &view
+	}
+}
+
+// Language returns the view of the blog in the language with the given code,
+// or in the default language for "" or a code the blog is not written in
+func (b *BlogData) Language(code string) *BlogData {
+	if b.languageViews == nil {
+		return b
+	}
+	if view, ok := b.languageViews[code]; ok {
+		return view
+	}
+	return b.languageViews[b.Languages[0].Code]
+}
+
+// LanguageName returns the name of the language the page is in
+func (d BlogPageData) LanguageName() string {
+	if language := getLanguage(d.Languages, d.Lang); language != nil {
+		return language.Name
+	}
+	return d.Lang
+}
+
+// languageBlog returns the blog in the language of the request's /{lang}
+// prefix, or in the default language without one
+func languageBlog(r *http.Request) (*BlogData, error) {
+	blogData, err := content().Blog()
+	if err != nil {
+		return nil, err
+	}
+	return blogData.Language(mux.Vars(r)["lang"]), nil
+}
+
+// IsLanguagePrefix reports whether /{code}/writings serves one of the blog's
+// languages, for the language middleware. The default language has no prefix.
+func IsLanguagePrefix(code string) bool {
+	blogData, err := content().Blog()
+	if err != nil {
+		return false
+	}
+	view, ok := blogData.languageViews[code]
+	return ok && view.Prefix != ""
+}
+
+// postAlternates lists a post and its translations for hreflang links, with
+// the version in the default language as the x-default
+func postAlternates(b *BlogData, post *BlogPost) []Alternate {
+	if len(post.Translations) == 0 {
+		return nil
+	}
+	site := b.siteURL()
+	defaultLang := b.Languages[0].Code
+	alternates := []Alternate{{Lang: post.Lang, Href: site + post.URL()}}
+	for _, translation := range post.Translations {
+		alternates = append(alternates, Alternate{Lang: translation.Lang, Href: site + translation.URL})
+	}
+	for _, alternate := range alternates {
+		if alternate.Lang == defaultLang {
+			return append(alternates, Alternate{Lang: "x-default", Href: alternate.Href})
+		}
+	}
+	return alternates
+}
+
+// indexAlternates lists the writings index of every language for hreflang
+// links, with the default language's as the x-default
+func indexAlternates(b *BlogData) []Alternate {
+	if len(b.Languages) < 2 {
+		return nil
+	}
+	site := b.siteURL()
+	var alternates []Alternate
+	for _, language := range b.Languages {
+		alternates = append(alternates, Alternate{Lang: language.Code, Href: site + languagePrefix(b.Languages, language.Code) + "/writings"})
+	}
+	return append(alternates, Alternate{Lang: "x-default", Href: alternates[0].Href})
+}
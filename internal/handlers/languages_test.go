@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+const testLanguagesYAML = `languages:
+  - code: "en"
+    name: "English"
+  - code: "hi"
+    name: "हिन्दी"
+`
+
+// writeLanguagesTestBlog creates a blog in English and Hindi with the given
+// post files
+func writeLanguagesTestBlog(t *testing.T, posts map[string]string) string {
+	t.Helper()
+
+	dir := writeTestBlog(t, posts)
+	if err := os.WriteFile(filepath.Join(dir, "blogs.yaml"), []byte(testBlogsYAML+testLanguagesYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func loadLanguagesTestBlog(t *testing.T) *BlogData {
+	t.Helper()
+
+	dir := writeLanguagesTestBlog(t, map[string]string{
+		"engines.yaml":    testPost("engines", "2024-01-01", `category: "engineering"`, `tags: ["go"]`, `content: "Engines."`),
+		"engines-hi.yaml": testPost("engines-hi", "2024-01-05", `category: "engineering"`, `tags: ["go"]`, `lang: "hi"`, `translation_of: "engines"`, `content: "इंजन।"`),
+		"only-en.yaml":    testPost("only-en", "2024-02-01", `content: "Only in English."`),
+	})
+	return loadTestBlog(t, dir)
+}
+
+func TestLoadBlogDataTranslations(t *testing.T) {
+	blogData := loadLanguagesTestBlog(t)
+
+	engines := blogData.PostBySlug("engines")
+	if engines.Lang != "en" {
+		t.Errorf("Expected posts without lang to be in the default language, got %q", engines.Lang)
+	}
+	if len(engines.Translations) != 1 || engines.Translations[0] != (Translation{Lang: "hi", Name: "हिन्दी", Title: "engines-hi", URL: "/hi/writings/engines-hi"}) {
+		t.Errorf("Expected engines to link to its Hindi translation, got %+v", engines.Translations)
+	}
+	hindi := blogData.PostBySlug("engines-hi")
+	if len(hindi.Translations) != 1 || hindi.Translations[0].URL != "/writings/engines" {
+		t.Errorf("Expected the translation to link back to the original, got %+v", hindi.Translations)
+	}
+	if got := hindi.ReadingTimeText(); got != "1 मिनट का पाठ" {
+		t.Errorf("Expected the reading time in Hindi, got %q", got)
+	}
+
+	for _, tt := range []struct {
+		code, lang, prefix, slugs string
+	}{
+		{"", "en", "", "only-en,engines"},
+		{"hi", "hi", "/hi", "engines-hi"},
+		{"fr", "en", "", "only-en,engines"},
+	} {
+		view := blogData.Language(tt.code)
+		var slugs []string
+		for _, post := range view.Posts {
+			slugs = append(slugs, post.Slug)
+		}
+		if view.Lang != tt.lang || view.Prefix != tt.prefix || strings.Join(slugs, ",") != tt.slugs {
+			t.Errorf("Language(%q) = %s %q with %v, want %s %q with %s", tt.code, view.Lang, view.Prefix, slugs, tt.lang, tt.prefix, tt.slugs)
+		}
+	}
+	if got := blogData.Language("hi").PostsByTag("go"); len(got) != 1 || got[0].Slug != "engines-hi" {
+		t.Errorf("Expected the Hindi tag index to hold only Hindi posts, got %d posts", len(got))
+	}
+
+	tests := []struct {
+		name  string
+		posts map[string]string
+		want  string
+	}{
+		{"unknown language", map[string]string{
+			"post.yaml": testPost("post", "2024-01-01", `lang: "fr"`, `content: "Post."`),
+		}, `lang: "fr" is not one of the languages`},
+		{"missing original", map[string]string{
+			"post.yaml": testPost("post", "2024-01-01", `lang: "hi"`, `translation_of: "nothing"`, `content: "Post."`),
+		}, `no post has the id "nothing"`},
+		{"same language", map[string]string{
+			"one.yaml": testPost("one", "2024-01-01", `content: "One."`),
+			"two.yaml": testPost("two", "2024-01-02", `translation_of: "one"`, `content: "Two."`),
+		}, `"one" is also in "en"`},
+		{"translation of a translation", map[string]string{
+			"one.yaml":   testPost("one", "2024-01-01", `content: "One."`),
+			"two.yaml":   testPost("two", "2024-01-02", `lang: "hi"`, `translation_of: "one"`, `content: "Two."`),
+			"three.yaml": testPost("three", "2024-01-03", `translation_of: "two"`, `content: "Three."`),
+		}, `"two" is itself a translation`},
+		{"two translations", map[string]string{
+			"one.yaml":   testPost("one", "2024-01-01", `content: "One."`),
+			"two.yaml":   testPost("two", "2024-01-02", `lang: "hi"`, `translation_of: "one"`, `content: "Two."`),
+			"three.yaml": testPost("three", "2024-01-03", `lang: "hi"`, `translation_of: "one"`, `content: "Three."`),
+		}, `already has a "hi" translation`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadBlogData(writeLanguagesTestBlog(t, tt.posts)); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	dir := writeTestBlog(t, nil)
+	config := testBlogsYAML + "languages:\n  - code: \"en-GB\"\n    name: \"English\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "blogs.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadBlogData(dir); err == nil || !strings.Contains(err.Error(), "language code") {
+		t.Errorf("Expected a language code error, got %v", err)
+	}
+}
+
+func TestLanguagePages(t *testing.T) {
+	loadLanguagesTestBlog(t)
+
+	get := func(handler func(http.ResponseWriter, *http.Request) error, path string, vars map[string]string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", path, nil), vars)
+		rr := httptest.NewRecorder()
+		if err := handler(rr, req); err != nil {
+			t.Fatalf("GET %s returned an error: %v", path, err)
+		}
+		return rr
+	}
+	expect := func(page, body string, wants ...string) {
+		t.Helper()
+		for _, want := range wants {
+			if !strings.Contains(body, want) {
+				t.Errorf("Expected %s to contain %s", page, want)
+			}
+		}
+	}
+
+	body := get(BlogPostHandler, "/writings/engines", map[string]string{"slug": "engines"}).Body.String()
+	expect("the original", body,
+		`<html lang="en">`,
+		`<link rel="alternate" hreflang="hi" href="https://example.com/hi/writings/engines-hi">`,
+		`<link rel="alternate" hreflang="x-default" href="https://example.com/writings/engines">`,
+		`<a href="/hi/writings/engines-hi" hreflang="hi" lang="hi" title="engines-hi">हिन्दी</a>`,
+	)
+
+	body = get(BlogPostHandler, "/hi/writings/engines-hi", map[string]string{"lang": "hi", "slug": "engines-hi"}).Body.String()
+	expect("the translation", body,
+		`<html lang="hi">`,
+		`<link rel="canonical" href="https://example.com/hi/writings/engines-hi">`,
+		`<a href="/hi/writings/tag/go"`,
+		`<a href="/hi/writings" style=`,
+		`"inLanguage":"hi"`,
+	)
+
+	rr := get(BlogPostHandler, "/writings/engines-hi", map[string]string{"slug": "engines-hi"})
+	if rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != "/hi/writings/engines-hi" {
+		t.Errorf("Expected a post under the wrong prefix to redirect to its own, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+
+	body = get(WritingsHandler, "/hi/writings", map[string]string{"lang": "hi"}).Body.String()
+	expect("the Hindi listing", body,
+		`href="/hi/writings/engines-hi"`,
+		`hx-get="/hi/writings/search"`,
+		`<link rel="alternate" hreflang="en" href="https://example.com/writings">`,
+		`title="test blog (RSS)" href="/hi/writings/feed.xml">`,
+	)
+	if strings.Contains(body, "only-en") {
+		t.Error("Expected the Hindi listing to leave out English posts")
+	}
+
+	body = get(BlogRSSHandler, "/hi/writings/feed.xml", map[string]string{"lang": "hi"}).Body.String()
+	expect("the Hindi feed", body,
+		"<language>hi</language>",
+		"<link>https://example.com/hi/writings/engines-hi</link>",
+		`<atom:link href="https://example.com/hi/writings/feed.xml"`,
+	)
+	if strings.Contains(body, "only-en") {
+		t.Error("Expected the Hindi feed to leave out English posts")
+	}
+
+	body = get(SitemapHandler, "/sitemap.xml", nil).Body.String()
+	expect("the sitemap", body,
+		"<loc>https://example.com/hi/writings</loc>",
+		"<loc>https://example.com/hi/writings/engines-hi</loc>",
+		"<loc>https://example.com/hi/writings/tag/go</loc>",
+		`<xhtml:link rel="alternate" hreflang="hi" href="https://example.com/hi/writings/engines-hi"></xhtml:link>`,
+	)
+
+	for code, want := range map[string]bool{"hi": true, "en": false, "fr": false} {
+		if got := IsLanguagePrefix(code); got != want {
+			t.Errorf("IsLanguagePrefix(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
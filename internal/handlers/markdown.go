@@ -4,17 +4,9 @@ import (
 	"net/http"
 	"path/filepath"
 
-	"github.com/thinkingojha/go-htmx/internal/logger"
 	"github.com/thinkingojha/go-htmx/internal/utils"
 )
 
-var markdownRenderer *utils.Markdown
-
-func init() {
-	markdownRenderer = &utils.Markdown{}
-	markdownRenderer.InitMarkdownRenderer()
-}
-
 func MarkdownHandler(w http.ResponseWriter, r *http.Request) error {
 	// Get the markdown content from query parameter or form
 	markdownContent := r.FormValue("content")
@@ -27,23 +19,19 @@ func MarkdownHandler(w http.ResponseWriter, r *http.Request) error {
 		return renderMarkdownEditor(w, r)
 	}
 
-	// Render markdown to HTML
-	htmlContent, err := markdownRenderer.RenderHTML([]byte(markdownContent))
-	if err != nil {
-		logger.Errorf("Failed to render markdown: %v", err)
-		return err
-	}
+	// Render markdown to HTML exactly as a published post would be
+	htmlContent, _ := utils.RenderMarkdownWithTOC(markdownContent)
 
 	// Check if this is an HTMX request (partial update)
 	if r.Header.Get("HX-Request") == "true" {
 		// Return just the rendered HTML for HTMX
 		w.Header().Set("Content-Type", "text/html")
-		w.Write(htmlContent)
+		w.Write([]byte(htmlContent))
 		return nil
 	}
 
 	// Return full page with rendered markdown
-	return renderMarkdownPage(w, r, string(htmlContent))
+	return renderMarkdownPage(w, r, htmlContent)
 }
 
 func renderMarkdownEditor(w http.ResponseWriter, r *http.Request) error {
@@ -84,6 +72,7 @@ func renderSimpleMarkdownEditor(w http.ResponseWriter, r *http.Request) error {
 <head>
     <title>Markdown Editor</title>
     <script src="https://unpkg.com/htmx.org@1.9.8"></script>
+    <link rel="stylesheet" href="/static/css/highlight.css">
     <style>
         body { font-family: Arial, sans-serif; margin: 20px; }
         .container { max-width: 1200px; margin: 0 auto; }
@@ -119,3 +108,17 @@ func renderSimpleMarkdownEditor(w http.ResponseWriter, r *http.Request) error {
 `))
 	return nil
 }
+
+// HighlightCSSHandler serves the stylesheet for syntax highlighted code
+// blocks, generated from the configured highlight style
+func HighlightCSSHandler(w http.ResponseWriter, r *http.Request) error {
+	css, err := utils.HighlightCSS()
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write([]byte(css))
+	return nil
+}
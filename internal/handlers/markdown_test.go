@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+func TestMarkdownHandlerExtensions(t *testing.T) {
+	source := "## Notes\n\nA claim[^1] -- with \"quotes\".\n\n[^1]: The source.\n\n- [x] done\n- [ ] todo\n\nTerm\n: Definition\n\n$$\n\\frac{1}{n}\n$$\n"
+
+	render := func() string {
+		form := "content=" + url.QueryEscape(source)
+		req := httptest.NewRequest("POST", "/write", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		rr := httptest.NewRecorder()
+		if err := MarkdownHandler(rr, req); err != nil {
+			t.Fatalf("MarkdownHandler returned an error: %v", err)
+		}
+		return rr.Body.String()
+	}
+
+	body := render()
+	published, _ := utils.RenderMarkdownWithTOC(source)
+	if body != published {
+		t.Errorf("Expected the /write preview to match published output\npreview:   %s\npublished: %s", body, published)
+	}
+	for _, want := range []string{
+		`<span class="sidenote">`,
+		`&ldquo;quotes&rdquo;`,
+		`<input type="checkbox" class="task" disabled checked> done`,
+		`<dt>Term</dt>`,
+		`<math xmlns="http://www.w3.org/1998/Math/MathML" display="block">`,
+		`<mfrac>`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected rendered markdown to contain %s, got: %s", want, body)
+		}
+	}
+
+	utils.SetMarkdownOptions(utils.MarkdownOptions{Footnotes: true})
+	defer utils.SetMarkdownOptions(utils.DefaultMarkdownOptions())
+
+	body = render()
+	for _, want := range []string{`<div class="footnotes">`, `<sup class="footnote-ref" id="fnref:1">`, `&quot;quotes&quot;`, `[x] done`, `$$`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected disabled extensions to leave %s, got: %s", want, body)
+		}
+	}
+}
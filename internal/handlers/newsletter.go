@@ -0,0 +1,689 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thinkingojha/go-htmx/internal/logger"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// Subscriber states
+const (
+	SubscriberPending      = "pending" // waiting for the address to be confirmed
+	SubscriberConfirmed    = "confirmed"
+	SubscriberUnsubscribed = "unsubscribed"
+	SubscriberBounced      = "bounced"
+)
+
+const (
+	// newsletterRetryWindow is how long a post is retried for subscribers
+	// whose mail server turned it away for the time being
+	newsletterRetryWindow = 24 * time.Hour
+
+	// newsletterResendAfter keeps the subscribe form from being used to
+	// flood someone's inbox with confirmation requests
+	newsletterResendAfter = time.Hour
+)
+
+// Subscriber is a newsletter subscriber. The token confirms the address and
+// unsubscribes it, so it is only ever sent to the subscriber.
+type Subscriber struct {
+	Email   string    `json:"email"`
+	Token   string    `json:"token"`
+	Status  string    `json:"status"`
+	Created time.Time `json:"created"`
+}
+
+// newsletterRecord is one line of the newsletter log
+type newsletterRecord struct {
+	Op     string    `json:"op"` // "subscribe", "status", "baseline", "delivered" or "sent"
+	Email  string    `json:"email,omitempty"`
+	Token  string    `json:"token,omitempty"`
+	Status string    `json:"status,omitempty"`
+	Post   string    `json:"post,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Newsletter keeps the subscribers and mails them new posts. Like comments,
+// its state is an append-only log, newsletter.jsonl, replayed on open.
+//
+// Posts dated before the newsletter first ran are never mailed, so the
+// archive is not sent out; every later post is sent once to each confirmed
+// subscriber when it goes live.
+type Newsletter struct {
+	mu          sync.Mutex
+	path        string
+	mailer      utils.Mailer
+	now         func() time.Time
+	subscribers map[string]*Subscriber // by address
+	tokens      map[string]*Subscriber
+	baseline    time.Time                  // when the newsletter first ran
+	sent        map[string]bool            // posts that have gone out
+	delivered   map[string]map[string]bool // addresses each post reached
+	firstTry    map[string]time.Time       // when sending each post began
+	confirmSent map[string]time.Time       // when each address was last asked to confirm
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenNewsletter opens the newsletter log in dir, creating the directory if
+// needed. Mail goes out through mailer.
+func OpenNewsletter(dir string, mailer utils.Mailer) (*Newsletter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create newsletter directory: %w", err)
+	}
+	n := &Newsletter{
+		path:        filepath.Join(dir, "newsletter.jsonl"),
+		mailer:      mailer,
+		now:         time.Now,
+		subscribers: make(map[string]*Subscriber),
+		tokens:      make(map[string]*Subscriber),
+		sent:        make(map[string]bool),
+		delivered:   make(map[string]map[string]bool),
+		firstTry:    make(map[string]time.Time),
+		confirmSent: make(map[string]time.Time),
+	}
+	err := replayJSONLines(n.path, func(line []byte) error {
+		var record newsletterRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return err
+		}
+		return n.apply(record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// apply replays one record of the log
+func (n *Newsletter) apply(record newsletterRecord) error {
+	switch record.Op {
+	case "subscribe":
+		if previous, ok := n.subscribers[record.Email]; ok {
+			delete(n.tokens, previous.Token)
+		}
+		s := &Subscriber{Email: record.Email, Token: record.Token, Status: record.Status, Created: record.At}
+		n.subscribers[s.Email] = s
+		n.tokens[s.Token] = s
+	case "status":
+		s, ok := n.subscribers[record.Email]
+		if !ok {
+			return fmt.Errorf("status of unknown subscriber %q", record.Email)
+		}
+		s.Status = record.Status
+	case "baseline":
+		n.baseline = record.At
+	case "delivered":
+		if n.delivered[record.Post] == nil {
+			n.delivered[record.Post] = make(map[string]bool)
+		}
+		n.delivered[record.Post][record.Email] = true
+	case "sent":
+		n.sent[record.Post] = true
+		delete(n.delivered, record.Post)
+	default:
+		return fmt.Errorf("unknown op %q", record.Op)
+	}
+	return nil
+}
+
+// append writes a record to the end of the log and applies it
+func (n *Newsletter) append(record newsletterRecord) error {
+	record.At = n.now().UTC()
+	if err := appendJSONLine(n.path, record); err != nil {
+		return fmt.Errorf("failed to save newsletter: %w", err)
+	}
+	return n.apply(record)
+}
+
+// Subscribe adds a pending subscriber and mails them a link to confirm.
+// Nothing is sent to an address that is already subscribed, or that was
+// asked to confirm within the hour, so the result does not reveal who
+// subscribes.
+func (n *Newsletter) Subscribe(blogData *BlogData, address string) error {
+	n.mu.Lock()
+	s, ok := n.subscribers[address]
+	switch {
+	case ok && s.Status == SubscriberConfirmed:
+		n.mu.Unlock()
+		return nil
+	case ok && s.Status == SubscriberPending:
+		if n.now().Sub(n.confirmSent[address]) < newsletterResendAfter {
+			n.mu.Unlock()
+			return nil
+		}
+	default:
+		token, err := newNewsletterToken()
+		if err != nil {
+			n.mu.Unlock()
+			return err
+		}
+		if err := n.append(newsletterRecord{Op: "subscribe", Email: address, Token: token, Status: SubscriberPending}); err != nil {
+			n.mu.Unlock()
+			return err
+		}
+		s = n.subscribers[address]
+	}
+	n.confirmSent[address] = n.now()
+	subscriber := *s
+	n.mu.Unlock()
+
+	msg, err := confirmEmail(blogData, subscriber)
+	if err == nil {
+		err = n.mailer.Send(msg)
+	}
+	if err != nil {
+		// Let the reader try again straight away
+		n.mu.Lock()
+		delete(n.confirmSent, address)
+		n.mu.Unlock()
+	}
+	return err
+}
+
+// Confirm confirms the subscription the token was mailed for. A token whose
+// subscriber has since unsubscribed or bounced confirms nothing.
+func (n *Newsletter) Confirm(token string) (Subscriber, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	s, ok := n.tokens[token]
+	if !ok || (s.Status != SubscriberPending && s.Status != SubscriberConfirmed) {
+		return Subscriber{}, false
+	}
+	if s.Status == SubscriberPending {
+		if err := n.append(newsletterRecord{Op: "status", Email: s.Email, Status: SubscriberConfirmed}); err != nil {
+			logger.Errorf("%v", err)
+			return Subscriber{}, false
+		}
+	}
+	return *s, true
+}
+
+// Unsubscribe ends the subscription the token belongs to
+func (n *Newsletter) Unsubscribe(token string) (Subscriber, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	s, ok := n.tokens[token]
+	if !ok {
+		return Subscriber{}, false
+	}
+	if s.Status != SubscriberUnsubscribed {
+		if err := n.append(newsletterRecord{Op: "status", Email: s.Email, Status: SubscriberUnsubscribed}); err != nil {
+			logger.Errorf("%v", err)
+			return Subscriber{}, false
+		}
+	}
+	return *s, true
+}
+
+// Bounce stops mail to an address its mail server refused
+func (n *Newsletter) Bounce(address string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	s, ok := n.subscribers[address]
+	if !ok {
+		return false
+	}
+	if s.Status != SubscriberBounced {
+		if err := n.append(newsletterRecord{Op: "status", Email: s.Email, Status: SubscriberBounced}); err != nil {
+			logger.Errorf("%v", err)
+			return false
+		}
+		logger.Infof("Newsletter subscriber %s bounced", address)
+	}
+	return true
+}
+
+// Subscribers returns the subscribers in the given state, by address
+func (n *Newsletter) Subscribers(status string) []Subscriber {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var subscribers []Subscriber
+	for _, s := range n.subscribers {
+		if s.Status == status {
+			subscribers = append(subscribers, *s)
+		}
+	}
+	sort.Slice(subscribers, func(i, j int) bool { return subscribers[i].Email < subscribers[j].Email })
+	return subscribers
+}
+
+// DeliverNewPosts mails the posts that went live since the newsletter first
+// ran and have not gone out yet, oldest first, and returns how many emails
+// were sent. An address refused for good is marked bounced; one refused for
+// now is tried again on later calls for a day.
+func (n *Newsletter) DeliverNewPosts(blogData *BlogData) int {
+	n.mu.Lock()
+	if n.baseline.IsZero() {
+		// Don't mail the whole archive the first time around
+		if err := n.append(newsletterRecord{Op: "baseline"}); err != nil {
+			logger.Errorf("%v", err)
+		}
+		n.mu.Unlock()
+		return 0
+	}
+	var posts []BlogPost
+	for _, post := range blogData.Posts {
+		if !n.sent[post.Slug] && post.PublishDate.After(n.baseline) {
+			posts = append(posts, post)
+		}
+	}
+	n.mu.Unlock()
+
+	sort.Slice(posts, func(i, j int) bool { return posts[i].PublishDate.Before(posts[j].PublishDate) })
+	count := 0
+	for _, post := range posts {
+		count += n.deliverPost(blogData, post)
+	}
+	return count
+}
+
+func (n *Newsletter) deliverPost(blogData *BlogData, post BlogPost) int {
+	n.mu.Lock()
+	if _, ok := n.firstTry[post.Slug]; !ok {
+		n.firstTry[post.Slug] = n.now()
+	}
+	var recipients []Subscriber
+	for _, s := range n.subscribers {
+		if s.Status == SubscriberConfirmed && !n.delivered[post.Slug][s.Email] {
+			recipients = append(recipients, *s)
+		}
+	}
+	n.mu.Unlock()
+
+	count, retry := 0, false
+	for _, s := range recipients {
+		msg, err := postEmail(blogData, post, s)
+		if err != nil {
+			// Retried like a failed send, so a broken template is not
+			// logged on every tick forever
+			logger.Errorf("Failed to render newsletter for %s: %v", post.Slug, err)
+			retry = true
+			break
+		}
+		if err := n.mailer.Send(msg); err != nil {
+			if utils.IsPermanentMailError(err) {
+				n.Bounce(s.Email)
+				continue
+			}
+			logger.Warnf("Newsletter for %s to %s failed, will retry: %v", post.Slug, s.Email, err)
+			retry = true
+			continue
+		}
+		count++
+		n.mu.Lock()
+		if err := n.append(newsletterRecord{Op: "delivered", Post: post.Slug, Email: s.Email}); err != nil {
+			logger.Errorf("%v", err)
+		}
+		n.mu.Unlock()
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if retry && n.now().Sub(n.firstTry[post.Slug]) < newsletterRetryWindow {
+		return count
+	}
+	if err := n.append(newsletterRecord{Op: "sent", Post: post.Slug}); err != nil {
+		logger.Errorf("%v", err)
+	}
+	delete(n.firstTry, post.Slug)
+	logger.Infof("Newsletter for %s sent to %d subscribers", post.Slug, count)
+	return count
+}
+
+// Start looks for newly published posts every interval until Close
+func (n *Newsletter) Start(interval time.Duration) {
+	n.stop, n.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(n.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if blogData, err := content().Blog(); err == nil {
+				n.DeliverNewPosts(blogData)
+			}
+			select {
+			case <-ticker.C:
+			case <-n.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops looking for new posts
+func (n *Newsletter) Close() error {
+	if n.stop != nil {
+		close(n.stop)
+		<-n.done
+		n.stop = nil
+	}
+	return nil
+}
+
+func newNewsletterToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// parseSubscriberAddress checks a submitted email address and returns it in
+// the form subscribers are stored under
+func parseSubscriberAddress(s string) (string, bool) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(s))
+	if err != nil || addr.Name != "" || !strings.Contains(addr.Address[strings.LastIndexByte(addr.Address, '@')+1:], ".") {
+		return "", false
+	}
+	return strings.ToLower(addr.Address), true
+}
+
+// newsletterEmailData is what the email templates show
+type newsletterEmailData struct {
+	SiteURL        string
+	SiteTitle      string
+	Post           *BlogPost
+	PostURL        string
+	PostHTML       template.HTML
+	ConfirmURL     string
+	UnsubscribeURL string
+}
+
+func newsletterEmail(blogData *BlogData, s Subscriber) newsletterEmailData {
+	siteURL := blogData.siteURL()
+	return newsletterEmailData{
+		SiteURL:        siteURL,
+		SiteTitle:      blogData.Title,
+		ConfirmURL:     siteURL + "/newsletter/confirm?token=" + url.QueryEscape(s.Token),
+		UnsubscribeURL: siteURL + "/newsletter/unsubscribe?token=" + url.QueryEscape(s.Token),
+	}
+}
+
+// renderEmail executes one of the email templates
+func renderEmail(name string, data newsletterEmailData) (string, error) {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return "", err
+	}
+	templates, err = templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "email", "*.html"))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// confirmEmail asks a new subscriber to confirm their address
+func confirmEmail(blogData *BlogData, s Subscriber) (utils.MailMessage, error) {
+	data := newsletterEmail(blogData, s)
+	html, err := renderEmail("email-confirm", data)
+	if err != nil {
+		return utils.MailMessage{}, err
+	}
+	return utils.MailMessage{
+		To:      s.Email,
+		Subject: "Confirm your subscription to " + data.SiteTitle,
+		HTML:    html,
+		Text: "Someone, hopefully you, asked to get new posts from " + data.SiteTitle + " by email.\n\n" +
+			"Confirm your subscription:\n" + data.ConfirmURL + "\n\n" +
+			"If it wasn't you, ignore this email and nothing more will be sent.\n",
+	}, nil
+}
+
+// postEmail is a new post, as mailed to one subscriber
+func postEmail(blogData *BlogData, post BlogPost, s Subscriber) (utils.MailMessage, error) {
+	data := newsletterEmail(blogData, s)
+	data.Post = &post
+	data.PostURL = data.SiteURL + post.URL()
+	data.PostHTML = template.HTML(absoluteLinks(data.SiteURL, utils.MarkdownToHTML(post.Content)))
+	html, err := renderEmail("email-post", data)
+	if err != nil {
+		return utils.MailMessage{}, err
+	}
+
+	text := post.Title + "\n\n"
+	if post.Excerpt != "" {
+		text += post.Excerpt + "\n\n"
+	}
+	text += "Read it at " + data.PostURL + "\n\n--\nUnsubscribe: " + data.UnsubscribeURL + "\n"
+	return utils.MailMessage{
+		To:      s.Email,
+		Subject: post.Title,
+		HTML:    html,
+		Text:    text,
+		Headers: map[string]string{
+			// One click unsubscribe (RFC 8058): mail clients POST to the link
+			"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	}, nil
+}
+
+var siteRelativeAttr = regexp.MustCompile(`(\s(?:href|src)=")(/[^/"][^"]*|/)"`)
+
+// absoluteLinks points the site-relative links and images of rendered HTML
+// at siteURL, since an email has no page to be relative to
+func absoluteLinks(siteURL, html string) string {
+	return siteRelativeAttr.ReplaceAllString(html, `${1}`+siteURL+`${2}"`)
+}
+
+// newsletter mails new posts to subscribers; nil while it is disabled
+var newsletter *Newsletter
+
+// SetNewsletter sets the newsletter the subscribe form signs readers up to
+func SetNewsletter(n *Newsletter) {
+	newsletter = n
+}
+
+// NewsletterForm is the subscribe form, as submitted when it is shown again
+// with the reason it was rejected
+type NewsletterForm struct {
+	Email string
+	Error string
+}
+
+type NewsletterPageData struct {
+	PageName     string
+	Title        string
+	Description  string
+	CanonicalURL string
+	OgImage      string
+	Heading      string
+	Message      string
+	Action       string // asks first, with a button posting the token here
+	Button       string // label of that button
+	Token        string
+}
+
+func renderNewsletterPage(w http.ResponseWriter, status int, data NewsletterPageData) error {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return err
+	}
+	templates, err = templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "newsletter", "*.html"))
+	if err != nil {
+		return err
+	}
+	data.PageName = "writings"
+	data.Title = "Newsletter"
+	if blogData, err := content().Blog(); err == nil {
+		data.CanonicalURL = blogData.siteURL() + "/newsletter"
+	}
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "newsletter", data); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// NewsletterSubscribeHandler signs a reader up and mails them a link to
+// confirm. HTMX requests get the form back with the problem, or a note to
+// check their inbox in its place.
+func NewsletterSubscribeHandler(w http.ResponseWriter, r *http.Request) error {
+	if newsletter == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return nil
+	}
+	htmx := r.Header.Get("HX-Request") == "true"
+	templates, err := blogTemplates()
+	if err != nil {
+		return err
+	}
+
+	form := NewsletterForm{Email: strings.TrimSpace(r.PostForm.Get("email"))}
+	address, ok := parseSubscriberAddress(form.Email)
+	if !ok {
+		form.Error = "Please give a valid email address."
+		if !htmx {
+			return renderNewsletterPage(w, http.StatusBadRequest, NewsletterPageData{Heading: "newsletter", Message: form.Error})
+		}
+		return templates.ExecuteTemplate(w, "newsletter-form", form)
+	}
+
+	// The website field is hidden from people; only bots fill it in
+	if r.PostForm.Get("website") == "" {
+		if err := newsletter.Subscribe(blogData, address); err != nil {
+			logger.Errorf("Failed to subscribe %s: %v", address, err)
+			form.Error = "Something went wrong sending the confirmation email. Please try again later."
+			if !htmx {
+				return renderNewsletterPage(w, http.StatusServiceUnavailable, NewsletterPageData{Heading: "newsletter", Message: form.Error})
+			}
+			return templates.ExecuteTemplate(w, "newsletter-form", form)
+		}
+	}
+
+	if !htmx {
+		return renderNewsletterPage(w, http.StatusOK, NewsletterPageData{
+			Heading: "check your inbox",
+			Message: "a link to confirm your subscription is on its way to " + address + ".",
+		})
+	}
+	return templates.ExecuteTemplate(w, "newsletter-result", address)
+}
+
+// NewsletterConfirmHandler confirms a subscription from the link in the
+// confirmation email. Like unsubscribing, the link asks first, so link
+// scanners following it confirm no one; the reader's click posts to it.
+func NewsletterConfirmHandler(w http.ResponseWriter, r *http.Request) error {
+	if newsletter == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	token := r.URL.Query().Get("token")
+	if r.Method != http.MethodPost {
+		return renderNewsletterPage(w, http.StatusOK, NewsletterPageData{
+			Heading: "confirm",
+			Message: "get new writing by email?",
+			Action:  "/newsletter/confirm",
+			Button:  "confirm subscription",
+			Token:   token,
+		})
+	}
+
+	if token == "" {
+		token = r.PostFormValue("token")
+	}
+	s, ok := newsletter.Confirm(token)
+	if !ok {
+		return renderNewsletterPage(w, http.StatusNotFound, NewsletterPageData{
+			Heading: "link expired",
+			Message: "this confirmation link is no longer valid. subscribe again to get a new one.",
+		})
+	}
+	return renderNewsletterPage(w, http.StatusOK, NewsletterPageData{
+		Heading: "you're subscribed",
+		Message: "new writing will arrive at " + s.Email + ". every email has a link to unsubscribe.",
+	})
+}
+
+// NewsletterUnsubscribeHandler ends a subscription. The link in each email
+// asks first, so link scanners following it unsubscribe no one; mail
+// clients unsubscribe in one click by posting to it.
+func NewsletterUnsubscribeHandler(w http.ResponseWriter, r *http.Request) error {
+	if newsletter == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	token := r.URL.Query().Get("token")
+	if r.Method != http.MethodPost {
+		return renderNewsletterPage(w, http.StatusOK, NewsletterPageData{
+			Heading: "unsubscribe",
+			Message: "stop getting new writing by email?",
+			Action:  "/newsletter/unsubscribe",
+			Button:  "unsubscribe",
+			Token:   token,
+		})
+	}
+
+	if token == "" {
+		token = r.PostFormValue("token")
+	}
+	s, ok := newsletter.Unsubscribe(token)
+	if !ok {
+		return renderNewsletterPage(w, http.StatusNotFound, NewsletterPageData{
+			Heading: "link expired",
+			Message: "this unsubscribe link is not valid.",
+		})
+	}
+	return renderNewsletterPage(w, http.StatusOK, NewsletterPageData{
+		Heading: "unsubscribed",
+		Message: s.Email + " won't get any more emails.",
+	})
+}
+
+// NewsletterBounceHandler marks an address as bounced, for a mail server or
+// bounce processor to call with the admin password
+func NewsletterBounceHandler(w http.ResponseWriter, r *http.Request) error {
+	if !requireAdmin(w, r) {
+		return nil
+	}
+	if newsletter == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+	address, ok := parseSubscriberAddress(r.PostFormValue("email"))
+	if !ok || !newsletter.Bounce(address) {
+		http.NotFound(w, r)
+		return nil
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
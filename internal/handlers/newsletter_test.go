@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// testSMTPServer is just enough of an SMTP server for net/smtp to deliver
+// to. It refuses mail to the addresses in reject with a 550.
+type testSMTPServer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	messages []*mail.Message
+	reject   map[string]bool
+}
+
+func startTestSMTP(t *testing.T) *testSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testSMTPServer{listener: listener, reject: make(map[string]bool)}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *testSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+	reply("220 test ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO", "MAIL", "RSET", "NOOP":
+			reply("250 ok")
+		case "RCPT":
+			to := strings.Trim(line[strings.IndexByte(line, ':')+1:], "<> ")
+			s.mu.Lock()
+			rejected := s.reject[to]
+			s.mu.Unlock()
+			if rejected {
+				reply("550 5.1.1 no such user")
+			} else {
+				reply("250 ok")
+			}
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			msg, err := mail.ReadMessage(strings.NewReader(data.String()))
+			if err != nil {
+				reply("554 " + err.Error())
+				continue
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (s *testSMTPServer) rejectAddress(address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reject[address] = true
+}
+
+// sent returns the messages delivered so far
+func (s *testSMTPServer) sent() []*mail.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*mail.Message(nil), s.messages...)
+}
+
+func (s *testSMTPServer) mailer() *utils.SMTPMailer {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &utils.SMTPMailer{Host: "127.0.0.1", Port: addr.Port, From: "Test Blog <blog@example.com>"}
+}
+
+// mailText returns the plain text part of a message
+func mailText(t *testing.T, msg *mail.Message) string {
+	t.Helper()
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	part, err := parts.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := io.ReadAll(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(text)
+}
+
+var testConfirmLink = regexp.MustCompile(`/newsletter/confirm\?token=([0-9a-f]+)`)
+
+func useTestNewsletter(t *testing.T, server *testSMTPServer) *Newsletter {
+	t.Helper()
+
+	n, err := OpenNewsletter(t.TempDir(), server.mailer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := newsletter
+	SetNewsletter(n)
+	t.Cleanup(func() { newsletter = previous })
+	return n
+}
+
+func TestNewsletterDelivery(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"old.yaml": testPost("old", "2024-01-01"),
+		"new.yaml": testPost("new", "2024-07-01", `category: "engineering"`, `content: "See [the old post](/writings/old)."`),
+	})
+	blogData, err := loadBlogData(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startTestSMTP(t)
+	newsletterDir := t.TempDir()
+	n, err := OpenNewsletter(newsletterDir, server.mailer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+
+	// Subscribing mails a confirmation link, once an hour at most
+	if err := n.Subscribe(blogData, "ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Subscribe(blogData, "ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	sent := server.sent()
+	if len(sent) != 1 || sent[0].Header.Get("To") != "ada@example.com" {
+		t.Fatalf("Expected one confirmation email to ada, got %d", len(sent))
+	}
+	link := testConfirmLink.FindStringSubmatch(mailText(t, sent[0]))
+	if link == nil {
+		t.Fatalf("Expected a confirmation link in:\n%s", mailText(t, sent[0]))
+	}
+	if _, ok := n.Confirm("wrong"); ok {
+		t.Error("Expected an unknown token to confirm nothing")
+	}
+	if s, ok := n.Confirm(link[1]); !ok || s.Status != SubscriberConfirmed {
+		t.Fatalf("Expected the link to confirm ada, got %+v", s)
+	}
+
+	n.Subscribe(blogData, "gone@example.com")
+	gone := n.Subscribers(SubscriberPending)[0]
+	n.Confirm(gone.Token)
+	n.Subscribe(blogData, "never@example.com")
+	server.rejectAddress("gone@example.com")
+
+	// The first run only notes where the newsletter starts
+	if count := n.DeliverNewPosts(blogData); count != 0 {
+		t.Fatalf("Expected nothing sent on the first run, got %d", count)
+	}
+	before := len(server.sent())
+	if count := n.DeliverNewPosts(blogData); count != 1 {
+		t.Fatalf("Expected the new post to go to ada only, got %d", count)
+	}
+	sent = server.sent()[before:]
+	if len(sent) != 1 {
+		t.Fatalf("Expected one post email, got %d", len(sent))
+	}
+	msg := sent[0]
+	if msg.Header.Get("Subject") != "new" || msg.Header.Get("To") != "ada@example.com" {
+		t.Errorf("Expected the new post mailed to ada, got %q to %q", msg.Header.Get("Subject"), msg.Header.Get("To"))
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "<https://example.com/newsletter/unsubscribe?token="+link[1]+">" {
+		t.Errorf("Expected a List-Unsubscribe link, got %q", got)
+	}
+	if msg.Header.Get("List-Unsubscribe-Post") != "List-Unsubscribe=One-Click" {
+		t.Error("Expected one click unsubscribe")
+	}
+	if bounced := n.Subscribers(SubscriberBounced); len(bounced) != 1 || bounced[0].Email != "gone@example.com" {
+		t.Errorf("Expected gone to bounce, got %+v", bounced)
+	}
+	if count := n.DeliverNewPosts(blogData); count != 0 {
+		t.Errorf("Expected each post to be sent once, got %d more", count)
+	}
+
+	// The log is replayed when the newsletter is opened again
+	reopened, err := OpenNewsletter(newsletterDir, server.mailer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := reopened.DeliverNewPosts(blogData); count != 0 {
+		t.Errorf("Expected nothing sent after reopening, got %d", count)
+	}
+	if confirmed := reopened.Subscribers(SubscriberConfirmed); len(confirmed) != 1 || confirmed[0].Email != "ada@example.com" {
+		t.Errorf("Expected ada to stay confirmed, got %+v", confirmed)
+	}
+	if s, ok := reopened.Unsubscribe(link[1]); !ok || s.Status != SubscriberUnsubscribed {
+		t.Errorf("Expected ada to be unsubscribed, got %+v", s)
+	}
+	if _, ok := reopened.Confirm(link[1]); ok {
+		t.Error("Expected an unsubscribed token to confirm nothing")
+	}
+}
+
+func TestNewsletterDeliveryRenderFailure(t *testing.T) {
+	blogData, err := loadBlogData(writeTestBlog(t, map[string]string{
+		"new.yaml": testPost("new", "2024-07-01", `content: "New."`),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startTestSMTP(t)
+	n, err := OpenNewsletter(t.TempDir(), server.mailer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+	if err := n.Subscribe(blogData, "ada@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	n.Confirm(n.Subscribers(SubscriberPending)[0].Token)
+	n.DeliverNewPosts(blogData)
+
+	// Without the email templates the post is retried, then given up on
+	basePath := utils.Templates.BasePath
+	utils.Templates.BasePath = t.TempDir()
+	t.Cleanup(func() { utils.Templates.BasePath = basePath })
+	before := len(server.sent())
+	if count := n.DeliverNewPosts(blogData); count != 0 {
+		t.Fatalf("Expected nothing sent without templates, got %d", count)
+	}
+	if _, ok := n.firstTry["new"]; !ok {
+		t.Fatal("Expected the post to be retried")
+	}
+	now = now.Add(newsletterRetryWindow)
+	n.DeliverNewPosts(blogData)
+	if _, ok := n.firstTry["new"]; ok {
+		t.Error("Expected the post to be given up on after the retry window")
+	}
+
+	utils.Templates.BasePath = basePath
+	if count := n.DeliverNewPosts(blogData); count != 0 || len(server.sent()) != before {
+		t.Errorf("Expected the post given up on to stay unsent, got %d", count)
+	}
+}
+
+func TestNewsletterHandlers(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01"),
+	})
+	loadTestBlog(t, dir)
+	server := startTestSMTP(t)
+	n := useTestNewsletter(t, server)
+
+	subscribe := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/newsletter/subscribe", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("HX-Request", "true")
+		rr := httptest.NewRecorder()
+		if err := NewsletterSubscribeHandler(rr, req); err != nil {
+			t.Fatalf("NewsletterSubscribeHandler returned an error: %v", err)
+		}
+		return rr
+	}
+	if rr := subscribe(url.Values{"email": {"not an address"}}); !strings.Contains(rr.Body.String(), "Please give a valid email address.") {
+		t.Errorf("Expected the form back with an error, got:\n%s", rr.Body.String())
+	}
+	if rr := subscribe(url.Values{"email": {"bot@example.com"}, "website": {"http://spam"}}); !strings.Contains(rr.Body.String(), "check bot@example.com") {
+		t.Errorf("Expected the honeypot to look like success, got:\n%s", rr.Body.String())
+	}
+	if rr := subscribe(url.Values{"email": {" Ada@Example.com "}}); !strings.Contains(rr.Body.String(), "check ada@example.com") {
+		t.Errorf("Expected a note to check the inbox, got:\n%s", rr.Body.String())
+	}
+	if sent := server.sent(); len(sent) != 1 || sent[0].Header.Get("To") != "ada@example.com" {
+		t.Fatalf("Expected only ada to be mailed, got %d emails", len(sent))
+	}
+	token := n.Subscribers(SubscriberPending)[0].Token
+
+	get := func(handler func(http.ResponseWriter, *http.Request) error, method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		if err := handler(rr, httptest.NewRequest(method, target, nil)); err != nil {
+			t.Fatalf("%s %s returned an error: %v", method, target, err)
+		}
+		return rr
+	}
+	// Following the confirmation link only asks; posting to it confirms
+	if rr := get(NewsletterConfirmHandler, "POST", "/newsletter/confirm?token=wrong"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown token, got %d", rr.Code)
+	}
+	rr := get(NewsletterConfirmHandler, "GET", "/newsletter/confirm?token="+token)
+	if !strings.Contains(rr.Body.String(), `<link rel="canonical" href="https://example.com/newsletter">`) {
+		t.Error("Expected the newsletter pages to be canonical on the configured site")
+	}
+	if !strings.Contains(rr.Body.String(), `action="/newsletter/confirm?token=`+token+`"`) {
+		t.Errorf("Expected a form to confirm subscribing, got:\n%s", rr.Body.String())
+	}
+	if len(n.Subscribers(SubscriberPending)) != 1 {
+		t.Error("Expected following the link to leave the subscription pending")
+	}
+	if rr := get(NewsletterConfirmHandler, "POST", "/newsletter/confirm?token="+token); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "new writing will arrive at ada@example.com") {
+		t.Errorf("Expected the subscription to be confirmed, got %d:\n%s", rr.Code, rr.Body.String())
+	}
+
+	// Following the unsubscribe link only asks; posting to it unsubscribes
+	rr = get(NewsletterUnsubscribeHandler, "GET", "/newsletter/unsubscribe?token="+token)
+	if !strings.Contains(rr.Body.String(), `action="/newsletter/unsubscribe?token=`+token+`"`) {
+		t.Errorf("Expected a form to confirm unsubscribing, got:\n%s", rr.Body.String())
+	}
+	if len(n.Subscribers(SubscriberConfirmed)) != 1 {
+		t.Error("Expected following the link to leave the subscription alone")
+	}
+	if rr := get(NewsletterUnsubscribeHandler, "POST", "/newsletter/unsubscribe?token="+token); rr.Code != http.StatusOK || len(n.Subscribers(SubscriberUnsubscribed)) != 1 {
+		t.Errorf("Expected one click to unsubscribe, got %d", rr.Code)
+	}
+
+	if rr := get(NewsletterBounceHandler, "POST", "/newsletter/bounce"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected bounces to be off without an admin token, got %d", rr.Code)
+	}
+
+	// Posts offer the subscribe form
+	req := httptest.NewRequest("GET", "/writings/one", nil)
+	req = mux.SetURLVars(req, map[string]string{"slug": "one"})
+	rr = httptest.NewRecorder()
+	if err := BlogPostHandler(rr, req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rr.Body.String(), `hx-post="/newsletter/subscribe"`) {
+		t.Error("Expected the post to include the subscribe form")
+	}
+}
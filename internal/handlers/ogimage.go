@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/logger"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// ogCardVersion is part of every card's cache key; bump it when the card
+// layout changes so cached cards are drawn again
+const ogCardVersion = 1
+
+// ogCacheDir holds generated Open Graph cards; cards are drawn on every
+// request while it is empty
+var ogCacheDir string
+
+// SetOGCacheDir sets the directory generated Open Graph cards are cached in
+func SetOGCacheDir(dir string) {
+	ogCacheDir = dir
+}
+
+// OGImageURL returns the post's Open Graph image: meta.og_image when set,
+// otherwise the card generated for it
+func (p BlogPost) OGImageURL() string {
+	if p.Meta.OGImage != "" {
+		return p.Meta.OGImage
+	}
+	return "/writings/" + url.PathEscape(p.Slug) + "/og.png"
+}
+
+// ogCard returns what the post's generated card shows
+func (b *BlogData) ogCard(post BlogPost) utils.OGCard {
+	card := utils.OGCard{
+		Title:   post.Title,
+		Details: post.FormatDate() + " · " + post.ReadingTimeText(),
+	}
+	if site, err := url.Parse(b.Meta.SiteURL); err == nil {
+		card.Site = site.Host
+	}
+	if category := getCategoryBySlug(b.Categories, post.Category); category != nil {
+		card.Category = category.Name
+		card.Accent = parseHexColor(category.Swatch())
+	}
+	return card
+}
+
+// ogCardKey hashes everything a card shows, so a card is only drawn again
+// when its content changes
+func ogCardKey(card utils.OGCard) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%s\x00%s\x00%s\x00%s\x00", ogCardVersion, card.Title, card.Category, card.Details, card.Site)
+	if card.Accent != nil {
+		r, g, b, a := card.Accent.RGBA()
+		fmt.Fprintf(h, "%d,%d,%d,%d", r, g, b, a)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// parseHexColor reads a #rgb or #rrggbb color, or returns nil
+func parseHexColor(s string) color.Color {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return nil
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return nil
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+// BlogOGImageHandler serves /writings/{slug}/og.png, the generated Open Graph
+// card of a published post
+func BlogOGImageHandler(w http.ResponseWriter, r *http.Request) error {
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+
+	post := blogData.PostBySlug(mux.Vars(r)["slug"])
+	if post == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	card := blogData.ogCard(*post)
+	key := ogCardKey(card)
+	data, err := cachedOGCard(key, card)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	// Cards only change with their key, so unlike pages they may be cached
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Header().Del("Pragma")
+	w.Header().Del("Expires")
+	w.Header().Set("ETag", `"`+key+`"`)
+	http.ServeContent(w, r, "og.png", time.Time{}, bytes.NewReader(data))
+	return nil
+}
+
+// cachedOGCard returns the card cached under key, drawing and caching it
+// first if needed. A card that cannot be cached is still served.
+func cachedOGCard(key string, card utils.OGCard) ([]byte, error) {
+	var path string
+	if ogCacheDir != "" {
+		path = filepath.Join(ogCacheDir, key+".png")
+		if data, err := os.ReadFile(path); err == nil {
+			return data, nil
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := utils.RenderOGCard(&buf, card); err != nil {
+		return nil, fmt.Errorf("drawing Open Graph card: %w", err)
+	}
+	if path != "" {
+		if err := writeFileAtomic(path, buf.Bytes()); err != nil {
+			logger.Warnf("Could not cache Open Graph card: %v", err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes data to path through a temporary file, so concurrent
+// readers never see a partial file
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
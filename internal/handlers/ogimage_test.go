@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBlogOGImageHandler(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01", `category: "engineering"`, `tags: ["go"]`, `content: "One."`),
+	})
+	blogData := loadTestBlog(t, dir)
+
+	cacheDir := t.TempDir()
+	previous := ogCacheDir
+	SetOGCacheDir(cacheDir)
+	t.Cleanup(func() { SetOGCacheDir(previous) })
+
+	serve := func(slug, etag string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/writings/"+slug+"/og.png", nil), map[string]string{"slug": slug})
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		if err := BlogOGImageHandler(rr, req); err != nil {
+			t.Fatalf("BlogOGImageHandler returned an error: %v", err)
+		}
+		return rr
+	}
+
+	rr := serve("one", "")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Expected a PNG, got status %d and type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	img, err := png.Decode(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != 1200 || size.Y != 630 {
+		t.Errorf("Expected a 1200×630 card, got %v", size)
+	}
+
+	etag := rr.Header().Get("ETag")
+	cached, _ := filepath.Glob(filepath.Join(cacheDir, "*.png"))
+	if len(cached) != 1 || "\""+strings.TrimSuffix(filepath.Base(cached[0]), ".png")+"\"" != etag {
+		t.Fatalf("Expected the card cached under its ETag %s, got %v", etag, cached)
+	}
+
+	// A cached card is served from disk
+	if err := os.WriteFile(cached[0], []byte("cached"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if body := serve("one", "").Body.String(); body != "cached" {
+		t.Error("Expected the cached card to be served")
+	}
+	if rr := serve("one", etag); rr.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rr.Code)
+	}
+
+	// Changing what the card shows changes its key
+	blogData.Posts[0].Title = "Renamed"
+	blogData.buildIndexes()
+	if rr := serve("one", ""); rr.Header().Get("ETag") == etag {
+		t.Error("Expected a new card after the title changed")
+	}
+
+	if rr := serve("missing", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown post, got %d", rr.Code)
+	}
+}
+
+func TestOGImageDefault(t *testing.T) {
+	post := BlogPost{Slug: "one"}
+	if got := post.OGImageURL(); got != "/writings/one/og.png" {
+		t.Errorf("Expected the generated card by default, got %s", got)
+	}
+	post.Meta.OGImage = "/static/one.png"
+	if got := post.OGImageURL(); got != "/static/one.png" {
+		t.Errorf("Expected meta.og_image to win, got %s", got)
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// previewSecret signs draft preview links; previews are disabled while empty
+var previewSecret []byte
+
+// SetPreviewSecret sets the key used to sign and verify draft preview links
+func SetPreviewSecret(secret string) {
+	previewSecret = []byte(secret)
+}
+
+// PreviewPath returns a signed /writings/{slug}?preview=... path that renders
+// the post, draft or not, until expires
+func PreviewPath(blogData *BlogData, slug string, expires time.Time) (string, error) {
+	if post, _ := blogData.PreviewBySlug(slug); post == nil {
+		return "", fmt.Errorf("no post with slug %q", slug)
+	}
+
+	token, err := utils.NewPreviewToken(previewSecret, slug, expires)
+	if err != nil {
+		return "", err
+	}
+	return "/writings/" + url.PathEscape(slug) + "?preview=" + url.QueryEscape(token), nil
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestBlogPostHandlerPreview(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"draft.md": "---\nid: \"draft\"\ntitle: \"Draft\"\nslug: \"draft\"\npublished: false\n---\nWork in progress.\n",
+	})
+	blogData := loadTestBlog(t, dir)
+
+	SetPreviewSecret("test-secret")
+	defer SetPreviewSecret("")
+
+	validPath, err := PreviewPath(blogData, "draft", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredPath, err := PreviewPath(blogData, "draft", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{name: "without token", url: "/writings/draft", expectedStatus: http.StatusNotFound},
+		{name: "forged token", url: "/writings/draft?preview=abc.def", expectedStatus: http.StatusNotFound},
+		{name: "expired token", url: expiredPath, expectedStatus: http.StatusNotFound},
+		{name: "valid token", url: validPath, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req = mux.SetURLVars(req, map[string]string{"slug": "draft"})
+			rr := httptest.NewRecorder()
+
+			if err := BlogPostHandler(rr, req); err != nil {
+				t.Fatalf("BlogPostHandler returned an error: %v", err)
+			}
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			if got := rr.Header().Get("X-Robots-Tag"); !strings.Contains(got, "noindex") {
+				t.Errorf("Expected noindex X-Robots-Tag, got %q", got)
+			}
+			if !strings.Contains(rr.Body.String(), "draft preview") {
+				t.Error("Expected draft banner in preview page")
+			}
+		})
+	}
+}
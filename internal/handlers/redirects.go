@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redirect sends requests for a path that moved elsewhere, or answers 410
+// Gone for content that was removed
+type Redirect struct {
+	From   string `yaml:"from"`
+	To     string `yaml:"to"`
+	Status int    `yaml:"status"` // 301 (the default), 302, 308 or 410
+}
+
+type redirectsYAML struct {
+	Redirects []Redirect `yaml:"redirects"`
+}
+
+// builtinRedirects are the legacy paths of the site's own pages
+var builtinRedirects = []Redirect{
+	{From: "/info", To: "/about", Status: http.StatusMovedPermanently},
+	{From: "/blog", To: "/writings", Status: http.StatusMovedPermanently},
+}
+
+// readRedirects reads redirects.yaml from the blog directory. The file is
+// optional.
+func readRedirects(dir string) ([]Redirect, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "redirects.yaml"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redirects.yaml: %w", err)
+	}
+
+	var file redirectsYAML
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal redirects.yaml: %w", err)
+	}
+	for i := range file.Redirects {
+		if err := file.Redirects[i].normalize(); err != nil {
+			return nil, fmt.Errorf("redirects.yaml: %w", err)
+		}
+	}
+	return file.Redirects, nil
+}
+
+func (r *Redirect) normalize() error {
+	if !strings.HasPrefix(r.From, "/") {
+		return fmt.Errorf("from %q: must be a path starting with /", r.From)
+	}
+	r.From = cleanRedirectPath(r.From)
+
+	switch r.Status {
+	case 0:
+		r.Status = http.StatusMovedPermanently
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusPermanentRedirect, http.StatusGone:
+	default:
+		return fmt.Errorf("%s: unsupported status %d (expected 301, 302, 308 or 410)", r.From, r.Status)
+	}
+
+	if r.Status == http.StatusGone {
+		if r.To != "" {
+			return fmt.Errorf("%s: a 410 Gone redirect has no to", r.From)
+		}
+		return nil
+	}
+	if r.To == "" {
+		return fmt.Errorf("%s: missing to", r.From)
+	}
+	return nil
+}
+
+// aliasPath turns an alias of post into the path it answers: an old slug
+// becomes /writings/{slug} under the post's language prefix, a path is kept as
+// is
+func aliasPath(post BlogPost, alias string) string {
+	if strings.HasPrefix(alias, "/") {
+		return cleanRedirectPath(alias)
+	}
+	return post.prefix + "/writings/" + alias
+}
+
+// cleanRedirectPath drops a trailing slash so /a/ and /a match the same entry
+func cleanRedirectPath(path string) string {
+	if len(path) > 1 {
+		return strings.TrimSuffix(path, "/")
+	}
+	return path
+}
+
+// validateRedirects checks the redirect rules and post aliases together: no
+// path is claimed twice, none hides a post, and following redirects from any
+// path never comes back to it
+func validateRedirects(posts []BlogPost, rules []Redirect, files map[string]string) error {
+	postPaths := make(map[string]string, len(posts))
+	for _, post := range posts {
+		postPaths[post.URL()] = post.Slug
+	}
+
+	table := make(map[string]Redirect)
+	sources := make(map[string]string)
+	add := func(r Redirect, source string) error {
+		if existing, ok := sources[r.From]; ok {
+			return fmt.Errorf("redirect from %s is defined by both %s and %s", r.From, existing, source)
+		}
+		if slug, ok := postPaths[r.From]; ok {
+			return fmt.Errorf("%s: redirect from %s would hide the post %q", source, r.From, slug)
+		}
+		table[r.From], sources[r.From] = r, source
+		return nil
+	}
+
+	for _, r := range builtinRedirects {
+		if err := add(r, "built-in redirects"); err != nil {
+			return err
+		}
+	}
+	for _, r := range rules {
+		if err := add(r, "redirects.yaml"); err != nil {
+			return err
+		}
+	}
+	for _, post := range posts {
+		for _, alias := range post.Aliases {
+			if alias == "" {
+				return fmt.Errorf("%s: aliases: empty alias", files[post.Slug])
+			}
+			r := Redirect{From: aliasPath(post, alias), To: post.URL(), Status: http.StatusMovedPermanently}
+			if err := add(r, files[post.Slug]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for from := range table {
+		if chain, loops := redirectChain(table, from); loops {
+			return fmt.Errorf("redirect loop: %s", strings.Join(chain, " → "))
+		}
+	}
+	return nil
+}
+
+// redirectChain follows redirects from path and reports whether they lead
+// back to a path already visited
+func redirectChain(table map[string]Redirect, path string) ([]string, bool) {
+	chain := []string{path}
+	seen := map[string]bool{path: true}
+	for {
+		r, ok := table[path]
+		if !ok || r.Status == http.StatusGone || !strings.HasPrefix(r.To, "/") || strings.HasPrefix(r.To, "//") {
+			return chain, false
+		}
+		path = cleanRedirectPath(strings.SplitN(strings.SplitN(r.To, "?", 2)[0], "#", 2)[0])
+		chain = append(chain, path)
+		if seen[path] {
+			return chain, true
+		}
+		seen[path] = true
+	}
+}
+
+// buildRedirects indexes the redirect rules and the aliases of the visible
+// posts by path. Aliases of drafts and scheduled posts wait until the post
+// goes live.
+func (b *BlogData) buildRedirects() {
+	b.redirects = make(map[string]Redirect)
+	for _, r := range builtinRedirects {
+		b.redirects[r.From] = r
+	}
+	for _, r := range b.redirectRules {
+		b.redirects[r.From] = r
+	}
+	for _, post := range b.Posts {
+		for _, alias := range post.Aliases {
+			from := aliasPath(post, alias)
+			b.redirects[from] = Redirect{From: from, To: post.URL(), Status: http.StatusMovedPermanently}
+		}
+	}
+}
+
+// FindRedirect returns where a request for path should go and with which
+// status, for the redirect middleware
+func FindRedirect(path string) (string, int, bool) {
+	path = cleanRedirectPath(path)
+	blogData, err := content().Blog()
+	if err != nil {
+		for _, r := range builtinRedirects {
+			if r.From == path {
+				return r.To, r.Status, true
+			}
+		}
+		return "", 0, false
+	}
+	r, ok := blogData.redirects[path]
+	return r.To, r.Status, ok
+}
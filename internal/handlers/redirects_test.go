@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thinkingojha/go-htmx/internal/middleware"
+)
+
+func writeTestRedirects(t *testing.T, dir, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "redirects.yaml"), []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRedirectMiddleware(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"renamed.yaml": testPost("renamed", "2024-01-01", `aliases: ["old-name", "/posts/renamed/"]`),
+		"draft.yaml":   "id: \"draft\"\nslug: \"draft\"\npublished: false\naliases: [\"old-draft\"]\n",
+	})
+	writeTestRedirects(t, dir, `redirects:
+  - from: /talks
+    to: /writings/renamed
+    status: 302
+  - from: /writings/removed
+    status: 410
+  - from: /api/v1
+    to: /api/v2
+    status: 308
+`)
+	loadTestBlog(t, dir)
+
+	gone := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := middleware.Redirects(FindRedirect, gone)(next)
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/writings/old-name", http.StatusMovedPermanently, "/writings/renamed"},
+		{"/writings/old-name?ref=x", http.StatusMovedPermanently, "/writings/renamed?ref=x"},
+		{"/posts/renamed", http.StatusMovedPermanently, "/writings/renamed"},
+		{"/talks/", http.StatusFound, "/writings/renamed"},
+		{"/api/v1", http.StatusPermanentRedirect, "/api/v2"},
+		{"/writings/removed", http.StatusGone, ""},
+		{"/info", http.StatusMovedPermanently, "/about"},
+		{"/blog", http.StatusMovedPermanently, "/writings"},
+		{"/writings/old-draft", http.StatusTeapot, ""},
+		{"/writings/renamed", http.StatusTeapot, ""},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+		if rr.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.path, rr.Code, tt.status)
+		}
+		if location := rr.Header().Get("Location"); location != tt.location {
+			t.Errorf("%s: got Location %q, want %q", tt.path, location, tt.location)
+		}
+	}
+}
+
+func TestRedirectLanguageAliases(t *testing.T) {
+	dir := writeLanguagesTestBlog(t, map[string]string{
+		"engines.yaml":    testPost("engines", "2024-01-01", `aliases: ["motors"]`),
+		"engines-hi.yaml": testPost("engines-hi", "2024-01-05", `lang: "hi"`, `translation_of: "engines"`, `aliases: ["motors"]`),
+	})
+	loadTestBlog(t, dir)
+
+	// The same old slug is an alias in each language, under its own prefix
+	for path, want := range map[string]string{
+		"/writings/motors":    "/writings/engines",
+		"/hi/writings/motors": "/hi/writings/engines-hi",
+	} {
+		to, status, ok := FindRedirect(path)
+		if !ok || to != want || status != http.StatusMovedPermanently {
+			t.Errorf("FindRedirect(%q) = %q, %d, %v, want %q, 301", path, to, status, ok, want)
+		}
+	}
+}
+
+func TestRedirectValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		posts     map[string]string
+		redirects string
+		wantErr   string
+	}{
+		{
+			name:      "loop",
+			redirects: "redirects:\n  - from: /a\n    to: /b\n  - from: /b/\n    to: /a?x=1\n",
+			wantErr:   "redirect loop: ",
+		},
+		{
+			name:      "redirect hiding a post",
+			posts:     map[string]string{"one.yaml": testPost("one", "2024-01-01", `aliases: ["/a"]`)},
+			redirects: "redirects:\n  - from: /writings/one\n    to: /a\n",
+			wantErr:   `would hide the post "one"`,
+		},
+		{
+			name:      "alias claimed twice",
+			posts:     map[string]string{"one.yaml": testPost("one", "2024-01-01", `aliases: ["old"]`)},
+			redirects: "redirects:\n  - from: /writings/old\n    to: /\n",
+			wantErr:   "redirect from /writings/old is defined by both redirects.yaml and ",
+		},
+		{
+			name:      "unsupported status",
+			redirects: "redirects:\n  - from: /a\n    to: /b\n    status: 307\n",
+			wantErr:   "unsupported status 307",
+		},
+		{
+			name:      "gone with a target",
+			redirects: "redirects:\n  - from: /a\n    to: /b\n    status: 410\n",
+			wantErr:   "a 410 Gone redirect has no to",
+		},
+		{
+			name:      "relative from",
+			redirects: "redirects:\n  - from: a\n    to: /b\n",
+			wantErr:   `from "a": must be a path starting with /`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestBlog(t, tt.posts)
+			writeTestRedirects(t, dir, tt.redirects)
+
+			_, err := readBlogData(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
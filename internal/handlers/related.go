@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// Weights of the signals combined into a related-post score
+const (
+	relatedTagWeight        = 1.5 // per shared tag
+	relatedCategoryWeight   = 1.0
+	relatedSimilarityWeight = 3.0 // times the TF-IDF cosine similarity, 0–1
+	relatedRecencyWeight    = 0.5 // times a decay on age relative to the newest post
+
+	// relatedRecencyHalfLife is the age at which the recency bonus halves
+	relatedRecencyHalfLife = 365 * 24 * time.Hour
+
+	// relatedMinSimilarity is the cosine similarity below which content alone
+	// does not make two posts related
+	relatedMinSimilarity = 0.05
+
+	relatedLimit = 3
+)
+
+// relatedIndex holds the TF-IDF vectors of the published posts, by position
+// in BlogData.Posts, so related posts can be scored for any post
+type relatedIndex struct {
+	idf     map[string]float64
+	vectors []map[string]float64
+	newest  time.Time
+}
+
+func buildRelatedIndex(posts []BlogPost) *relatedIndex {
+	idx := &relatedIndex{idf: make(map[string]float64)}
+
+	counts := make([]map[string]int, len(posts))
+	df := make(map[string]int)
+	for i, post := range posts {
+		counts[i] = termCounts(post)
+		for term := range counts[i] {
+			df[term]++
+		}
+		if post.PublishDate.After(idx.newest) {
+			idx.newest = post.PublishDate
+		}
+	}
+
+	// Smoothed so terms shared by every post still carry a little weight
+	n := float64(len(posts))
+	for term, count := range df {
+		idx.idf[term] = math.Log((1+n)/(1+float64(count))) + 1
+	}
+
+	idx.vectors = make([]map[string]float64, len(posts))
+	for i := range posts {
+		idx.vectors[i] = idx.vector(counts[i])
+	}
+	return idx
+}
+
+// termCounts counts the terms in a post's title and text
+func termCounts(post BlogPost) map[string]int {
+	counts := make(map[string]int)
+	for _, term := range tokenize(post.Title + " " + utils.MarkdownToText(post.Content)) {
+		counts[term]++
+	}
+	return counts
+}
+
+// vector weights term counts by IDF and normalises the result to unit length.
+// Terms the index has not seen are ignored.
+func (idx *relatedIndex) vector(counts map[string]int) map[string]float64 {
+	vector := make(map[string]float64, len(counts))
+	var norm float64
+	for term, count := range counts {
+		idf, ok := idx.idf[term]
+		if !ok {
+			continue
+		}
+		weight := float64(count) * idf
+		vector[term] = weight
+		norm += weight * weight
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for term := range vector {
+		vector[term] /= norm
+	}
+	return vector
+}
+
+func cosine(a, b map[string]float64) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	var dot float64
+	for term, weight := range a {
+		dot += weight * b[term]
+	}
+	return dot
+}
+
+// score rates how related candidate is to post. Posts sharing no tag, no
+// category and little content score zero whatever their age.
+func (idx *relatedIndex) score(post BlogPost, vector map[string]float64, candidate BlogPost, candidateVector map[string]float64) float64 {
+	var relevance float64
+	for _, tag := range post.Tags {
+		for _, other := range candidate.Tags {
+			if tag == other {
+				relevance += relatedTagWeight
+			}
+		}
+	}
+	if post.Category != "" && post.Category == candidate.Category {
+		relevance += relatedCategoryWeight
+	}
+	if similarity := cosine(vector, candidateVector); similarity >= relatedMinSimilarity {
+		relevance += relatedSimilarityWeight * similarity
+	}
+	if relevance == 0 {
+		return 0
+	}
+
+	age := idx.newest.Sub(candidate.PublishDate)
+	recency := math.Pow(0.5, float64(age)/float64(relatedRecencyHalfLife))
+	return relevance + relatedRecencyWeight*recency
+}
+
+// rank returns up to limit posts related to post, best first. Newer posts win
+// ties.
+func (idx *relatedIndex) rank(posts []BlogPost, post BlogPost, vector map[string]float64, limit int) []BlogPost {
+	type scored struct {
+		index int
+		score float64
+	}
+
+	var candidates []scored
+	for i, candidate := range posts {
+		if candidate.Slug == post.Slug {
+			continue
+		}
+		if score := idx.score(post, vector, candidate, idx.vectors[i]); score > 0 {
+			candidates = append(candidates, scored{index: i, score: score})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	related := make([]BlogPost, 0, min(limit, len(candidates)))
+	for _, candidate := range candidates[:min(limit, len(candidates))] {
+		related = append(related, posts[candidate.index])
+	}
+	return related
+}
+
+// buildRelated precomputes the related posts of every published post: the
+// post's related: list when it has one, otherwise the best scoring posts
+func (b *BlogData) buildRelated() {
+	b.relatedIndex = buildRelatedIndex(b.Posts)
+	b.related = make(map[string][]BlogPost, len(b.Posts))
+	for i, post := range b.Posts {
+		if post.Related != nil {
+			b.related[post.Slug] = b.relatedOverride(post)
+			continue
+		}
+		b.related[post.Slug] = b.relatedIndex.rank(b.Posts, post, b.relatedIndex.vectors[i], relatedLimit)
+	}
+}
+
+// relatedOverride returns the published posts named in post's related: list,
+// in the order given
+func (b *BlogData) relatedOverride(post BlogPost) []BlogPost {
+	var related []BlogPost
+	for _, slug := range post.Related {
+		if other := b.PostBySlug(slug); other != nil {
+			related = append(related, *other)
+		}
+	}
+	return related
+}
+
+// relatedTo returns the related posts of post. Drafts and scheduled posts are
+// not precomputed, so previews score them against the published posts.
+func (b *BlogData) relatedTo(post *BlogPost) []BlogPost {
+	if related, ok := b.related[post.Slug]; ok && b.PostBySlug(post.Slug) == post {
+		return related
+	}
+	if post.Related != nil {
+		return b.relatedOverride(*post)
+	}
+	if b.relatedIndex == nil {
+		return nil
+	}
+	return b.relatedIndex.rank(b.Posts, *post, b.relatedIndex.vector(termCounts(*post)), relatedLimit)
+}
+
+// validateRelated checks that every related: entry names another post
+func validateRelated(posts []BlogPost, files map[string]string) error {
+	for _, post := range posts {
+		for _, slug := range post.Related {
+			if slug == post.Slug {
+				return fmt.Errorf("%s: related: a post cannot be related to itself", files[post.Slug])
+			}
+			if _, ok := files[slug]; !ok {
+				return fmt.Errorf("%s: related: unknown post %q", files[post.Slug], slug)
+			}
+		}
+	}
+	return nil
+}
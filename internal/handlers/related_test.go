@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func loadRelatedTestBlog(t *testing.T) *BlogData {
+	t.Helper()
+
+	dir := writeTestBlog(t, map[string]string{
+		// No category: related only through tags and content
+		"goroutines.yaml": testPost("goroutines", "2024-01-01", `tags: ["go", "concurrency"]`, `content: "Goroutines and channels make concurrency in Go approachable."`),
+		"channels.yaml":   testPost("channels", "2024-02-01", `category: "engineering"`, `tags: ["go", "concurrency"]`, `content: "Buffered channels let goroutines hand off work without blocking."`),
+		"errors.yaml":     testPost("errors", "2024-03-01", `category: "engineering"`, `tags: ["go"]`, `content: "Wrapping errors keeps context as they travel up the stack."`),
+		"sourdough.yaml":  testPost("sourdough", "2024-04-01", `tags: ["baking"]`, `content: "Feed the starter the night before and bake in a hot oven."`),
+		"pinned.yaml":     testPost("pinned", "2024-05-01", `related: ["sourdough", "goroutines"]`, `content: "A post that chooses its own related posts."`),
+	})
+	return loadTestBlog(t, dir)
+}
+
+func relatedSlugs(posts []BlogPost) string {
+	slugs := make([]string, len(posts))
+	for i, post := range posts {
+		slugs[i] = post.Slug
+	}
+	return strings.Join(slugs, ",")
+}
+
+func TestRelatedPosts(t *testing.T) {
+	blogData := loadRelatedTestBlog(t)
+
+	tests := []struct {
+		slug string
+		want string
+	}{
+		// Shared tags and content outrank a shared category alone
+		{slug: "goroutines", want: "channels,errors"},
+		{slug: "channels", want: "goroutines,errors"},
+		// Nothing in common with the other posts
+		{slug: "sourdough", want: ""},
+		// The related: list replaces the computed posts, in its order
+		{slug: "pinned", want: "sourdough,goroutines"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			got := relatedSlugs(blogData.relatedTo(blogData.PostBySlug(tt.slug)))
+			if got != tt.want {
+				t.Errorf("relatedTo(%s) = %q, want %q", tt.slug, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadBlogDataUnknownRelatedPost(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01", `related: ["missing"]`, `content: "Body."`),
+	})
+
+	if _, err := loadBlogData(dir); err == nil || !strings.Contains(err.Error(), `unknown post "missing"`) {
+		t.Errorf("Expected an unknown related post error, got %v", err)
+	}
+}
+
+func TestBlogPostHandlerRelatedPosts(t *testing.T) {
+	loadRelatedTestBlog(t)
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/writings/goroutines", nil), map[string]string{"slug": "goroutines"})
+	rr := httptest.NewRecorder()
+	if err := BlogPostHandler(rr, req); err != nil {
+		t.Fatalf("BlogPostHandler returned an error: %v", err)
+	}
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `class="related-posts"`) || !strings.Contains(body, `<a href="/writings/channels">`) {
+		t.Error("Expected the post page to list related posts")
+	}
+	if strings.Contains(body, `<a href="/writings/sourdough">`) {
+		t.Error("Expected unrelated posts to be left out")
+	}
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"html"
+	"html/template"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// Field weights used when scoring a term hit
+const (
+	titleWeight   = 5.0
+	tagWeight     = 3.0
+	excerptWeight = 2.0
+	contentWeight = 1.0
+
+	// prefixPenalty scales hits where the query term is only a prefix of the
+	// indexed term
+	prefixPenalty = 0.5
+
+	// Snippets show snippetWords words, starting a few words before the
+	// first hit
+	snippetWords       = 30
+	snippetWordsBefore = 8
+)
+
+var searchStopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {},
+	"for": {}, "from": {}, "in": {}, "is": {}, "it": {}, "of": {}, "on": {}, "or": {},
+	"that": {}, "the": {}, "this": {}, "to": {}, "was": {}, "with": {},
+}
+
+type posting struct {
+	doc    int
+	weight float64
+}
+
+type searchDoc struct {
+	prose string // rendered content without code blocks, used for snippets
+}
+
+// searchIndex is an inverted index over the published posts of a BlogData
+// snapshot. Document numbers are positions in BlogData.Posts.
+type searchIndex struct {
+	postings map[string][]posting
+	terms    []string // sorted, for prefix lookups
+	docs     []searchDoc
+}
+
+// SearchResult is a post matching a query together with its highlighted snippet
+type SearchResult struct {
+	Post    BlogPost
+	Score   float64
+	Snippet template.HTML
+}
+
+// searchQuery is a parsed search string
+type searchQuery struct {
+	terms    []string
+	tag      string
+	category string
+}
+
+func buildSearchIndex(posts []BlogPost) *searchIndex {
+	idx := &searchIndex{
+		postings: make(map[string][]posting),
+		docs:     make([]searchDoc, len(posts)),
+	}
+
+	for i, post := range posts {
+		text := utils.MarkdownToText(post.Content)
+		idx.docs[i] = searchDoc{prose: utils.MarkdownToProse(post.Content)}
+
+		weights := make(map[string]float64)
+		addField := func(s string, weight float64) {
+			for _, term := range tokenize(s) {
+				weights[term] += weight
+			}
+		}
+		addField(post.Title, titleWeight)
+		addField(strings.Join(post.Tags, " "), tagWeight)
+		addField(post.Excerpt, excerptWeight)
+		addField(text, contentWeight)
+
+		for term, weight := range weights {
+			idx.postings[term] = append(idx.postings[term], posting{doc: i, weight: weight})
+		}
+	}
+
+	idx.terms = make([]string, 0, len(idx.postings))
+	for term := range idx.postings {
+		idx.terms = append(idx.terms, term)
+	}
+	sort.Strings(idx.terms)
+
+	return idx
+}
+
+// tokenize lowercases s and splits it into indexable terms
+func tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := fields[:0]
+	for _, field := range fields {
+		if _, stop := searchStopWords[field]; stop {
+			continue
+		}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+// parseSearchQuery splits free-text terms from tag: and category: operators
+func parseSearchQuery(q string) searchQuery {
+	var query searchQuery
+	for _, field := range strings.Fields(q) {
+		lower := strings.ToLower(field)
+		switch {
+		case strings.HasPrefix(lower, "tag:") && len(field) > len("tag:"):
+			query.tag = field[len("tag:"):]
+		case strings.HasPrefix(lower, "category:") && len(field) > len("category:"):
+			query.category = field[len("category:"):]
+		default:
+			query.terms = append(query.terms, tokenize(field)...)
+		}
+	}
+	return query
+}
+
+// empty reports whether the query has nothing to search for, like a query of
+// stop words only
+func (q searchQuery) empty() bool {
+	return len(q.terms) == 0 && q.tag == "" && q.category == ""
+}
+
+// matches returns the documents containing term or a term it prefixes, scored
+// with an idf weighting so rare terms count for more
+func (idx *searchIndex) matches(term string) map[int]float64 {
+	scores := make(map[int]float64)
+	total := float64(len(idx.docs))
+
+	start := sort.SearchStrings(idx.terms, term)
+	for _, indexed := range idx.terms[start:] {
+		if !strings.HasPrefix(indexed, term) {
+			break
+		}
+
+		list := idx.postings[indexed]
+		idf := math.Log(1 + total/float64(len(list)))
+		scale := 1.0
+		if indexed != term {
+			scale = prefixPenalty
+		}
+		for _, p := range list {
+			scores[p.doc] += p.weight * idf * scale
+		}
+	}
+	return scores
+}
+
+// Search returns the published posts matching q, best match first. Every
+// free-text term must match; tag: and category: narrow the results further.
+// An empty query matches nothing.
+func (b *BlogData) Search(q string) []SearchResult {
+	query := parseSearchQuery(q)
+	if b.search == nil || query.empty() {
+		return nil
+	}
+
+	var scores map[int]float64
+	if len(query.terms) == 0 {
+		scores = make(map[int]float64, len(b.Posts))
+		for i := range b.Posts {
+			scores[i] = 0
+		}
+	}
+	for _, term := range query.terms {
+		termScores := b.search.matches(term)
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+		for doc, score := range scores {
+			if extra, ok := termScores[doc]; ok {
+				scores[doc] = score + extra
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	var results []SearchResult
+	for doc, score := range scores {
+		post := b.Posts[doc]
+		if !post.Published {
+			continue
+		}
+		if query.category != "" && !strings.EqualFold(post.Category, query.category) {
+			continue
+		}
+		if query.tag != "" && !hasTagFold(post.Tags, query.tag) {
+			continue
+		}
+		results = append(results, SearchResult{
+			Post:    post,
+			Score:   score,
+			Snippet: highlightSnippet(b.search.docs[doc].prose, query.terms),
+		})
+	}
+
+	// Ties keep the listing order, which is newest first
+	order := make(map[string]int, len(b.Posts))
+	for i, post := range b.Posts {
+		order[post.Slug] = i
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return order[results[i].Post.Slug] < order[results[j].Post.Slug]
+	})
+
+	return results
+}
+
+func hasTagFold(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightSnippet cuts a window of text around the first matched term and
+// wraps every word starting with a query term in <mark>
+func highlightSnippet(text string, terms []string) template.HTML {
+	if len(terms) == 0 || text == "" {
+		return ""
+	}
+
+	words := strings.Fields(text)
+	first := -1
+	for i, word := range words {
+		if wordMatches(word, terms) {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	start := max(0, first-snippetWordsBefore)
+	end := min(len(words), start+snippetWords)
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("… ")
+	}
+	for i := start; i < end; i++ {
+		if i > start {
+			sb.WriteByte(' ')
+		}
+		escaped := html.EscapeString(words[i])
+		if wordMatches(words[i], terms) {
+			sb.WriteString("<mark>" + escaped + "</mark>")
+		} else {
+			sb.WriteString(escaped)
+		}
+	}
+	if end < len(words) {
+		sb.WriteString(" …")
+	}
+
+	return template.HTML(sb.String())
+}
+
+func wordMatches(word string, terms []string) bool {
+	for _, token := range tokenize(word) {
+		for _, term := range terms {
+			if strings.HasPrefix(token, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BlogSearchHandler serves /writings/search. HTMX requests get just the
+// posts-list fragment so results can update as the reader types.
+func BlogSearchHandler(w http.ResponseWriter, r *http.Request) error {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return err
+	}
+	templates, err = templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "blog", "*.html"))
+	if err != nil {
+		return err
+	}
+
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	page := parseIntParam(r, "page", 1)
+
+	sortBy, sortOrder := archiveSort(r, blogData.Archive)
+
+	var posts []BlogPost
+	snippets := make(map[string]template.HTML)
+	// A query with nothing to search for gets the regular listing
+	if parseSearchQuery(query).empty() {
+		posts = sortPosts(filterPosts(blogData, "", ""), sortBy, sortOrder)
+	} else {
+		for _, result := range blogData.Search(query) {
+			posts = append(posts, result.Post)
+			if result.Snippet != "" {
+				snippets[result.Post.Slug] = result.Snippet
+			}
+		}
+		// Results are ranked by relevance unless the reader picked an order
+		if r.URL.Query().Has("sort") || r.URL.Query().Has("order") {
+			posts = sortPosts(posts, sortBy, sortOrder)
+		} else {
+			sortBy, sortOrder = "", ""
+		}
+	}
+
+	postsPerPage := blogData.Archive.PostsPerPage
+	paginatedPosts, page, totalPages := paginatePosts(posts, page, postsPerPage)
+
+	pageData := BlogPageData{
+		BlogData:     *blogData,
+		PageName:     "writings",
+		CanonicalURL: blogData.siteURL() + blogData.Prefix + "/writings",
+		CurrentPage:  page,
+		TotalPages:   totalPages,
+		PostsPerPage: postsPerPage,
+		SortBy:       sortBy,
+		SortOrder:    sortOrder,
+		AllTags:      getAllTags(blogData.Posts),
+		Query:        query,
+		Snippets:     snippets,
+		Feeds:        feedLinks(blogData, "", ""),
+	}
+	pageData.Posts = paginatedPosts
+
+	if r.Header.Get("HX-Request") == "true" {
+		return templates.ExecuteTemplate(w, "posts-list", pageData)
+	}
+
+	return templates.ExecuteTemplate(w, "blog", pageData)
+}
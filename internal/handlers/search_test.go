@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func loadSearchTestBlog(t *testing.T) *BlogData {
+	t.Helper()
+
+	dir := writeTestBlog(t, map[string]string{
+		"kafka.yaml": `id: "kafka"
+title: "Streaming with Kafka"
+slug: "kafka"
+publish_date: "2024-01-01"
+category: "engineering"
+tags: ["go", "streaming"]
+published: true
+content: |
+  Consumers read events in order.
+`,
+		"errors.yaml": `id: "errors"
+title: "Error handling"
+slug: "errors"
+publish_date: "2024-02-01"
+category: "go-development"
+tags: ["go"]
+published: true
+content: |
+  We publish domain errors to Kafka & log <everything>.
+`,
+	})
+
+	return loadTestBlog(t, dir)
+}
+
+func TestBlogDataSearch(t *testing.T) {
+	blogData := loadSearchTestBlog(t)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "title match ranks first", query: "kafka", want: []string{"kafka", "errors"}},
+		{name: "prefix match", query: "stream", want: []string{"kafka"}},
+		{name: "all terms must match", query: "kafka consumers", want: []string{"kafka"}},
+		{name: "tag operator", query: "tag:streaming", want: []string{"kafka"}},
+		{name: "category operator", query: "kafka category:go-development", want: []string{"errors"}},
+		{name: "no match", query: "python", want: nil},
+		{name: "stop words only", query: "the", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, result := range blogData.Search(tt.query) {
+				got = append(got, result.Post.Slug)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlogDataSearchSnippet(t *testing.T) {
+	blogData := loadSearchTestBlog(t)
+
+	results := blogData.Search("kafk")
+	var snippet string
+	for _, result := range results {
+		if result.Post.Slug == "errors" {
+			snippet = string(result.Snippet)
+		}
+	}
+
+	if !strings.Contains(snippet, "<mark>Kafka</mark>") {
+		t.Errorf("Expected highlighted term in snippet, got %q", snippet)
+	}
+	if strings.Contains(snippet, "<everything>") {
+		t.Errorf("Expected snippet text to be escaped, got %q", snippet)
+	}
+}
+
+func TestBlogSearchHandlerHTMX(t *testing.T) {
+	loadSearchTestBlog(t)
+
+	req := httptest.NewRequest("GET", "/writings/search?q=kafka", nil)
+	req.Header.Set("HX-Request", "true")
+	rr := httptest.NewRecorder()
+
+	if err := BlogSearchHandler(rr, req); err != nil {
+		t.Fatalf("BlogSearchHandler returned an error: %v", err)
+	}
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `id="posts-list"`) || strings.Contains(body, "<html") {
+		t.Errorf("Expected only the posts-list fragment, got: %s", body)
+	}
+	if !strings.Contains(body, "/writings/kafka") {
+		t.Errorf("Expected kafka post in results, got: %s", body)
+	}
+}
+
+func TestBlogSearchHandlerStopWords(t *testing.T) {
+	loadSearchTestBlog(t)
+
+	req := httptest.NewRequest("GET", "/writings/search?q=the", nil)
+	req.Header.Set("HX-Request", "true")
+	rr := httptest.NewRecorder()
+	if err := BlogSearchHandler(rr, req); err != nil {
+		t.Fatalf("BlogSearchHandler returned an error: %v", err)
+	}
+
+	// Nothing to search for, so every post is listed newest first
+	body := rr.Body.String()
+	errors, kafka := strings.Index(body, "/writings/errors"), strings.Index(body, "/writings/kafka")
+	if errors < 0 || kafka < 0 || errors > kafka {
+		t.Errorf("Expected the regular listing, got: %s", body)
+	}
+	if !strings.Contains(body, `aria-current="true">newest`) {
+		t.Error("Expected the listing's default order to be marked")
+	}
+}
+
+func TestBlogSearchHandlerSort(t *testing.T) {
+	loadSearchTestBlog(t)
+
+	search := func(query string) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/writings/search?"+query, nil)
+		req.Header.Set("HX-Request", "true")
+		rr := httptest.NewRecorder()
+		if err := BlogSearchHandler(rr, req); err != nil {
+			t.Fatalf("BlogSearchHandler returned an error: %v", err)
+		}
+		return rr.Body.String()
+	}
+
+	// Relevance puts the title match first; newest first puts errors first
+	body := search("q=kafka")
+	if strings.Index(body, "/writings/kafka") > strings.Index(body, "/writings/errors") {
+		t.Error("Expected results ranked by relevance without a sort")
+	}
+	body = search("q=kafka&sort=publish_date&order=desc")
+	if strings.Index(body, "/writings/errors") > strings.Index(body, "/writings/kafka") {
+		t.Error("Expected newest first with sort=publish_date&order=desc")
+	}
+
+	page := BlogPageData{BlogData: BlogData{Archive: ArchiveConfig{SortBy: SortByPublishDate, SortOrder: "desc"}}, Query: "kafka"}
+	if got := page.ListingURL(2, SortByPublishDate, "desc"); got != "/writings/search?order=desc&page=2&q=kafka&sort=publish_date" {
+		t.Errorf("ListingURL of a search sorted newest first = %q", got)
+	}
+	if got := page.ListingURL(2, "", ""); got != "/writings/search?page=2&q=kafka" {
+		t.Errorf("ListingURL of a search ranked by relevance = %q", got)
+	}
+}
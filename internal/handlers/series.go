@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// PostSeries places a post in a multi-part series
+type PostSeries struct {
+	Name string `json:"name" yaml:"name"`
+	Slug string `json:"slug" yaml:"slug"`
+	Part int    `json:"part" yaml:"part"`
+}
+
+// SeriesNav describes a series from the point of view of one page: every
+// visible part in order, and the parts either side of the current one
+type SeriesNav struct {
+	Name    string
+	Slug    string
+	Parts   []BlogPost
+	Current int // part number of the post being read, 0 on the series page
+	Prev    *BlogPost
+	Next    *BlogPost
+}
+
+// URL returns the path of the series landing page
+func (s PostSeries) URL() string {
+	return "/writings/series/" + url.PathEscape(s.Slug)
+}
+
+// URL returns the path of the series landing page
+func (s SeriesNav) URL() string {
+	return PostSeries{Slug: s.Slug}.URL()
+}
+
+// validateSeries checks that every series entry has a slug and a positive part
+// number, that no two posts claim the same part of a series, and gives parts
+// without a name the name used elsewhere in their series. Each language has
+// its own parts and names, so translations may share their original's series.
+func validateSeries(posts []BlogPost, files map[string]string) error {
+	names := make(map[string]string)
+	parts := make(map[string]map[int]string)
+
+	for _, post := range posts {
+		series := post.Series
+		if series == nil {
+			continue
+		}
+		key := post.Lang + "/" + series.Slug
+		if series.Slug == "" {
+			return fmt.Errorf("%s: series: missing slug", files[post.Slug])
+		}
+		if series.Part <= 0 {
+			return fmt.Errorf("%s: series: part must be a positive number", files[post.Slug])
+		}
+		if parts[key] == nil {
+			parts[key] = make(map[int]string)
+		}
+		if existing, ok := parts[key][series.Part]; ok {
+			return fmt.Errorf("series %q: part %d claimed by both %s and %s", series.Slug, series.Part, files[existing], files[post.Slug])
+		}
+		parts[key][series.Part] = post.Slug
+		if series.Name != "" && names[key] == "" {
+			names[key] = series.Name
+		}
+	}
+
+	for i := range posts {
+		if series := posts[i].Series; series != nil && series.Name == "" {
+			series.Name = names[posts[i].Lang+"/"+series.Slug]
+			if series.Name == "" {
+				series.Name = series.Slug
+			}
+		}
+	}
+	return nil
+}
+
+// buildSeriesIndex groups the visible posts by series, ordered by part
+func (b *BlogData) buildSeriesIndex() {
+	b.postsBySeries = make(map[string][]BlogPost)
+	for _, post := range b.Posts {
+		if post.Series != nil {
+			b.postsBySeries[post.Series.Slug] = append(b.postsBySeries[post.Series.Slug], post)
+		}
+	}
+	for _, parts := range b.postsBySeries {
+		sort.Slice(parts, func(i, j int) bool {
+			return parts[i].Series.Part < parts[j].Series.Part
+		})
+	}
+}
+
+// PostsInSeries returns the visible parts of a series, in order
+func (b *BlogData) PostsInSeries(slug string) []BlogPost {
+	return b.postsBySeries[slug]
+}
+
+// seriesNav returns the navigation for the series post belongs to, or nil
+func (b *BlogData) seriesNav(post *BlogPost) *SeriesNav {
+	if post.Series == nil {
+		return nil
+	}
+
+	parts := b.PostsInSeries(post.Series.Slug)
+	nav := &SeriesNav{
+		Name:    post.Series.Name,
+		Slug:    post.Series.Slug,
+		Parts:   parts,
+		Current: post.Series.Part,
+	}
+	for i := range parts {
+		switch part := parts[i].Series.Part; {
+		case part < nav.Current:
+			nav.Prev = &parts[i]
+		case part > nav.Current && nav.Next == nil:
+			nav.Next = &parts[i]
+		}
+	}
+	return nav
+}
+
+// BlogSeriesHandler serves /writings/series/{slug}, listing every part of a
+// series in order
+func BlogSeriesHandler(w http.ResponseWriter, r *http.Request) error {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return err
+	}
+	templates, err = templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "blog", "*.html"))
+	if err != nil {
+		return err
+	}
+
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	slug := mux.Vars(r)["slug"]
+	parts := blogData.PostsInSeries(slug)
+	if len(parts) == 0 {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	nav := &SeriesNav{Name: parts[0].Series.Name, Slug: slug, Parts: parts}
+	pageData := BlogPageData{
+		BlogData:     *blogData,
+		PageName:     "writings",
+		CanonicalURL: blogData.siteURL() + blogData.Prefix + nav.URL(),
+		Series:       nav,
+		Feeds:        feedLinks(blogData, "", ""),
+	}
+	pageData.Title = nav.Name
+	pageData.Description = fmt.Sprintf("A %d-part series: %s", len(parts), strings.Join(seriesTitles(parts), ", "))
+
+	return templates.ExecuteTemplate(w, "blog", pageData)
+}
+
+func seriesTitles(parts []BlogPost) []string {
+	titles := make([]string, len(parts))
+	for i, part := range parts {
+		titles[i] = part.Title
+	}
+	return titles
+}
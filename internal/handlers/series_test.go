@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func loadSeriesTestBlog(t *testing.T) *BlogData {
+	t.Helper()
+
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml":   testPost("one", "2024-01-01", `series: {name: "Building a blog", slug: "blog", part: 1}`),
+		"two.yaml":   testPost("two", "2024-02-01", `series: {slug: "blog", part: 2}`),
+		"three.yaml": testPost("three", "2024-03-01", `series: {slug: "blog", part: 3}`),
+	})
+	return loadTestBlog(t, dir)
+}
+
+func TestSeriesNav(t *testing.T) {
+	blogData := loadSeriesTestBlog(t)
+
+	parts := blogData.PostsInSeries("blog")
+	if len(parts) != 3 || parts[0].Slug != "one" || parts[2].Slug != "three" {
+		t.Fatalf("Expected parts in order, got %+v", parts)
+	}
+	if parts[1].Series.Name != "Building a blog" {
+		t.Errorf("Expected the series name to be shared, got %q", parts[1].Series.Name)
+	}
+
+	nav := blogData.seriesNav(blogData.PostBySlug("two"))
+	if nav.Prev == nil || nav.Prev.Slug != "one" || nav.Next == nil || nav.Next.Slug != "three" {
+		t.Errorf("Unexpected navigation for part 2: prev %v, next %v", nav.Prev, nav.Next)
+	}
+
+	nav = blogData.seriesNav(blogData.PostBySlug("one"))
+	if nav.Prev != nil || nav.Next == nil || nav.Next.Slug != "two" {
+		t.Errorf("Unexpected navigation for part 1: prev %v, next %v", nav.Prev, nav.Next)
+	}
+}
+
+func TestLoadBlogDataDuplicateSeriesPart(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml": testPost("one", "2024-01-01", `series: {slug: "blog", part: 1}`),
+		"two.yaml": testPost("two", "2024-02-01", `series: {slug: "blog", part: 1}`),
+	})
+
+	_, err := loadBlogData(dir)
+	if err == nil || !strings.Contains(err.Error(), "part 1") {
+		t.Fatalf("Expected a duplicate part error, got %v", err)
+	}
+}
+
+func TestBlogSeriesHandler(t *testing.T) {
+	loadSeriesTestBlog(t)
+
+	tests := []struct {
+		name           string
+		slug           string
+		expectedStatus int
+	}{
+		{name: "known series", slug: "blog", expectedStatus: http.StatusOK},
+		{name: "unknown series", slug: "cooking", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest("GET", "/writings/series/"+tt.slug, nil), map[string]string{"slug": tt.slug})
+			rr := httptest.NewRecorder()
+
+			if err := BlogSeriesHandler(rr, req); err != nil {
+				t.Fatalf("BlogSeriesHandler returned an error: %v", err)
+			}
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Errorf("Handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			body := rr.Body.String()
+			if strings.Index(body, `href="/writings/one"`) > strings.Index(body, `href="/writings/three"`) {
+				t.Error("Expected parts to be listed in order")
+			}
+			if !strings.Contains(body, `"@type":"CreativeWorkSeries"`) {
+				t.Error("Expected CreativeWorkSeries structured data")
+			}
+		})
+	}
+}
+
+func TestBlogPostHandlerSeries(t *testing.T) {
+	loadSeriesTestBlog(t)
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/writings/two", nil), map[string]string{"slug": "two"})
+	rr := httptest.NewRecorder()
+	if err := BlogPostHandler(rr, req); err != nil {
+		t.Fatalf("BlogPostHandler returned an error: %v", err)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{`href="/writings/series/blog"`, `rel="prev"`, `rel="next"`, `"isPartOf"`, `"url":"https://example.com/writings/series/blog"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected post page to contain %s", want)
+		}
+	}
+}
+
+func TestJSONFeedSeries(t *testing.T) {
+	loadSeriesTestBlog(t)
+
+	rr := httptest.NewRecorder()
+	if err := BlogJSONFeedHandler(rr, httptest.NewRequest("GET", "/writings/feed.json", nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc jsonFeed
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	series := doc.Items[0].Series
+	if series == nil || series.Part != 3 || series.URL != "https://example.com/writings/series/blog" {
+		t.Errorf("Expected series membership in the feed, got %+v", series)
+	}
+}
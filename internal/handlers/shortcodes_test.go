@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+func shortcodeTestPost(body string) string {
+	return "---\nid: \"post\"\ntitle: \"Post\"\nslug: \"post\"\npublish_date: \"2024-01-01\"\npublished: true\n---\n" + body
+}
+
+// writeTestSnippets adds snippets to a test blog and points the include
+// shortcode at them for the rest of the test
+func writeTestSnippets(t *testing.T, dir string, snippets map[string]string) {
+	t.Helper()
+
+	snippetDir := filepath.Join(dir, "snippets")
+	if err := os.MkdirAll(snippetDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range snippets {
+		if err := os.WriteFile(filepath.Join(snippetDir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	utils.SetSnippetDir(snippetDir)
+	t.Cleanup(func() { utils.SetSnippetDir("") })
+}
+
+func TestLoadBlogDataShortcodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{name: "unknown shortcode", body: "{{< vimeo 123 >}}\n", wantErr: `unknown shortcode "vimeo"`},
+		{name: "unclosed callout", body: "{{< callout >}}\nbody\n", wantErr: "is not closed"},
+		{name: "bad callout type", body: "{{< callout danger >}}body{{< /callout >}}\n", wantErr: `unknown callout type "danger"`},
+		{name: "missing snippet", body: "{{< include missing >}}\n", wantErr: `snippet "missing.md"`},
+		{name: "nested unknown shortcode", body: "{{< callout >}}\n{{< vimeo 123 >}}\n{{< /callout >}}\n", wantErr: `unknown shortcode "vimeo"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeTestBlog(t, map[string]string{"post.md": shortcodeTestPost(tt.body)})
+
+			_, err := loadBlogData(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.Contains(err.Error(), "post.md") {
+				t.Errorf("Expected an error naming post.md and containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestBlogPostHandlerShortcodes(t *testing.T) {
+	dir := writeTestBlog(t, map[string]string{"post.md": shortcodeTestPost(`Intro.
+
+{{< figure src="/static/img/a.png" srcset="/static/img/a.png 1x, /static/img/a@2x.png 2x" alt="A chart" caption="Source: [the data](https://example.com/data)" >}}
+
+{{< callout type="warning" >}}
+Mind the **gap**.
+{{< /callout >}}
+
+{{< youtube dQw4w9WgXcQ title="A talk" >}}
+
+{{< gist octocat/aa5a315d61ae9438b18d file="hello.go" >}}
+
+{{< include cta >}}
+
+Write {{</* youtube ID */>}} to link a video.
+
+` + "```\n{{< not-a-shortcode >}}\n```\n")})
+	writeTestSnippets(t, dir, map[string]string{"cta.md": "Subscribe for *more*.\n"})
+
+	loadTestBlog(t, dir)
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/writings/post", nil), map[string]string{"slug": "post"})
+	rr := httptest.NewRecorder()
+	if err := BlogPostHandler(rr, req); err != nil {
+		t.Fatalf("BlogPostHandler returned an error: %v", err)
+	}
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		`srcset="/static/img/a.png 1x, /static/img/a@2x.png 2x"`,
+		`<figcaption>Source: <a href="https://example.com/data"`,
+		`<aside class="callout callout-warning" role="note">`,
+		`<strong>gap</strong>`,
+		`href="https://www.youtube.com/watch?v=dQw4w9WgXcQ"`,
+		`data-embed-src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"`,
+		`href="https://gist.github.com/octocat/aa5a315d61ae9438b18d#file-hello-go"`,
+		`Subscribe for <em>more</em>.`,
+		`Write {{&lt; youtube ID &gt;}} to link a video.`,
+		`{{&lt; not-a-shortcode &gt;}}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected post page to contain %s", want)
+		}
+	}
+	if strings.Contains(body, "<iframe") || strings.Contains(body, `class="shortcode-error"`) {
+		t.Error("Expected embeds as placeholders and no shortcode errors")
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// sitemapPages are the pages outside the blog, with their change frequency
+// and priority
+var sitemapPages = []sitemapURL{
+	{Loc: "/", ChangeFreq: "weekly", Priority: "1.0"},
+	{Loc: "/about", ChangeFreq: "monthly", Priority: "0.8"},
+	{Loc: "/products", ChangeFreq: "monthly", Priority: "0.8"},
+	{Loc: "/contact", ChangeFreq: "monthly", Priority: "0.5"},
+	{Loc: "/writings", ChangeFreq: "weekly", Priority: "0.9"},
+}
+
+// Sitemap protocol document model (https://www.sitemaps.org/protocol.html)
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	XHTMLNS string       `xml:"xmlns:xhtml,attr,omitempty"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc        string             `xml:"loc"`
+	LastMod    string             `xml:"lastmod,omitempty"`
+	ChangeFreq string             `xml:"changefreq,omitempty"`
+	Priority   string             `xml:"priority,omitempty"`
+	Alternates []sitemapAlternate `xml:"xhtml:link"`
+}
+
+// sitemapAlternate points search engines at the page in another language
+type sitemapAlternate struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// sitemapURLs lists every public page: the fixed pages, then the blog in each
+// of its languages. Locations are paths; the caller makes them absolute.
+func (b *BlogData) sitemapURLs() []sitemapURL {
+	urls := append([]sitemapURL(nil), sitemapPages...)
+	if b.languageViews == nil {
+		return append(urls, b.blogSitemapURLs()...)
+	}
+	for _, language := range b.Languages {
+		urls = append(urls, b.languageViews[language.Code].blogSitemapURLs()...)
+	}
+	return urls
+}
+
+// blogSitemapURLs lists the published posts, series, category, author and tag
+// pages and the date archives of one language, under its prefix. Posts with
+// translations list them as alternates.
+func (b *BlogData) blogSitemapURLs() []sitemapURL {
+	var urls []sitemapURL
+	prefix := b.Prefix
+	if prefix != "" {
+		urls = append(urls, sitemapURL{Loc: prefix + "/writings", ChangeFreq: "weekly", Priority: "0.9"})
+	}
+
+	for _, post := range b.Posts {
+		entry := sitemapURL{
+			Loc:        post.URL(),
+			LastMod:    sitemapDate(post.lastModified()),
+			ChangeFreq: "monthly",
+			Priority:   "0.7",
+		}
+		if len(post.Translations) > 0 {
+			entry.Alternates = append(entry.Alternates, sitemapAlternate{Rel: "alternate", Hreflang: post.Lang, Href: post.URL()})
+			for _, translation := range post.Translations {
+				entry.Alternates = append(entry.Alternates, sitemapAlternate{Rel: "alternate", Hreflang: translation.Lang, Href: translation.URL})
+			}
+		}
+		urls = append(urls, entry)
+	}
+
+	seriesSlugs := make([]string, 0, len(b.postsBySeries))
+	for slug := range b.postsBySeries {
+		seriesSlugs = append(seriesSlugs, slug)
+	}
+	sort.Strings(seriesSlugs)
+	for _, slug := range seriesSlugs {
+		parts := b.postsBySeries[slug]
+		urls = append(urls, sitemapURL{
+			Loc:        prefix + parts[0].Series.URL(),
+			LastMod:    sitemapDate(newestModified(parts)),
+			ChangeFreq: "weekly",
+			Priority:   "0.6",
+		})
+	}
+
+	for _, category := range b.Categories {
+		if posts := b.PostsByCategory(category.Slug); len(posts) > 0 {
+			urls = append(urls, sitemapURL{Loc: prefix + category.URL(), LastMod: sitemapDate(posts[0].PublishDate), ChangeFreq: "weekly", Priority: "0.5"})
+		}
+	}
+	for _, author := range b.Authors {
+		if posts := b.PostsByAuthor(author.ID); len(posts) > 0 {
+			urls = append(urls, sitemapURL{Loc: prefix + author.URL(), LastMod: sitemapDate(posts[0].PublishDate), ChangeFreq: "weekly", Priority: "0.4"})
+		}
+	}
+	tags := b.TagCounts()
+	if len(tags) > 0 {
+		urls = append(urls, sitemapURL{Loc: prefix + "/writings/tags", ChangeFreq: "weekly", Priority: "0.4"})
+	}
+	for _, tag := range tags {
+		posts := b.PostsByTag(tag.Name)
+		urls = append(urls, sitemapURL{Loc: prefix + tag.URL(), LastMod: sitemapDate(posts[0].PublishDate), ChangeFreq: "weekly", Priority: "0.4"})
+	}
+
+	if len(b.Posts) > 0 {
+		urls = append(urls, sitemapURL{Loc: prefix + ArchivePeriod{}.URL(), LastMod: sitemapDate(b.Posts[0].PublishDate), ChangeFreq: "weekly", Priority: "0.4"})
+	}
+	for _, year := range b.archiveYears {
+		posts := b.PostsInPeriod(ArchivePeriod{Year: year.Year})
+		urls = append(urls, sitemapURL{Loc: prefix + year.URL(), LastMod: sitemapDate(posts[0].PublishDate), ChangeFreq: "monthly", Priority: "0.3"})
+		for _, month := range year.Months {
+			posts := b.PostsInPeriod(ArchivePeriod{Year: month.Year, Month: month.Month})
+			urls = append(urls, sitemapURL{Loc: prefix + month.URL(), LastMod: sitemapDate(posts[0].PublishDate), ChangeFreq: "yearly", Priority: "0.3"})
+		}
+	}
+
+	return urls
+}
+
+// newestModified returns the latest lastModified of posts
+func newestModified(posts []BlogPost) time.Time {
+	var newest time.Time
+	for _, post := range posts {
+		if modified := post.lastModified(); modified.After(newest) {
+			newest = modified
+		}
+	}
+	return newest
+}
+
+func sitemapDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// SitemapHandler serves /sitemap.xml, generated from the published content
+func SitemapHandler(w http.ResponseWriter, r *http.Request) error {
+	blogData, err := content().Blog()
+	if err != nil {
+		return err
+	}
+
+	siteURL := blogData.siteURL()
+	doc := sitemapURLSet{URLs: blogData.sitemapURLs()}
+	for i := range doc.URLs {
+		doc.URLs[i].Loc = siteURL + doc.URLs[i].Loc
+		for j := range doc.URLs[i].Alternates {
+			doc.URLs[i].Alternates[j].Href = siteURL + doc.URLs[i].Alternates[j].Href
+			doc.XHTMLNS = "http://www.w3.org/1999/xhtml"
+		}
+	}
+
+	output, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(output)
+	return nil
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+// categorySwatches maps the color names used in blogs.yaml to CSS colors
+var categorySwatches = map[string]string{
+	"gray":   "#6b7280",
+	"red":    "#ef4444",
+	"orange": "#f97316",
+	"yellow": "#eab308",
+	"green":  "#22c55e",
+	"teal":   "#14b8a6",
+	"cyan":   "#06b6d4",
+	"blue":   "#3b82f6",
+	"indigo": "#6366f1",
+	"purple": "#a855f7",
+	"pink":   "#ec4899",
+}
+
+// URL returns the category's landing page
+func (c Category) URL() string {
+	return "/writings/category/" + url.PathEscape(c.Slug)
+}
+
+// Swatch returns the category color as CSS: a known color name, a #hex value
+// as given, or gray
+func (c Category) Swatch() string {
+	if swatch, ok := categorySwatches[strings.ToLower(c.Color)]; ok {
+		return swatch
+	}
+	if strings.HasPrefix(c.Color, "#") {
+		return c.Color
+	}
+	return categorySwatches["gray"]
+}
+
+// PostCategory returns the configured category of the page's post, or nil
+func (d BlogPageData) PostCategory() *Category {
+	if d.Post == nil {
+		return nil
+	}
+	return getCategoryBySlug(d.Categories, d.Post.Category)
+}
+
+// TagURL returns the landing page of a tag
+func TagURL(tag string) string {
+	return "/writings/tag/" + url.PathEscape(tag)
+}
+
+// TagCount is a tag and the number of published posts carrying it
+type TagCount struct {
+	Name  string
+	Count int
+}
+
+// URL returns the tag's landing page
+func (t TagCount) URL() string {
+	return TagURL(t.Name)
+}
+
+// TagCounts returns every tag of the published posts, most used first and
+// then by name
+func (b *BlogData) TagCounts() []TagCount {
+	counts := make([]TagCount, 0, len(b.postsByTag))
+	for tag, posts := range b.postsByTag {
+		counts = append(counts, TagCount{Name: tag, Count: len(posts)})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Name < counts[j].Name
+	})
+	return counts
+}
+
+// BlogTagHandler serves /writings/tag/{tag}, the posts carrying a tag
+func BlogTagHandler(w http.ResponseWriter, r *http.Request) error {
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	tag := mux.Vars(r)["tag"]
+	posts := blogData.PostsByTag(tag)
+	if len(posts) == 0 {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	pageData := BlogPageData{BlogData: *blogData, SelectedTag: tag, ListingPath: blogData.Prefix + TagURL(tag)}
+	pageData.Title = "Posts tagged " + tag
+	pageData.Description = fmt.Sprintf("%d %s tagged %s", len(posts), pluralPosts(len(posts)), tag)
+	return serveLanding(w, r, blogData, posts, pageData)
+}
+
+// BlogCategoryHandler serves /writings/category/{category} for the categories
+// configured in blogs.yaml
+func BlogCategoryHandler(w http.ResponseWriter, r *http.Request) error {
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	category := getCategoryBySlug(blogData.Categories, mux.Vars(r)["category"])
+	if category == nil {
+		http.NotFound(w, r)
+		return nil
+	}
+
+	pageData := BlogPageData{BlogData: *blogData, SelectedCategory: category.Slug, Category: category, ListingPath: blogData.Prefix + category.URL()}
+	pageData.Title = category.Name
+	pageData.Description = category.Description
+	return serveLanding(w, r, blogData, blogData.PostsByCategory(category.Slug), pageData)
+}
+
+// serveLanding renders a tag or category landing page: the listing with the
+// page's own heading, sorting and pagination. pageData carries the blog data,
+// title, description and selection; the rest is filled in here. HTMX requests
+// get just the list.
+func serveLanding(w http.ResponseWriter, r *http.Request, blogData *BlogData, posts []BlogPost, pageData BlogPageData) error {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return err
+	}
+	templates, err = templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "blog", "*.html"))
+	if err != nil {
+		return err
+	}
+
+	sortBy, sortOrder := archiveSort(r, blogData.Archive)
+	posts = sortPosts(posts, sortBy, sortOrder)
+	postsPerPage := blogData.Archive.PostsPerPage
+	paginatedPosts, page, totalPages := paginatePosts(posts, parseIntParam(r, "page", 1), postsPerPage)
+
+	pageData.PageName = "writings"
+	pageData.CanonicalURL = blogData.siteURL() + pageData.ListingURL(page, blogData.Archive.SortBy, blogData.Archive.SortOrder)
+	pageData.CurrentPage = page
+	pageData.TotalPages = totalPages
+	pageData.PostsPerPage = postsPerPage
+	pageData.PostCount = len(posts)
+	pageData.SortBy = sortBy
+	pageData.SortOrder = sortOrder
+	pageData.Feeds = feedLinks(blogData, pageData.SelectedTag, pageData.SelectedCategory)
+	pageData.Posts = paginatedPosts
+	if newsletter != nil {
+		pageData.NewsletterForm = &NewsletterForm{}
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		return templates.ExecuteTemplate(w, "posts-list", pageData)
+	}
+
+	return templates.ExecuteTemplate(w, "blog", pageData)
+}
+
+// BlogTagsHandler serves /writings/tags, every tag with its post count
+func BlogTagsHandler(w http.ResponseWriter, r *http.Request) error {
+	templates, err := utils.Templates.Templates.Clone()
+	if err != nil {
+		return err
+	}
+	templates, err = templates.ParseGlob(filepath.Join(utils.Templates.BasePath, "blog", "*.html"))
+	if err != nil {
+		return err
+	}
+
+	blogData, err := languageBlog(r)
+	if err != nil {
+		return err
+	}
+
+	pageData := BlogPageData{
+		BlogData:     *blogData,
+		PageName:     "writings",
+		CanonicalURL: blogData.siteURL() + blogData.Prefix + "/writings/tags",
+		TagIndex:     blogData.TagCounts(),
+		Feeds:        feedLinks(blogData, "", ""),
+	}
+	pageData.Title = "Tags"
+	pageData.Description = "Every topic written about, with the number of posts on each"
+
+	return templates.ExecuteTemplate(w, "blog", pageData)
+}
+
+func pluralPosts(n int) string {
+	if n == 1 {
+		return "post"
+	}
+	return "posts"
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func loadTaxonomyTestBlog(t *testing.T) *BlogData {
+	t.Helper()
+
+	dir := writeTestBlog(t, map[string]string{
+		"one.yaml":   testPost("one", "2024-01-01", `category: "engineering"`, `tags: ["go", "testing"]`, `content: "One."`),
+		"two.yaml":   testPost("two", "2024-02-01", `category: "engineering"`, `tags: ["go"]`, `content: "Two."`),
+		"three.yaml": testPost("three", "2024-03-01", `tags: ["baking"]`, `content: "Three."`),
+	})
+	blogData := loadTestBlog(t, dir)
+	blogData.Categories[0].Description = "Deep dives into how things are built"
+	blogData.Categories[0].Color = "blue"
+	return blogData
+}
+
+func TestTagCounts(t *testing.T) {
+	blogData := loadTaxonomyTestBlog(t)
+
+	var got []string
+	for _, tag := range blogData.TagCounts() {
+		got = append(got, fmt.Sprintf("%s:%d", tag.Name, tag.Count))
+	}
+	if strings.Join(got, ",") != "go:2,baking:1,testing:1" {
+		t.Errorf("TagCounts() = %v, want most used first, then by name", got)
+	}
+}
+
+func TestBlogLandingHandlers(t *testing.T) {
+	loadTaxonomyTestBlog(t)
+
+	tests := []struct {
+		name           string
+		handler        func(http.ResponseWriter, *http.Request) error
+		path           string
+		vars           map[string]string
+		expectedStatus int
+		want           []string
+		notWant        []string
+	}{
+		{
+			name:           "tag",
+			handler:        BlogTagHandler,
+			path:           "/writings/tag/go",
+			vars:           map[string]string{"tag": "go"},
+			expectedStatus: http.StatusOK,
+			want: []string{
+				"#go", "2 posts", `<title>Posts tagged go — ankush.fyi</title>`,
+				`<link rel="canonical" href="https://example.com/writings/tag/go">`,
+				`href="/writings/tag/go/feed.xml"`,
+				`hx-get="/writings/tag/go?order=asc&amp;sort=publish_date"`,
+			},
+			notWant: []string{`href="/writings/three"`},
+		},
+		{
+			name:           "category",
+			handler:        BlogCategoryHandler,
+			path:           "/writings/category/engineering",
+			vars:           map[string]string{"category": "engineering"},
+			expectedStatus: http.StatusOK,
+			want: []string{
+				"engineering", "Deep dives into how things are built", "#3b82f6",
+				`<link rel="canonical" href="https://example.com/writings/category/engineering">`,
+			},
+			notWant: []string{`href="/writings/three"`},
+		},
+		{name: "unknown tag", handler: BlogTagHandler, path: "/writings/tag/rust", vars: map[string]string{"tag": "rust"}, expectedStatus: http.StatusNotFound},
+		{name: "unknown category", handler: BlogCategoryHandler, path: "/writings/category/cooking", vars: map[string]string{"category": "cooking"}, expectedStatus: http.StatusNotFound},
+		{
+			name:           "tag index",
+			handler:        BlogTagsHandler,
+			path:           "/writings/tags",
+			expectedStatus: http.StatusOK,
+			want:           []string{`href="/writings/tag/go"`, `href="/writings/tag/baking"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.vars != nil {
+				req = mux.SetURLVars(req, tt.vars)
+			}
+			rr := httptest.NewRecorder()
+
+			if err := tt.handler(rr, req); err != nil {
+				t.Fatalf("Handler returned an error: %v", err)
+			}
+			if status := rr.Code; status != tt.expectedStatus {
+				t.Fatalf("Handler returned wrong status code: got %v want %v", status, tt.expectedStatus)
+			}
+
+			body := rr.Body.String()
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected %s to contain %s", tt.path, want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(body, notWant) {
+					t.Errorf("Expected %s not to contain %s", tt.path, notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestWritingsHandlerFilterCanonical(t *testing.T) {
+	loadTaxonomyTestBlog(t)
+
+	rr := httptest.NewRecorder()
+	if err := WritingsHandler(rr, httptest.NewRequest("GET", "/writings?tag=go", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(rr.Body.String(), `<link rel="canonical" href="https://example.com/writings/tag/go">`) {
+		t.Error("Expected a tag-filtered listing to point at the tag page")
+	}
+}
+
+func TestSitemapTaxonomyPages(t *testing.T) {
+	blogData := loadTaxonomyTestBlog(t)
+
+	locs := make(map[string]bool)
+	for _, u := range blogData.sitemapURLs() {
+		locs[u.Loc] = true
+	}
+	for _, loc := range []string{"/writings/category/engineering", "/writings/tags", "/writings/tag/go", "/writings/tag/baking"} {
+		if !locs[loc] {
+			t.Errorf("Expected the sitemap to list %s", loc)
+		}
+	}
+}
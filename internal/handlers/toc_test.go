@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/thinkingojha/go-htmx/internal/utils"
+)
+
+func TestBlogPostHandlerTOC(t *testing.T) {
+	body := "## Setup\\n\\n### Install\\n\\n### Configure\\n\\n#### Advanced `flags`\\n\\n## Setup\\n\\nDone.\\n"
+	dir := writeTestBlog(t, map[string]string{
+		"toc.yaml":    testPost("toc", "2024-01-01", `content: "`+body+`"`),
+		"no-toc.yaml": testPost("no-toc", "2024-01-01", `toc: false`, `content: "`+body+`"`),
+	})
+	loadTestBlog(t, dir)
+
+	render := func(slug string) string {
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/writings/"+slug, nil), map[string]string{"slug": slug})
+		rr := httptest.NewRecorder()
+		if err := BlogPostHandler(rr, req); err != nil {
+			t.Fatalf("BlogPostHandler returned an error: %v", err)
+		}
+		return rr.Body.String()
+	}
+
+	page := render("toc")
+	for _, want := range []string{
+		`<h2 id="setup">Setup <a class="heading-anchor" href="#setup"`,
+		`<h2 id="setup-2">`,
+		`<h4 id="advanced-flags">`,
+		`aria-label="Table of contents"`,
+		`<li><a href="#install">Install</a></li>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Expected post page to contain %s", want)
+		}
+	}
+
+	_, toc := utils.RenderMarkdownWithTOC(strings.ReplaceAll(body, `\n`, "\n"))
+	if len(toc) != 2 || len(toc[0].Children) != 2 || len(toc[0].Children[1].Children) != 1 {
+		t.Errorf("Unexpected TOC nesting: %+v", toc)
+	}
+
+	page = render("no-toc")
+	if strings.Contains(page, `aria-label="Table of contents"`) {
+		t.Error("Expected no table of contents with toc: false")
+	}
+	if !strings.Contains(page, `<h2 id="setup">`) {
+		t.Error("Expected headings to keep their ids with toc: false")
+	}
+}
@@ -3,9 +3,11 @@ package middleware
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"github.com/thinkingojha/go-htmx/internal/config"
 	"github.com/thinkingojha/go-htmx/internal/logger"
@@ -99,3 +101,87 @@ func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
 		return http.TimeoutHandler(next, timeout, "Request Timeout")
 	}
 }
+
+// Redirects answers requests for moved or removed paths before they reach
+// next. lookup returns the target and status for a path; 410 Gone responses
+// are served by gone. The query string is carried over to targets without one.
+func Redirects(lookup func(path string) (string, int, bool), gone http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target, status, ok := lookup(r.URL.Path)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if status == http.StatusGone {
+				gone.ServeHTTP(w, r)
+				return
+			}
+			if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, status)
+		})
+	}
+}
+
+// Languages guards routes under a /{lang} prefix: requests whose language
+// known does not recognise are answered by notFound
+func Languages(known func(code string) bool, notFound http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !known(mux.Vars(r)["lang"]) {
+				notFound.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PageViews calls record for every page view: a GET answered 200 with HTML.
+// HTMX requests fetch part of a page already counted, so they are skipped.
+func PageViews(record func(r *http.Request)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.Header.Get("HX-Request") == "true" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rw := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+			if rw.status == http.StatusOK && strings.HasPrefix(rw.contentType, "text/html") {
+				record(r)
+			}
+		})
+	}
+}
+
+// responseRecorder notes the status and content type of a response as it is
+// written, sniffing the type like net/http does when none is set
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	contentType string
+	wrote       bool
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+		rw.contentType = rw.Header().Get("Content-Type")
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(p []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+		rw.contentType = rw.Header().Get("Content-Type")
+	}
+	if !rw.wrote && rw.contentType == "" {
+		rw.contentType = http.DetectContentType(p)
+	}
+	rw.wrote = true
+	return rw.ResponseWriter.Write(p)
+}
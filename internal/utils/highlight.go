@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// codeFence is the parsed info string of a fenced code block, such as
+// ```go {3-5} title="main.go" linenos
+type codeFence struct {
+	Language    string
+	Highlight   [][2]int // inclusive line ranges
+	Title       string
+	LineNumbers bool
+}
+
+// parseCodeFence reads the language, highlighted line ranges, title (or
+// filename) and linenos/nolinenos flags from a fence info string
+func parseCodeFence(info string, lineNumbers bool) codeFence {
+	fence := codeFence{LineNumbers: lineNumbers}
+
+	for i, field := range splitFenceInfo(info) {
+		key, value, hasValue := strings.Cut(field, "=")
+		switch {
+		case strings.HasPrefix(field, "{") && strings.HasSuffix(field, "}"):
+			fence.Highlight = append(fence.Highlight, parseLineRanges(field[1:len(field)-1])...)
+		case hasValue && (key == "title" || key == "filename" || key == "file"):
+			fence.Title = strings.Trim(value, `"'`)
+		case field == "linenos":
+			fence.LineNumbers = true
+		case field == "nolinenos":
+			fence.LineNumbers = false
+		case i == 0:
+			fence.Language = strings.ToLower(field)
+		}
+	}
+	return fence
+}
+
+// splitFenceInfo splits an info string on spaces, keeping quoted values and
+// {...} ranges together
+func splitFenceInfo(info string) []string {
+	var fields []string
+	var current strings.Builder
+	var quote rune
+	braces := 0
+
+	for _, r := range strings.TrimSpace(info) {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '{':
+			// "go{3-5}" is "go {3-5}"
+			if braces == 0 && current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			braces++
+		case r == '}':
+			braces--
+		case r == ' ' && braces == 0:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
+
+// parseLineRanges parses "1,3-5" into [[1 1] [3 5]], skipping invalid parts
+func parseLineRanges(spec string) [][2]int {
+	var ranges [][2]int
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(from)
+		if err != nil || start < 1 {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil || end < start {
+				continue
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+// writeCodeBlock writes a fenced code block as class-based highlighted HTML,
+// inside a figure with the title as caption when one is given
+func writeCodeBlock(w io.Writer, code string, fence codeFence) {
+	lexer := lexers.Get(fence.Language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	formatter := chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(fence.LineNumbers),
+		chromahtml.HighlightLines(fence.Highlight),
+	)
+
+	var highlighted bytes.Buffer
+	iterator, err := lexer.Tokenise(nil, code)
+	if err == nil {
+		err = formatter.Format(&highlighted, highlightStyle(), iterator)
+	}
+	if err != nil {
+		highlighted.Reset()
+		fmt.Fprintf(&highlighted, "<pre><code>%s</code></pre>", html.EscapeString(code))
+	}
+
+	io.WriteString(w, `<figure class="code-block"`)
+	if fence.Language != "" {
+		fmt.Fprintf(w, ` data-lang="%s"`, html.EscapeString(fence.Language))
+	}
+	io.WriteString(w, ">\n")
+	if fence.Title != "" {
+		fmt.Fprintf(w, "<figcaption>%s</figcaption>\n", html.EscapeString(fence.Title))
+	}
+	w.Write(highlighted.Bytes())
+	io.WriteString(w, "</figure>\n")
+}
+
+func highlightStyle() *chroma.Style {
+	return styles.Get(markdownOptions.HighlightStyle)
+}
+
+// HighlightCSS returns the stylesheet for highlighted code blocks in the
+// configured style
+func HighlightCSS() (string, error) {
+	var css bytes.Buffer
+	formatter := chromahtml.New(chromahtml.WithClasses(true))
+	if err := formatter.WriteCSS(&css, highlightStyle()); err != nil {
+		return "", err
+	}
+	return css.String(), nil
+}
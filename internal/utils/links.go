@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	markdownLinkPattern = regexp.MustCompile(`(!?)\[[^\]]*\]\(\s*<?([^)\s>]+)`)
+	htmlLinkPattern     = regexp.MustCompile(`\b(href|src)\s*=\s*"([^"]*)"`)
+)
+
+// ContentLink is a link or image reference in post markdown
+type ContentLink struct {
+	URL   string
+	Line  int
+	Image bool
+}
+
+// ContentLinks returns the targets of the markdown links and images, HTML
+// href and src attributes and shortcode src arguments in s, in order. Fenced
+// code blocks are skipped.
+func ContentLinks(s string) []ContentLink {
+	code := fencedCodeRanges(s)
+	inCode := func(offset int) bool {
+		for _, r := range code {
+			if offset >= r[0] && offset < r[1] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var links []ContentLink
+	for _, m := range markdownLinkPattern.FindAllStringSubmatchIndex(s, -1) {
+		if !inCode(m[0]) {
+			links = append(links, ContentLink{URL: s[m[4]:m[5]], Line: lineAt(s, m[0]), Image: m[3] > m[2]})
+		}
+	}
+	for _, m := range htmlLinkPattern.FindAllStringSubmatchIndex(s, -1) {
+		if !inCode(m[0]) {
+			links = append(links, ContentLink{URL: s[m[4]:m[5]], Line: lineAt(s, m[0]), Image: s[m[2]:m[3]] == "src"})
+		}
+	}
+
+	sort.SliceStable(links, func(i, j int) bool { return links[i].Line < links[j].Line })
+	return links
+}
+
+// IsSiteLink reports whether url points at a page on this site by path, such
+// as /writings/x, rather than another host, an anchor or a relative path
+func IsSiteLink(url string) bool {
+	return strings.HasPrefix(url, "/") && !strings.HasPrefix(url, "//")
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MailMessage is an email with an HTML body and a plain text alternative
+type MailMessage struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+	Headers map[string]string // extra headers, such as List-Unsubscribe
+}
+
+// Mailer sends email
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+// SMTPMailer sends email through an SMTP server, upgrading the connection
+// with STARTTLS when the server offers it
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string // no authentication while empty
+	Password string
+	From     string // such as "Ankush <hello@ankush.fyi>"
+}
+
+// Send delivers msg to its recipient
+func (m *SMTPMailer) Send(msg MailMessage) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("from address %q: %w", m.From, err)
+	}
+	data, err := msg.Bytes(m.From)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, from.Address, []string{msg.To}, data)
+}
+
+// IsPermanentMailError reports whether the server refused a message for good,
+// with a 5xx reply, as it does for an address that does not exist
+func IsPermanentMailError(err error) bool {
+	var protoErr *textproto.Error
+	return errors.As(err, &protoErr) && protoErr.Code >= 500 && protoErr.Code < 600
+}
+
+// Bytes renders the message as multipart/alternative MIME, ready to send
+func (msg MailMessage) Bytes(from string) ([]byte, error) {
+	boundary := make([]byte, 12)
+	if _, err := rand.Read(boundary); err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if at := strings.LastIndexByte(addr.Address, '@'); at >= 0 {
+			domain = addr.Address[at+1:]
+		}
+	}
+
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", "<"+hex.EncodeToString(id)+"@"+domain+">")
+	header("MIME-Version", "1.0")
+
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		header(name, msg.Headers[name])
+	}
+
+	b := hex.EncodeToString(boundary)
+	header("Content-Type", `multipart/alternative; boundary="`+b+`"`)
+	buf.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", b)
+		header("Content-Type", part.contentType)
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		// In text mode the writer ends every line with CRLF
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", b)
+	return buf.Bytes(), nil
+}
@@ -1,31 +1,312 @@
 package utils
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 )
 
-// This renders markdown blogs into html and saves the files in internal/template/blog/pages
+// MarkdownOptions toggles the optional markdown extensions. Every page that
+// renders markdown, from posts and feeds to the /write preview, goes through
+// the same pipeline with these options.
+type MarkdownOptions struct {
+	Footnotes       bool // [^1] references and [^1]: notes
+	Sidenotes       bool // show footnotes beside the text rather than at the end
+	DefinitionLists bool // "term" followed by ": definition" lines
+	Math            bool // $inline$ and $$display$$ TeX, rendered as MathML
+	Smartypants     bool // curly quotes, dashes and fractions
+	TaskLists       bool // "- [ ]" and "- [x]" list items as checkboxes
+	Highlight       bool // syntax highlight fenced code blocks
+	LineNumbers     bool // number code lines unless a block says nolinenos
+	HighlightStyle  string
+}
+
+// DefaultMarkdownOptions enables every extension
+func DefaultMarkdownOptions() MarkdownOptions {
+	return MarkdownOptions{
+		Footnotes:       true,
+		Sidenotes:       true,
+		DefinitionLists: true,
+		Math:            true,
+		Smartypants:     true,
+		TaskLists:       true,
+		Highlight:       true,
+		HighlightStyle:  "github-dark",
+	}
+}
+
+var markdownOptions = DefaultMarkdownOptions()
+
+// SetMarkdownOptions sets the extensions used by every markdown render
+func SetMarkdownOptions(opts MarkdownOptions) {
+	markdownOptions = opts
+}
+
+// MarkdownToHTML renders markdown the way post pages do, without the heading
+// anchor links
+func MarkdownToHTML(s string) string {
+	html, _ := renderMarkdown(s, false)
+	return html
+}
+
+// RenderMarkdownWithTOC renders post markdown, giving every heading a unique
+// id and h2–h4 headings a hover anchor link. It returns the nested table of
+// contents built from those headings.
+func RenderMarkdownWithTOC(s string) (string, []TOCEntry) {
+	return renderMarkdown(s, true)
+}
+
+func renderMarkdown(s string, anchors bool) (string, []TOCEntry) {
+	ctx := &shortcodeContext{snippetDir: snippetDir}
+	return ctx.renderMarkdown(s, anchors)
+}
+
+// renderMarkdown expands shortcodes, renders the markdown around them and
+// puts the rendered shortcodes in place
+func (c *shortcodeContext) renderMarkdown(s string, anchors bool) (string, []TOCEntry) {
+	opts := markdownOptions
+
+	source, found, err := extractShortcodes(s)
+	if err != nil {
+		source, found = s, nil
+	}
+
+	extensions := parser.NoIntraEmphasis | parser.Tables | parser.FencedCode |
+		parser.Autolink | parser.Strikethrough | parser.SpaceHeadings |
+		parser.HeadingIDs | parser.BackslashLineBreak
+	if opts.Footnotes {
+		extensions |= parser.Footnotes
+	}
+	if opts.DefinitionLists {
+		extensions |= parser.DefinitionLists
+	}
+	if opts.Math {
+		extensions |= parser.MathJax
+	}
+	doc := parser.NewWithExtensions(extensions).Parse([]byte(source))
+
+	r := &markdownRenderer{
+		opts:    opts,
+		anchors: anchors,
+		tasks:   make(map[*ast.Text]bool),
+	}
+	toc := r.prepare(doc)
+
+	flags := html.HrefTargetBlank | html.LazyLoadImages | html.Safelink
+	if opts.Smartypants {
+		flags |= html.Smartypants | html.SmartypantsFractions | html.SmartypantsDashes | html.SmartypantsLatexDashes
+	}
+	if opts.Footnotes && !opts.Sidenotes {
+		flags |= html.FootnoteReturnLinks
+	}
+	r.html = html.NewRenderer(html.RendererOptions{
+		Flags:          flags,
+		RenderNodeHook: r.renderNode,
+	})
+
+	output := c.replacePlaceholders(string(markdown.Render(doc, r.html)), found)
+	if err != nil {
+		output = shortcodeError(err) + output
+	}
+	return output, toc
+}
+
+// markdownRenderer holds the state of one render: the extensions in use and
+// the nodes rewritten before rendering
+type markdownRenderer struct {
+	opts     MarkdownOptions
+	anchors  bool
+	html     *html.Renderer
+	tasks    map[*ast.Text]bool // leading text of task list items, and whether checked
+	sidenote int
+}
+
+// prepare assigns heading ids, finds task list items and returns the table of
+// contents
+func (r *markdownRenderer) prepare(doc ast.Node) []TOCEntry {
+	var headings []TOCEntry
+	ids := make(map[string]int)
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+
+		switch node := node.(type) {
+		case *ast.Heading:
+			title := nodeText(node)
+			base := node.HeadingID
+			if base == "" {
+				base = slugify(title)
+			}
+			node.HeadingID = uniqueID(ids, base)
+			if node.Level >= tocMinLevel && node.Level <= tocMaxLevel {
+				headings = append(headings, TOCEntry{Level: node.Level, ID: node.HeadingID, Title: title})
+			}
+			return ast.SkipChildren
+
+		case *ast.ListItem:
+			if r.opts.TaskLists && node.RefLink == nil {
+				r.markTask(node)
+			}
+		}
+		return ast.GoToNext
+	})
+
+	return nestTOC(headings)
+}
+
+// markTask records a list item starting with "[ ] " or "[x] " as a task and
+// strips the marker from its text
+func (r *markdownRenderer) markTask(item *ast.ListItem) {
+	paragraph, ok := ast.GetFirstChild(item).(*ast.Paragraph)
+	if !ok {
+		return
+	}
+	text, ok := ast.GetFirstChild(paragraph).(*ast.Text)
+	if !ok || len(text.Literal) < 4 || text.Literal[0] != '[' || text.Literal[2] != ']' || text.Literal[3] != ' ' {
+		return
+	}
+
+	switch text.Literal[1] {
+	case ' ':
+		r.tasks[text] = false
+	case 'x', 'X':
+		r.tasks[text] = true
+	default:
+		return
+	}
+	text.Literal = text.Literal[4:]
+}
+
+// renderNode customises how some nodes render; it returns false to fall back
+// to the default renderer
+func (r *markdownRenderer) renderNode(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	switch node := node.(type) {
+	case *ast.Heading:
+		if r.anchors && !entering && node.Level >= tocMinLevel && node.Level <= tocMaxLevel {
+			fmt.Fprintf(w, ` <a class="heading-anchor" href="#%s" aria-label="Link to this section">#</a>`, node.HeadingID)
+		}
+
+	case *ast.Text:
+		if checked, ok := r.tasks[node]; ok {
+			if checked {
+				io.WriteString(w, `<input type="checkbox" class="task" disabled checked> `)
+			} else {
+				io.WriteString(w, `<input type="checkbox" class="task" disabled> `)
+			}
+		}
+
+	case *ast.CodeBlock:
+		if node.IsFenced && r.opts.Highlight {
+			writeCodeBlock(w, string(node.Literal), parseCodeFence(string(node.Info), r.opts.LineNumbers))
+			return ast.GoToNext, true
+		}
+
+	case *ast.Math:
+		io.WriteString(w, TeXToMathML(string(node.Literal), false))
+		return ast.GoToNext, true
+
+	case *ast.MathBlock:
+		if entering {
+			io.WriteString(w, TeXToMathML(string(node.Literal), true))
+			io.WriteString(w, "\n")
+		}
+		return ast.SkipChildren, true
+
+	case *ast.Link:
+		// Footnote references are written here because Safelink would
+		// otherwise reject their internal destinations
+		if node.NoteID != 0 {
+			if entering && r.opts.Sidenotes {
+				r.writeSidenote(w, node)
+			} else if entering {
+				io.WriteString(w, html.FootnoteRef("", node))
+			}
+			return ast.SkipChildren, true
+		}
 
-type Markdown struct {
-	renderer *html.Renderer
-	parser   *parser.Parser
+	case *ast.List:
+		// Sidenotes are written next to their reference, not at the end
+		if node.IsFootnotesList && r.opts.Sidenotes {
+			return ast.SkipChildren, true
+		}
+	}
+	return ast.GoToNext, false
 }
 
-func (m *Markdown) InitMarkdownRenderer() {
+// writeSidenote writes a footnote reference followed by the note itself. The
+// checkbox lets narrow screens toggle the note without JavaScript.
+func (r *markdownRenderer) writeSidenote(w io.Writer, link *ast.Link) {
+	r.sidenote++
+	id := fmt.Sprintf("sidenote-%d", r.sidenote)
 
-	extensions := parser.CommonExtensions | parser.AutoHeadingIDs | parser.NoEmptyLineBeforeBlock
-	m.parser = parser.NewWithExtensions(extensions)
+	fmt.Fprintf(w, `<label for="%s" class="sidenote-ref">%d</label>`, id, r.sidenote)
+	fmt.Fprintf(w, `<input type="checkbox" id="%s" class="sidenote-toggle">`, id)
+	fmt.Fprintf(w, `<span class="sidenote"><span class="sidenote-number">%d</span> `, r.sidenote)
 
-	htmlWithFlags := html.CommonFlags | html.HrefTargetBlank | html.LazyLoadImages | html.Safelink
-	opts := html.RendererOptions{
-		Flags: htmlWithFlags,
+	if link.Footnote != nil {
+		r.writeInline(w, link.Footnote)
 	}
-	m.renderer = html.NewRenderer(opts)
+	io.WriteString(w, "</span>")
 }
 
-func (m *Markdown) RenderHTML(doc []byte) ([]byte, error) {
-	document := m.parser.Parse(doc)
-	return markdown.Render(document, m.renderer), nil
+// writeInline renders the contents of a footnote without paragraph tags, so
+// it can sit inside the surrounding paragraph. Paragraphs are separated by
+// line breaks.
+func (r *markdownRenderer) writeInline(w io.Writer, note ast.Node) {
+	var buf bytes.Buffer
+	render := func(node ast.Node) {
+		ast.WalkFunc(node, func(node ast.Node, entering bool) ast.WalkStatus {
+			return r.html.RenderNode(&buf, node, entering)
+		})
+	}
+
+	for i, child := range note.GetChildren() {
+		paragraph, ok := child.(*ast.Paragraph)
+		if !ok {
+			render(child)
+			continue
+		}
+		if i > 0 {
+			buf.WriteString("<br>")
+		}
+		for _, inline := range paragraph.GetChildren() {
+			render(inline)
+		}
+	}
+	io.WriteString(w, strings.TrimSpace(buf.String()))
+}
+
+// CommentMarkdownToHTML renders reader-submitted markdown. Raw HTML and
+// images are dropped, links are limited to safe schemes and marked nofollow,
+// and headings render as plain paragraphs so a comment cannot outshout the
+// post. Shortcodes are not expanded.
+func CommentMarkdownToHTML(s string) string {
+	extensions := parser.NoIntraEmphasis | parser.FencedCode | parser.Autolink |
+		parser.Strikethrough | parser.SpaceHeadings | parser.BackslashLineBreak
+	doc := parser.NewWithExtensions(extensions).Parse([]byte(s))
+
+	renderer := html.NewRenderer(html.RendererOptions{
+		Flags: html.SkipHTML | html.SkipImages | html.Safelink | html.NofollowLinks |
+			html.NoreferrerLinks | html.NoopenerLinks | html.HrefTargetBlank,
+		RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+			if _, ok := node.(*ast.Heading); ok {
+				if entering {
+					io.WriteString(w, "<p>")
+				} else {
+					io.WriteString(w, "</p>\n")
+				}
+				return ast.GoToNext, true
+			}
+			return ast.GoToNext, false
+		},
+	})
+	return strings.TrimSpace(string(markdown.Render(doc, renderer)))
 }
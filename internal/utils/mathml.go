@@ -0,0 +1,260 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// mathIdentifiers maps TeX commands to the character rendered as an identifier
+var mathIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "iota": "ι", "kappa": "κ", "lambda": "λ",
+	"mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "rho": "ρ", "sigma": "σ", "tau": "τ",
+	"upsilon": "υ", "phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "ell": "ℓ", "hbar": "ℏ", "emptyset": "∅",
+}
+
+// mathOperators maps TeX commands to the character rendered as an operator
+var mathOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "int": "∫", "oint": "∮", "cdot": "⋅", "times": "×",
+	"div": "÷", "pm": "±", "mp": "∓", "leq": "≤", "le": "≤", "geq": "≥", "ge": "≥",
+	"neq": "≠", "ne": "≠", "approx": "≈", "equiv": "≡", "sim": "∼", "propto": "∝",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"iff": "⇔", "mapsto": "↦", "in": "∈", "notin": "∉", "subset": "⊂", "subseteq": "⊆",
+	"cup": "∪", "cap": "∩", "forall": "∀", "exists": "∃", "neg": "¬", "land": "∧",
+	"lor": "∨", "ldots": "…", "cdots": "⋯", "circ": "∘", "langle": "⟨", "rangle": "⟩",
+	"{": "{", "}": "}", "|": "‖",
+}
+
+// mathFunctions are rendered upright, as in TeX
+var mathFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "log": true, "ln": true, "exp": true,
+	"lim": true, "max": true, "min": true, "sup": true, "inf": true, "det": true,
+}
+
+// mathSpaces maps TeX spacing commands to MathML widths
+var mathSpaces = map[string]string{
+	",": "0.167em", ":": "0.222em", ";": "0.278em", " ": "0.333em", "quad": "1em", "qquad": "2em",
+}
+
+// TeXToMathML converts a practical subset of TeX math to presentation MathML:
+// identifiers, numbers, operators, ^ and _ scripts, {} groups, \frac, \sqrt,
+// \text, Greek letters and common symbols. The TeX source is kept as an
+// annotation.
+func TeXToMathML(tex string, display bool) string {
+	p := &texParser{src: []rune(strings.TrimSpace(tex))}
+	body := p.parseRow(0)
+
+	var b strings.Builder
+	b.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML"`)
+	if display {
+		b.WriteString(` display="block"`)
+	}
+	b.WriteString(`><semantics><mrow>`)
+	b.WriteString(body)
+	b.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString(`</annotation></semantics></math>`)
+	return b.String()
+}
+
+type texParser struct {
+	src []rune
+	pos int
+}
+
+// parseRow parses atoms until the end of input or an unmatched closing brace
+func (p *texParser) parseRow(depth int) string {
+	var b strings.Builder
+	for p.pos < len(p.src) {
+		if p.src[p.pos] == '}' {
+			if depth > 0 {
+				return b.String()
+			}
+			p.pos++
+			b.WriteString("<mo>}</mo>")
+			continue
+		}
+		atom, ok := p.parseAtom(depth)
+		if !ok {
+			continue
+		}
+		b.WriteString(p.parseScripts(atom, depth))
+	}
+	return b.String()
+}
+
+// parseScripts wraps base in msub, msup or msubsup when followed by _ or ^
+func (p *texParser) parseScripts(base string, depth int) string {
+	var sub, sup string
+	for p.skipSpace() && p.pos < len(p.src) && (p.src[p.pos] == '_' || p.src[p.pos] == '^') {
+		marker := p.src[p.pos]
+		p.pos++
+		p.skipSpace()
+		script, _ := p.parseAtom(depth)
+		if marker == '_' {
+			sub = script
+		} else {
+			sup = script
+		}
+	}
+
+	switch {
+	case sub != "" && sup != "":
+		return "<msubsup>" + base + sub + sup + "</msubsup>"
+	case sub != "":
+		return "<msub>" + base + sub + "</msub>"
+	case sup != "":
+		return "<msup>" + base + sup + "</msup>"
+	}
+	return base
+}
+
+// parseAtom parses one identifier, number, operator, group or command. It
+// reports false when it consumed only whitespace.
+func (p *texParser) parseAtom(depth int) (string, bool) {
+	if p.pos >= len(p.src) {
+		return "<mrow></mrow>", true
+	}
+
+	r := p.src[p.pos]
+	switch {
+	case unicode.IsSpace(r):
+		p.pos++
+		return "", false
+	case r == '{':
+		p.pos++
+		inner := p.parseRow(depth + 1)
+		if p.pos < len(p.src) {
+			p.pos++ // closing brace
+		}
+		return "<mrow>" + inner + "</mrow>", true
+	case r == '\\':
+		p.pos++
+		return p.parseCommand(depth), true
+	case unicode.IsDigit(r) || r == '.':
+		start := p.pos
+		for p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		return "<mn>" + string(p.src[start:p.pos]) + "</mn>", true
+	case unicode.IsLetter(r):
+		p.pos++
+		return "<mi>" + html.EscapeString(string(r)) + "</mi>", true
+	case r == '-':
+		p.pos++
+		return "<mo>−</mo>", true
+	case r == '\'':
+		p.pos++
+		return "<mo>′</mo>", true
+	}
+
+	p.pos++
+	return "<mo>" + html.EscapeString(string(r)) + "</mo>", true
+}
+
+// parseCommand parses the command after a backslash
+func (p *texParser) parseCommand(depth int) string {
+	if p.pos >= len(p.src) {
+		return "<mo>\\</mo>"
+	}
+
+	start := p.pos
+	if unicode.IsLetter(p.src[p.pos]) {
+		for p.pos < len(p.src) && unicode.IsLetter(p.src[p.pos]) {
+			p.pos++
+		}
+	} else {
+		p.pos++
+	}
+	name := string(p.src[start:p.pos])
+
+	switch name {
+	case "frac":
+		num, _ := p.parseArgument(depth)
+		den, _ := p.parseArgument(depth)
+		return "<mfrac>" + num + den + "</mfrac>"
+	case "sqrt":
+		if p.skipSpace() && p.pos < len(p.src) && p.src[p.pos] == '[' {
+			end := p.pos
+			for end < len(p.src) && p.src[end] != ']' {
+				end++
+			}
+			index := (&texParser{src: p.src[p.pos+1 : end]}).parseRow(0)
+			p.pos = min(end+1, len(p.src))
+			radicand, _ := p.parseArgument(depth)
+			return "<mroot>" + radicand + "<mrow>" + index + "</mrow></mroot>"
+		}
+		radicand, _ := p.parseArgument(depth)
+		return "<msqrt>" + radicand + "</msqrt>"
+	case "text", "mathrm", "operatorname":
+		text := p.rawArgument()
+		if name == "text" {
+			return "<mtext>" + html.EscapeString(text) + "</mtext>"
+		}
+		return `<mi mathvariant="normal">` + html.EscapeString(text) + "</mi>"
+	case "left", "right":
+		p.skipSpace()
+		if p.pos < len(p.src) && p.src[p.pos] == '.' {
+			p.pos++
+			return ""
+		}
+		delimiter, _ := p.parseAtom(depth)
+		return delimiter
+	}
+
+	if width, ok := mathSpaces[name]; ok {
+		return `<mspace width="` + width + `"></mspace>`
+	}
+	if ident, ok := mathIdentifiers[name]; ok {
+		return "<mi>" + ident + "</mi>"
+	}
+	if op, ok := mathOperators[name]; ok {
+		return "<mo>" + html.EscapeString(op) + "</mo>"
+	}
+	if mathFunctions[name] {
+		return "<mi>" + name + "</mi>"
+	}
+	return "<merror><mtext>\\" + html.EscapeString(name) + "</mtext></merror>"
+}
+
+// parseArgument parses a single command argument: a group or one atom
+func (p *texParser) parseArgument(depth int) (string, bool) {
+	p.skipSpace()
+	return p.parseAtom(depth)
+}
+
+// rawArgument returns the unparsed text of a {...} argument
+func (p *texParser) rawArgument() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) || p.src[p.pos] != '{' {
+		return ""
+	}
+	start := p.pos + 1
+	level := 0
+	for ; p.pos < len(p.src); p.pos++ {
+		switch p.src[p.pos] {
+		case '{':
+			level++
+		case '}':
+			level--
+			if level == 0 {
+				p.pos++
+				return string(p.src[start : p.pos-1])
+			}
+		}
+	}
+	return string(p.src[start:])
+}
+
+// skipSpace advances past whitespace; it always returns true so it can be
+// chained in conditions
+func (p *texParser) skipSpace() bool {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Open Graph card size, the 1.91:1 ratio social sites crop previews to
+const (
+	OGCardWidth  = 1200
+	OGCardHeight = 630
+)
+
+// Card layout, in pixels
+const (
+	ogMargin        = 80
+	ogAccentWidth   = 24
+	ogTitleSize     = 64
+	ogTitleLeading  = 80
+	ogTitleMaxLines = 4
+	ogLabelSize     = 30
+)
+
+var (
+	ogBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	ogText       = color.RGBA{0x1a, 0x1a, 0x1a, 0xff}
+	ogMuted      = color.RGBA{0x88, 0x88, 0x88, 0xff}
+)
+
+// OGCard is the content of a post's Open Graph image
+type OGCard struct {
+	Title    string
+	Category string // shown above the title in the accent color
+	Details  string // shown along the bottom, such as the date and reading time
+	Site     string // shown in the bottom right corner
+	Accent   color.Color
+}
+
+// ogFaces holds the parsed Go fonts, which are embedded in the binary. Faces
+// keep scratch buffers, so drawing holds mu.
+var ogFaces struct {
+	once               sync.Once
+	mu                 sync.Mutex
+	title, label, bold font.Face
+	err                error
+}
+
+func loadOGFaces() error {
+	ogFaces.once.Do(func() {
+		regular, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			ogFaces.err = err
+			return
+		}
+		bold, err := opentype.Parse(gobold.TTF)
+		if err != nil {
+			ogFaces.err = err
+			return
+		}
+		face := func(f *opentype.Font, size float64) font.Face {
+			if ogFaces.err != nil {
+				return nil
+			}
+			var face font.Face
+			face, ogFaces.err = opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+			return face
+		}
+		ogFaces.title = face(bold, ogTitleSize)
+		ogFaces.label = face(regular, ogLabelSize)
+		ogFaces.bold = face(bold, ogLabelSize)
+	})
+	return ogFaces.err
+}
+
+// RenderOGCard draws card as a PNG: the title in large type, wrapped and cut
+// short with an ellipsis if needed, an accent bar down the left edge, the
+// category above the title and the details and site name along the bottom.
+func RenderOGCard(w io.Writer, card OGCard) error {
+	if err := loadOGFaces(); err != nil {
+		return fmt.Errorf("loading fonts: %w", err)
+	}
+	accent := card.Accent
+	if accent == nil {
+		accent = ogMuted
+	}
+	ogFaces.mu.Lock()
+	defer ogFaces.mu.Unlock()
+
+	img := image.NewRGBA(image.Rect(0, 0, OGCardWidth, OGCardHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(ogBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, ogAccentWidth, OGCardHeight), image.NewUniform(accent), image.Point{}, draw.Src)
+
+	left := ogAccentWidth + ogMargin
+	textWidth := OGCardWidth - left - ogMargin
+
+	y := ogMargin + ogLabelSize
+	if card.Category != "" {
+		drawOGText(img, ogFaces.bold, accent, left, y, strings.ToUpper(card.Category))
+		y += ogLabelSize + 24
+	}
+
+	y += ogTitleSize
+	for _, line := range wrapOGText(ogFaces.title, card.Title, textWidth, ogTitleMaxLines) {
+		drawOGText(img, ogFaces.title, ogText, left, y, line)
+		y += ogTitleLeading
+	}
+
+	bottom := OGCardHeight - ogMargin
+	if card.Site != "" {
+		width := font.MeasureString(ogFaces.bold, card.Site).Ceil()
+		drawOGText(img, ogFaces.bold, ogText, OGCardWidth-ogMargin-width, bottom, card.Site)
+	}
+	if card.Details != "" {
+		drawOGText(img, ogFaces.label, ogMuted, left, bottom, card.Details)
+	}
+
+	return png.Encode(w, img)
+}
+
+func drawOGText(img draw.Image, face font.Face, c color.Color, x, y int, text string) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(text)
+}
+
+// wrapOGText breaks text into lines no wider than width, keeping at most
+// maxLines and ending the last with an ellipsis when text does not fit. A
+// word wider than a line is left to overflow.
+func wrapOGText(face font.Face, text string, width, maxLines int) []string {
+	fits := func(s string) bool { return font.MeasureString(face, s).Ceil() <= width }
+
+	var lines []string
+	words := strings.Fields(text)
+	for len(words) > 0 {
+		line := words[0]
+		n := 1
+		for n < len(words) && fits(line+" "+words[n]) {
+			line += " " + words[n]
+			n++
+		}
+		words = words[n:]
+
+		if len(lines) == maxLines-1 && len(words) > 0 {
+			return append(lines, ellipsize(line, fits))
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// ellipsize shortens line, by whole words where it can, until it fits with
+// an ellipsis on the end
+func ellipsize(line string, fits func(string) bool) string {
+	for !fits(line + "…") {
+		if i := strings.LastIndexByte(line, ' '); i > 0 {
+			line = line[:i]
+		} else if r := []rune(line); len(r) > 1 {
+			line = string(r[:len(r)-1])
+		} else {
+			break
+		}
+	}
+	return line + "…"
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPreviewDisabled = errors.New("preview links are disabled: no preview secret configured")
+	ErrInvalidPreview  = errors.New("invalid preview token")
+	ErrExpiredPreview  = errors.New("preview token has expired")
+)
+
+// NewPreviewToken signs a token granting access to the draft with the given
+// slug until expires. Tokens have the form "<expiry>.<signature>".
+func NewPreviewToken(secret []byte, slug string, expires time.Time) (string, error) {
+	if len(secret) == 0 {
+		return "", ErrPreviewDisabled
+	}
+	expiry := strconv.FormatInt(expires.Unix(), 36)
+	return expiry + "." + previewSignature(secret, slug, expiry), nil
+}
+
+// VerifyPreviewToken checks that token was issued for slug and has not expired
+func VerifyPreviewToken(secret []byte, slug, token string, now time.Time) error {
+	if len(secret) == 0 {
+		return ErrPreviewDisabled
+	}
+
+	expiry, signature, found := strings.Cut(token, ".")
+	if !found {
+		return ErrInvalidPreview
+	}
+	unix, err := strconv.ParseInt(expiry, 36, 64)
+	if err != nil {
+		return ErrInvalidPreview
+	}
+
+	expected := previewSignature(secret, slug, expiry)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidPreview
+	}
+	if !now.Before(time.Unix(unix, 0)) {
+		return ErrExpiredPreview
+	}
+	return nil
+}
+
+func previewSignature(secret []byte, slug, expiry string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("preview\n" + slug + "\n" + expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
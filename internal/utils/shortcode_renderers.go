@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"fmt"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	numberPattern     = regexp.MustCompile(`^[0-9]+$`)
+	youtubeIDPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{6,20}$`)
+	githubUserPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	gistIDPattern     = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+)
+
+// calloutTitles are the kinds of callout and their default titles
+var calloutTitles = map[string]string{
+	"note":    "Note",
+	"warning": "Warning",
+	"tip":     "Tip",
+}
+
+func init() {
+	RegisterShortcode("figure", ShortcodeRenderer{Template: "figure.html", Data: figureData})
+	RegisterShortcode("callout", ShortcodeRenderer{Template: "callout.html", Paired: true, Data: calloutData})
+	RegisterShortcode("youtube", ShortcodeRenderer{Template: "youtube.html", Data: youtubeData})
+	RegisterShortcode("gist", ShortcodeRenderer{Template: "gist.html", Data: gistData})
+	RegisterShortcode("include", ShortcodeRenderer{Template: "include.html", Data: includeData})
+}
+
+// figureData handles {{< figure src="..." alt="..." caption="..." >}}, with
+// optional srcset, sizes, width, height and link
+func figureData(sc Shortcode) (any, error) {
+	src := sc.Arg("src", 0)
+	if src == "" {
+		return nil, fmt.Errorf("src is required")
+	}
+	for _, name := range []string{"width", "height"} {
+		if value := sc.Arg(name, -1); value != "" && !numberPattern.MatchString(value) {
+			return nil, fmt.Errorf("%s must be a number of pixels, got %q", name, value)
+		}
+	}
+	caption, err := sc.InlineMarkdown(sc.Arg("caption", -1))
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Src, Srcset, Sizes, Alt, Link, Width, Height string
+		Caption                                      template.HTML
+	}{
+		Src:     src,
+		Srcset:  sc.Arg("srcset", -1),
+		Sizes:   sc.Arg("sizes", -1),
+		Alt:     sc.Arg("alt", -1),
+		Link:    sc.Arg("link", -1),
+		Width:   sc.Arg("width", -1),
+		Height:  sc.Arg("height", -1),
+		Caption: template.HTML(caption),
+	}, nil
+}
+
+// calloutData handles {{< callout type="warning" title="..." >}}...{{< /callout >}}.
+// The type defaults to note.
+func calloutData(sc Shortcode) (any, error) {
+	kind := sc.Arg("type", 0)
+	if kind == "" {
+		kind = "note"
+	}
+	title, ok := calloutTitles[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown callout type %q; use note, warning or tip", kind)
+	}
+	if custom := sc.Arg("title", -1); custom != "" {
+		title = custom
+	}
+	body, err := sc.Markdown(sc.Inner)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		Type, Title string
+		Body        template.HTML
+	}{Type: kind, Title: title, Body: template.HTML(body)}, nil
+}
+
+// youtubeData handles {{< youtube id="..." title="..." start="90" >}}. The
+// video is shown as a link rather than an iframe, so nothing is loaded from
+// YouTube until the reader follows it.
+func youtubeData(sc Shortcode) (any, error) {
+	id := sc.Arg("id", 0)
+	if !youtubeIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid video id %q", id)
+	}
+	start := sc.Arg("start", -1)
+	if start != "" && !numberPattern.MatchString(start) {
+		return nil, fmt.Errorf("start must be a number of seconds, got %q", start)
+	}
+	title := sc.Arg("title", 1)
+	if title == "" {
+		title = "YouTube video"
+	}
+
+	watch := url.Values{"v": {id}}
+	embed := "https://www.youtube-nocookie.com/embed/" + id
+	if start != "" {
+		watch.Set("t", start+"s")
+		embed += "?start=" + start
+	}
+
+	return struct {
+		ID, Title, WatchURL, EmbedURL string
+	}{
+		ID:       id,
+		Title:    title,
+		WatchURL: "https://www.youtube.com/watch?" + watch.Encode(),
+		EmbedURL: embed,
+	}, nil
+}
+
+// gistData handles {{< gist user="..." id="..." file="..." >}}, also written
+// {{< gist user/id >}}. Like videos, gists are linked rather than embedded.
+func gistData(sc Shortcode) (any, error) {
+	user, id := sc.Arg("user", -1), sc.Arg("id", -1)
+	if user == "" && id == "" && len(sc.Positional) > 0 {
+		user, id, _ = strings.Cut(sc.Positional[0], "/")
+	}
+	if !githubUserPattern.MatchString(user) {
+		return nil, fmt.Errorf("invalid GitHub user %q", user)
+	}
+	if !gistIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid gist id %q", id)
+	}
+
+	file := sc.Arg("file", -1)
+	link := "https://gist.github.com/" + user + "/" + id
+	if file != "" {
+		// GitHub anchors files as #file-name-ext
+		link += "#file-" + strings.ToLower(strings.NewReplacer(".", "-", " ", "-").Replace(file))
+	}
+
+	return struct {
+		User, ID, File, URL string
+	}{User: user, ID: id, File: file, URL: link}, nil
+}
+
+// includeData handles {{< include "name" >}}, rendering a snippet from the
+// blog's snippets directory in place
+func includeData(sc Shortcode) (any, error) {
+	name := sc.Arg("file", 0)
+	snippet, err := sc.Snippet(name)
+	if err != nil {
+		return nil, err
+	}
+	body, err := sc.Markdown(snippet)
+	if err != nil {
+		return nil, fmt.Errorf("snippet %q: %w", name, err)
+	}
+
+	return struct {
+		Name string
+		HTML template.HTML
+	}{Name: name, HTML: template.HTML(body)}, nil
+}
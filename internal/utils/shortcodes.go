@@ -0,0 +1,434 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxShortcodeDepth limits shortcodes nested through callouts and includes,
+// which also stops a snippet from including itself forever
+const maxShortcodeDepth = 8
+
+// Shortcode is one {{< name args >}} tag in post markdown. Arguments are
+// either key="value" pairs or positional values. Paired shortcodes wrap
+// markdown up to {{< /name >}}, which is held in Inner.
+type Shortcode struct {
+	Name       string
+	Args       map[string]string
+	Positional []string
+	Inner      string
+	Line       int
+
+	ctx *shortcodeContext
+}
+
+// ShortcodeError is a problem with the shortcode on Line of the markdown
+type ShortcodeError struct {
+	Line int
+	Err  error
+}
+
+func (e *ShortcodeError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ShortcodeError) Unwrap() error {
+	return e.Err
+}
+
+// Arg returns the named argument, falling back to the positional argument at
+// index when the name is not given. Pass a negative index for named-only
+// arguments.
+func (sc Shortcode) Arg(name string, index int) string {
+	if value, ok := sc.Args[name]; ok {
+		return value
+	}
+	if index >= 0 && index < len(sc.Positional) {
+		return sc.Positional[index]
+	}
+	return ""
+}
+
+// Markdown renders markdown inside a shortcode, such as a callout body or an
+// included snippet, expanding any shortcodes it contains
+func (sc Shortcode) Markdown(s string) (string, error) {
+	nested, err := sc.ctx.nested()
+	if err != nil {
+		return "", err
+	}
+	if nested.check {
+		return "", nested.validate(s)
+	}
+	output, _ := nested.renderMarkdown(s, false)
+	return output, nil
+}
+
+// InlineMarkdown renders a single line of markdown without the paragraph
+// around it, for captions and titles
+func (sc Shortcode) InlineMarkdown(s string) (string, error) {
+	output, err := sc.Markdown(s)
+	if err != nil {
+		return "", err
+	}
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "<p>") && strings.HasSuffix(output, "</p>") && strings.Count(output, "<p>") == 1 {
+		output = output[len("<p>") : len(output)-len("</p>")]
+	}
+	return output, nil
+}
+
+// Snippet reads a reusable markdown snippet by name from the snippet
+// directory. Names may leave off the .md extension.
+func (sc Shortcode) Snippet(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid snippet name %q", name)
+	}
+	if sc.ctx.snippetDir == "" {
+		return "", fmt.Errorf("no snippet directory to include %q from", name)
+	}
+	if filepath.Ext(name) == "" {
+		name += ".md"
+	}
+
+	data, err := os.ReadFile(filepath.Join(sc.ctx.snippetDir, name))
+	if err != nil {
+		return "", fmt.Errorf("snippet %q: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// ShortcodeRenderer renders one kind of shortcode. Data checks the arguments
+// and returns the value passed to Template, a file in the template/shortcodes
+// directory. Paired shortcodes must be closed with {{< /name >}}.
+type ShortcodeRenderer struct {
+	Template string
+	Paired   bool
+	Data     func(sc Shortcode) (any, error)
+}
+
+var shortcodes = make(map[string]ShortcodeRenderer)
+
+// RegisterShortcode adds a shortcode, replacing any with the same name
+func RegisterShortcode(name string, renderer ShortcodeRenderer) {
+	shortcodes[name] = renderer
+}
+
+var snippetDir string
+
+// SetSnippetDir sets the directory the include shortcode reads from
+func SetSnippetDir(dir string) {
+	snippetDir = dir
+}
+
+// CheckShortcodes reports the first unknown, unclosed or invalid shortcode in
+// s, including those inside callouts and included snippets. It does not need
+// the shortcode templates, so content can be checked before they are loaded.
+func CheckShortcodes(s, snippetDir string) error {
+	ctx := &shortcodeContext{snippetDir: snippetDir, check: true}
+	return ctx.validate(s)
+}
+
+// shortcodeContext is the state shared by a render and the shortcodes nested
+// inside it
+type shortcodeContext struct {
+	snippetDir string
+	depth      int
+	check      bool // validate only, without executing templates
+}
+
+func (c *shortcodeContext) nested() (*shortcodeContext, error) {
+	if c.depth >= maxShortcodeDepth {
+		return nil, fmt.Errorf("shortcodes nested more than %d deep; does a snippet include itself?", maxShortcodeDepth)
+	}
+	nested := *c
+	nested.depth++
+	return &nested, nil
+}
+
+func (c *shortcodeContext) validate(s string) error {
+	_, found, err := extractShortcodes(s)
+	if err != nil {
+		return err
+	}
+	for _, sc := range found {
+		sc.ctx = c
+		if _, err := shortcodes[sc.Name].Data(sc); err != nil {
+			return &ShortcodeError{Line: sc.Line, Err: fmt.Errorf("%s: %w", sc.Name, err)}
+		}
+	}
+	return nil
+}
+
+// render runs a shortcode's renderer and template
+func (c *shortcodeContext) render(sc Shortcode) (string, error) {
+	renderer := shortcodes[sc.Name]
+	sc.ctx = c
+	data, err := renderer.Data(sc)
+	if err != nil {
+		return "", err
+	}
+	if Templates.Shortcodes == nil {
+		return "", fmt.Errorf("shortcode templates are not loaded")
+	}
+
+	var buf bytes.Buffer
+	if err := Templates.Shortcodes.ExecuteTemplate(&buf, renderer.Template, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// replacePlaceholders swaps the placeholder comments in rendered HTML for the
+// rendered shortcodes. A shortcode that fails renders as an error message, so
+// problems show up in the /write preview.
+func (c *shortcodeContext) replacePlaceholders(output string, found []Shortcode) string {
+	for i, sc := range found {
+		rendered, err := c.render(sc)
+		if err != nil {
+			rendered = shortcodeError(&ShortcodeError{Line: sc.Line, Err: fmt.Errorf("%s: %w", sc.Name, err)})
+		}
+		output = strings.Replace(output, shortcodePlaceholder(i), rendered, 1)
+	}
+	return output
+}
+
+func shortcodePlaceholder(i int) string {
+	return fmt.Sprintf("<!--shortcode:%d-->", i)
+}
+
+func shortcodeError(err error) string {
+	return `<div class="shortcode-error">` + html.EscapeString(err.Error()) + "</div>\n"
+}
+
+// extractShortcodes replaces every shortcode in s with a placeholder comment
+// on its own line, which markdown passes through untouched, and returns the
+// shortcodes in placeholder order. Shortcodes in fenced code blocks are left
+// alone, and {{</* name */>}} writes a literal {{< name >}}.
+func extractShortcodes(s string) (string, []Shortcode, error) {
+	code := fencedCodeRanges(s)
+
+	var out strings.Builder
+	var found []Shortcode
+	pos := 0
+	for {
+		start := indexOutside(s, "{{<", pos, code)
+		if start < 0 {
+			break
+		}
+		tag, end, err := parseShortcodeTag(s, start)
+		if err != nil {
+			return "", nil, &ShortcodeError{Line: lineAt(s, start), Err: err}
+		}
+
+		out.WriteString(s[pos:start])
+		pos = end
+		if tag.literal != "" {
+			out.WriteString(tag.literal)
+			continue
+		}
+		if tag.closing {
+			return "", nil, &ShortcodeError{Line: lineAt(s, start), Err: fmt.Errorf("{{< /%s >}} without an opening tag", tag.name)}
+		}
+
+		renderer, ok := shortcodes[tag.name]
+		if !ok {
+			return "", nil, &ShortcodeError{Line: lineAt(s, start), Err: fmt.Errorf("unknown shortcode %q", tag.name)}
+		}
+		sc := Shortcode{Name: tag.name, Args: tag.args, Positional: tag.positional, Line: lineAt(s, start)}
+		if renderer.Paired {
+			innerEnd, closeEnd := findClosingShortcode(s, tag.name, end, code)
+			if innerEnd < 0 {
+				return "", nil, &ShortcodeError{Line: sc.Line, Err: fmt.Errorf("{{< %s >}} is not closed with {{< /%s >}}", tag.name, tag.name)}
+			}
+			sc.Inner = s[end:innerEnd]
+			pos = closeEnd
+		}
+
+		out.WriteString("\n\n" + shortcodePlaceholder(len(found)) + "\n\n")
+		found = append(found, sc)
+	}
+	out.WriteString(s[pos:])
+
+	return out.String(), found, nil
+}
+
+// shortcodeTag is a parsed {{< ... >}} tag
+type shortcodeTag struct {
+	name       string
+	closing    bool
+	args       map[string]string
+	positional []string
+	literal    string // the text an escaped {{</* ... */>}} tag stands for
+}
+
+// parseShortcodeTag parses the tag starting at s[start] and returns it with
+// the offset just past its closing >}}
+func parseShortcodeTag(s string, start int) (shortcodeTag, int, error) {
+	rest := s[start+len("{{<"):]
+	if strings.HasPrefix(rest, "/*") {
+		end := strings.Index(rest, "*/>}}")
+		if end < 0 {
+			return shortcodeTag{}, 0, fmt.Errorf("escaped shortcode is not closed with */>}}")
+		}
+		literal := "{{<" + rest[len("/*"):end] + ">}}"
+		return shortcodeTag{literal: literal}, start + len("{{<") + end + len("*/>}}"), nil
+	}
+
+	end := strings.Index(rest, ">}}")
+	if end < 0 {
+		return shortcodeTag{}, 0, fmt.Errorf("shortcode is not closed with >}}")
+	}
+	next := start + len("{{<") + end + len(">}}")
+
+	body := strings.Join(strings.Fields(rest[:end]), " ")
+	if name, ok := strings.CutPrefix(body, "/"); ok {
+		name = strings.TrimSpace(name)
+		if !validShortcodeName(name) {
+			return shortcodeTag{}, 0, fmt.Errorf("invalid closing shortcode %q", body)
+		}
+		return shortcodeTag{name: name, closing: true}, next, nil
+	}
+
+	fields := splitFenceInfo(body)
+	if len(fields) == 0 || !validShortcodeName(fields[0]) {
+		return shortcodeTag{}, 0, fmt.Errorf("invalid shortcode %q", body)
+	}
+
+	tag := shortcodeTag{name: fields[0], args: make(map[string]string)}
+	for _, field := range fields[1:] {
+		key, value, hasValue := strings.Cut(field, "=")
+		if hasValue && validShortcodeName(key) {
+			tag.args[key] = unquote(value)
+		} else {
+			tag.positional = append(tag.positional, unquote(field))
+		}
+	}
+	return tag, next, nil
+}
+
+func validShortcodeName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// findClosingShortcode finds the {{< /name >}} matching an opening tag that
+// ends at from, allowing the same shortcode to be nested. It returns where
+// the closing tag starts and ends, or -1 when there is none.
+func findClosingShortcode(s, name string, from int, code [][2]int) (int, int) {
+	depth := 0
+	for pos := from; ; {
+		start := indexOutside(s, "{{<", pos, code)
+		if start < 0 {
+			return -1, -1
+		}
+		tag, end, err := parseShortcodeTag(s, start)
+		if err != nil {
+			pos = start + len("{{<")
+			continue
+		}
+		pos = end
+		if tag.name != name {
+			continue
+		}
+		if !tag.closing {
+			depth++
+			continue
+		}
+		if depth == 0 {
+			return start, end
+		}
+		depth--
+	}
+}
+
+// fencedCodeRanges returns the byte ranges of the fenced code blocks in s
+func fencedCodeRanges(s string) [][2]int {
+	var ranges [][2]int
+	var fence string
+	start := 0
+
+	for offset := 0; offset < len(s); {
+		end := len(s)
+		if i := strings.IndexByte(s[offset:], '\n'); i >= 0 {
+			end = offset + i + 1
+		}
+		line := strings.TrimLeft(s[offset:end], " \t")
+		marker := fenceMarker(line)
+
+		switch {
+		case fence == "" && marker != "":
+			fence, start = marker, offset
+		case fence != "" && marker != "" && marker[0] == fence[0] && len(marker) >= len(fence) &&
+			strings.TrimSpace(line[len(marker):]) == "":
+			ranges = append(ranges, [2]int{start, end})
+			fence = ""
+		}
+		offset = end
+	}
+	if fence != "" {
+		ranges = append(ranges, [2]int{start, len(s)})
+	}
+	return ranges
+}
+
+// fenceMarker returns the run of three or more backticks or tildes a line
+// starts with, if any
+func fenceMarker(line string) string {
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// indexOutside is strings.Index from offset from, skipping matches inside
+// the given ranges
+func indexOutside(s, substr string, from int, ranges [][2]int) int {
+	for from < len(s) {
+		i := strings.Index(s[from:], substr)
+		if i < 0 {
+			return -1
+		}
+		i += from
+
+		inside := false
+		for _, r := range ranges {
+			if i >= r[0] && i < r[1] {
+				from, inside = r[1], true
+				break
+			}
+		}
+		if !inside {
+			return i
+		}
+	}
+	return -1
+}
+
+func lineAt(s string, offset int) int {
+	return strings.Count(s[:offset], "\n") + 1
+}
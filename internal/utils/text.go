@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	inlineTagPattern  = regexp.MustCompile(`</?(?:a|abbr|b|code|del|em|i|kbd|mark|s|span|strong|sub|sup)(?:\s[^>]*)?>`)
+	htmlTagPattern    = regexp.MustCompile(`<[^>]*>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+	codeBlockPattern  = regexp.MustCompile(`(?s)<figure class="code-block".*?</figure>|<pre[^>]*>.*?</pre>`)
+	lineNumberPattern = regexp.MustCompile(`<span class="ln"[^>]*>[^<]*</span>`)
+	paragraphPattern  = regexp.MustCompile(`(?s)<p>(.*?)</p>`)
+)
+
+// Reading speeds in words per minute. Code is read far more slowly than prose.
+const (
+	proseWordsPerMinute = 230
+	codeWordsPerMinute  = 80
+)
+
+// StripHTML removes tags from rendered HTML, decodes entities and collapses
+// whitespace, leaving the text a reader would see.
+func StripHTML(s string) string {
+	text := inlineTagPattern.ReplaceAllString(s, "")
+	text = htmlTagPattern.ReplaceAllString(text, " ")
+	text = html.UnescapeString(text)
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " "))
+}
+
+// MarkdownToText renders markdown the same way post pages do and returns the
+// visible text
+func MarkdownToText(s string) string {
+	return StripHTML(MarkdownToHTML(s))
+}
+
+// MarkdownToProse is MarkdownToText without fenced and indented code blocks
+func MarkdownToProse(s string) string {
+	return StripHTML(codeBlockPattern.ReplaceAllString(MarkdownToHTML(s), " "))
+}
+
+// WordCount returns the number of words a reader sees once markdown is
+// rendered, split into prose and code blocks
+func WordCount(s string) (prose, code int) {
+	rendered := MarkdownToHTML(s)
+	for _, block := range codeBlockPattern.FindAllString(rendered, -1) {
+		code += len(strings.Fields(StripHTML(lineNumberPattern.ReplaceAllString(block, ""))))
+	}
+	prose = len(strings.Fields(StripHTML(codeBlockPattern.ReplaceAllString(rendered, " "))))
+	return prose, code
+}
+
+// ReadingMinutes estimates the reading time for the given word counts,
+// rounded to the nearest minute. Any content takes at least a minute.
+func ReadingMinutes(prose, code int) int {
+	if prose+code == 0 {
+		return 0
+	}
+	minutes := float64(prose)/proseWordsPerMinute + float64(code)/codeWordsPerMinute
+	if minutes < 1 {
+		return 1
+	}
+	return int(minutes + 0.5)
+}
+
+// Excerpt returns the plain text of the first paragraph of markdown, cut to
+// at most maxWords words
+func Excerpt(s string, maxWords int) string {
+	rendered := codeBlockPattern.ReplaceAllString(MarkdownToHTML(s), " ")
+
+	var text string
+	for _, match := range paragraphPattern.FindAllStringSubmatch(rendered, -1) {
+		if text = StripHTML(match[1]); text != "" {
+			break
+		}
+	}
+	if text == "" {
+		text = StripHTML(rendered)
+	}
+
+	words := strings.Fields(text)
+	if len(words) > maxWords {
+		return strings.TrimRight(strings.Join(words[:maxWords], " "), ",;:.") + "…"
+	}
+	return text
+}
+
+// readingTimeFormats holds the reading time phrases per language: under a
+// minute, exactly one minute, and more
+var readingTimeFormats = map[string][3]string{
+	"en": {"less than a minute", "1 min read", "%d min read"},
+	"de": {"weniger als eine Minute", "1 Minute Lesezeit", "%d Minuten Lesezeit"},
+	"es": {"menos de un minuto", "1 minuto de lectura", "%d minutos de lectura"},
+	"fr": {"moins d'une minute", "1 minute de lecture", "%d minutes de lecture"},
+	"hi": {"एक मिनट से कम", "1 मिनट का पाठ", "%d मिनट का पाठ"},
+}
+
+// ReadingTimeText formats a reading time in minutes for the given language
+// tag, such as "en" or "en-us", falling back to English
+func ReadingTimeText(minutes int, lang string) string {
+	lang = strings.ToLower(lang)
+	if base, _, found := strings.Cut(lang, "-"); found {
+		lang = base
+	}
+	formats, ok := readingTimeFormats[lang]
+	if !ok {
+		formats = readingTimeFormats["en"]
+	}
+
+	switch {
+	case minutes < 1:
+		return formats[0]
+	case minutes == 1:
+		return formats[1]
+	default:
+		return fmt.Sprintf(formats[2], minutes)
+	}
+}
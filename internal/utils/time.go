@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var siteLocation = time.UTC
+
+// dateLayouts are the accepted content date formats, tried in order. Layouts
+// without an offset are interpreted in the site timezone.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"2006-01-02 15:04 -0700",
+	"2006-01-02 15:04:05 -0700",
+}
+
+// SetSiteLocation sets the timezone used to parse and display content dates
+func SetSiteLocation(loc *time.Location) {
+	siteLocation = loc
+}
+
+// SiteLocation returns the configured site timezone
+func SiteLocation() *time.Location {
+	return siteLocation
+}
+
+// ParseSiteDate parses a content date such as "2024-05-01", "2024-05-01 09:30"
+// or "2024-05-01T09:30:00+05:30". A trailing IANA zone name
+// ("2024-05-01 09:30 America/New_York") overrides the site timezone. The
+// result is always expressed in the site timezone.
+func ParseSiteDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	loc := siteLocation
+	if i := strings.LastIndexByte(value, ' '); i > 0 {
+		if zone := value[i+1:]; strings.Contains(zone, "/") || zone == "UTC" {
+			named, err := time.LoadLocation(zone)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid timezone %q in date %q", zone, value)
+			}
+			loc = named
+			value = value[:i]
+		}
+	}
+
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.In(siteLocation), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD with an optional time and timezone", value)
+}
@@ -7,13 +7,12 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
-
-	"github.com/russross/blackfriday/v2"
 )
 
 type TemplatesStruct struct {
-	Templates *template.Template
-	BasePath  string
+	Templates  *template.Template
+	Shortcodes *template.Template // one file per shortcode, named by file
+	BasePath   string
 }
 
 var Templates TemplatesStruct
@@ -78,10 +77,10 @@ func ParseTemplates(basePath string) error {
 			return strings.Split(s, sep)
 		},
 		"date": func(t time.Time, layout string) string {
-			return t.Format(layout)
+			return t.In(siteLocation).Format(layout)
 		},
 		"now": func() time.Time {
-			return time.Now()
+			return time.Now().In(siteLocation)
 		},
 		"dict": func(values ...interface{}) (map[string]interface{}, error) {
 			if len(values)%2 != 0 {
@@ -113,8 +112,7 @@ func ParseTemplates(basePath string) error {
 			return template.URL(s)
 		},
 		"markdownify": func(s string) template.HTML {
-			output := blackfriday.Run([]byte(s))
-			return template.HTML(output)
+			return template.HTML(MarkdownToHTML(s))
 		},
 		"truncate": func(text string, length int) string {
 			if len(text) <= length {
@@ -161,9 +159,15 @@ func ParseTemplates(basePath string) error {
 		return err
 	}
 
+	shortcodeTemplates := template.New("shortcodes").Funcs(funcMap)
+	if shortcodeTemplates, err = shortcodeTemplates.ParseGlob(filepath.Join(basePath, "shortcodes", "*.html")); err != nil {
+		return err
+	}
+
 	Templates = TemplatesStruct{
-		Templates: templates,
-		BasePath:  basePath,
+		Templates:  templates,
+		Shortcodes: shortcodeTemplates,
+		BasePath:   basePath,
 	}
 
 	return nil
@@ -211,8 +215,8 @@ func (t *TemplatesStruct) AddTemplateFuncs() {
 			}
 			return text[start:end]
 		},
-		"date": func(t time.Time, layout string) string { return t.Format(layout) },
-		"now":  func() time.Time { return time.Now() },
+		"date": func(t time.Time, layout string) string { return t.In(siteLocation).Format(layout) },
+		"now":  func() time.Time { return time.Now().In(siteLocation) },
 		"dict": func(values ...interface{}) (map[string]interface{}, error) {
 			if len(values)%2 != 0 {
 				return nil, fmt.Errorf("dict: odd number of arguments")
@@ -232,8 +236,7 @@ func (t *TemplatesStruct) AddTemplateFuncs() {
 		"safeJS":   func(s string) template.JS { return template.JS(s) },
 		"safeURL":  func(s string) template.URL { return template.URL(s) },
 		"markdownify": func(text string) template.HTML {
-			output := blackfriday.Run([]byte(text))
-			return template.HTML(output)
+			return template.HTML(MarkdownToHTML(text))
 		},
 	})
 }
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Heading levels included in a table of contents
+const (
+	tocMinLevel = 2
+	tocMaxLevel = 4
+)
+
+// TOCEntry is a heading in a post's table of contents
+type TOCEntry struct {
+	Level    int
+	ID       string
+	Title    string
+	Children []TOCEntry
+}
+
+// nestTOC turns a flat list of headings into a tree. A heading nests under the
+// closest preceding heading of a lower level.
+func nestTOC(headings []TOCEntry) []TOCEntry {
+	var root []TOCEntry
+	for _, heading := range headings {
+		root = insertTOC(root, heading)
+	}
+	return root
+}
+
+func insertTOC(entries []TOCEntry, heading TOCEntry) []TOCEntry {
+	if n := len(entries); n > 0 && entries[n-1].Level < heading.Level {
+		entries[n-1].Children = insertTOC(entries[n-1].Children, heading)
+		return entries
+	}
+	return append(entries, heading)
+}
+
+// nodeText returns the plain text inside a node
+func nodeText(node ast.Node) string {
+	var b strings.Builder
+	ast.WalkFunc(node, func(child ast.Node, entering bool) ast.WalkStatus {
+		switch child := child.(type) {
+		case *ast.Text:
+			b.Write(child.Literal)
+		case *ast.Code:
+			b.Write(child.Literal)
+		}
+		return ast.GoToNext
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// slugify turns heading text into an id: lowercase letters and digits joined
+// by single hyphens
+func slugify(text string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
+
+// uniqueID returns base, or base-2, base-3... when base is already taken
+func uniqueID(ids map[string]int, base string) string {
+	id := base
+	for ids[id] > 0 {
+		ids[base]++
+		id = fmt.Sprintf("%s-%d", base, ids[base])
+	}
+	ids[id]++
+	return id
+}
@@ -0,0 +1,44 @@
+package utils
+
+import "strings"
+
+// User agent classes, coarse enough that they say nothing about the reader
+const (
+	AgentBot     = "bot"
+	AgentMobile  = "mobile"
+	AgentTablet  = "tablet"
+	AgentDesktop = "desktop"
+	AgentOther   = "other"
+)
+
+var botMarkers = []string{
+	"bot", "crawl", "spider", "slurp", "fetch", "preview", "curl", "wget",
+	"python", "go-http-client", "java/", "httpclient", "headless", "lighthouse",
+	"feed", "rss", "monitor", "uptime",
+}
+
+// UserAgentClass sorts a User-Agent header into bot, mobile, tablet, desktop
+// or other
+func UserAgentClass(ua string) string {
+	ua = strings.ToLower(ua)
+	if ua == "" {
+		return AgentBot
+	}
+	for _, marker := range botMarkers {
+		if strings.Contains(ua, marker) {
+			return AgentBot
+		}
+	}
+
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return AgentTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		return AgentMobile
+	case strings.Contains(ua, "windows") || strings.Contains(ua, "macintosh") ||
+		strings.Contains(ua, "x11") || strings.Contains(ua, "cros"):
+		return AgentDesktop
+	}
+	return AgentOther
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/thinkingojha/go-htmx/cmd/cli"
 	"github.com/thinkingojha/go-htmx/cmd/server"
 	"github.com/thinkingojha/go-htmx/internal/config"
+	"github.com/thinkingojha/go-htmx/internal/handlers"
 	"github.com/thinkingojha/go-htmx/internal/logger"
 	"github.com/thinkingojha/go-htmx/internal/utils"
 )
@@ -19,7 +22,31 @@ func main() {
 
 	// Initialize logger
 	logger.Init(cfg.App.LogLevel, cfg.IsProduction())
-	logger.Infof("Starting %s v%s in %s mode", cfg.App.Name, cfg.App.Version, cfg.App.Environment)
+	if err := cfg.Validate(); err != nil {
+		logger.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Content dates are parsed and displayed in the site timezone
+	loc, err := cfg.Location()
+	if err != nil {
+		logger.Fatalf("Failed to load timezone: %v", err)
+	}
+	utils.SetSiteLocation(loc)
+	handlers.SetPreviewSecret(cfg.Security.PreviewSecret)
+	handlers.SetOGCacheDir(cfg.App.OGCacheDir)
+	handlers.SetAdminToken(cfg.Security.AdminToken)
+	utils.SetMarkdownOptions(utils.MarkdownOptions{
+		Footnotes:       cfg.Markdown.Footnotes,
+		Sidenotes:       cfg.Markdown.Sidenotes,
+		DefinitionLists: cfg.Markdown.DefinitionLists,
+		Math:            cfg.Markdown.Math,
+		Smartypants:     cfg.Markdown.Smartypants,
+		TaskLists:       cfg.Markdown.TaskLists,
+		Highlight:       cfg.Markdown.Highlight,
+		LineNumbers:     cfg.Markdown.LineNumbers,
+		HighlightStyle:  cfg.Markdown.HighlightStyle,
+	})
+	utils.SetSnippetDir(filepath.Join(cfg.App.BlogDir, "snippets"))
 
 	// Determine base directory for templates
 	var baseDir string
@@ -38,13 +65,70 @@ func main() {
 		baseDir = filepath.Dir(execDir)
 	}
 
-	// Parse templates
+	// Parse templates, which commands need too for shortcodes
 	templateDir := filepath.Join(baseDir, cfg.App.TemplateDir)
 	if err := utils.ParseTemplates(templateDir); err != nil {
 		logger.Fatalf("Failed to parse templates: %v", err)
 	}
+
+	// Run a command instead of the server when one is given
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(cfg, os.Args[1:]))
+	}
+
+	logger.Infof("Starting %s v%s in %s mode", cfg.App.Name, cfg.App.Version, cfg.App.Environment)
 	logger.Infof("Templates loaded from %s", templateDir)
 
+	// Load blog and experience content
+	store, err := handlers.NewContentStore(cfg.App.BlogDir, cfg.App.ExperienceFile)
+	if err != nil {
+		logger.Fatalf("Failed to load content: %v", err)
+	}
+	if cfg.App.WatchContent {
+		if err := store.Watch(); err != nil {
+			logger.Warnf("Content hot reload disabled: %v", err)
+		}
+	}
+	defer store.Close()
+	handlers.SetContentStore(store)
+
+	// Open the comment log
+	if cfg.Comments.Enabled {
+		comments, err := handlers.OpenCommentStore(cfg.Comments.Dir, cfg.Comments.Moderate)
+		if err != nil {
+			logger.Fatalf("Failed to open comments: %v", err)
+		}
+		handlers.SetCommentStore(comments, cfg.Comments.MaxLength)
+	}
+
+	// Count page views
+	if cfg.Analytics.Enabled {
+		analytics, err := handlers.OpenAnalytics(cfg.Analytics.Dir, cfg.Security.TrustedProxies)
+		if err != nil {
+			logger.Fatalf("Failed to open analytics: %v", err)
+		}
+		analytics.Start(time.Duration(cfg.Analytics.FlushInterval) * time.Second)
+		defer analytics.Close()
+		handlers.SetAnalytics(analytics)
+	}
+
+	// Mail new posts to newsletter subscribers
+	if cfg.Newsletter.Enabled {
+		newsletter, err := handlers.OpenNewsletter(cfg.Newsletter.Dir, &utils.SMTPMailer{
+			Host:     cfg.Newsletter.SMTP.Host,
+			Port:     cfg.Newsletter.SMTP.Port,
+			Username: cfg.Newsletter.SMTP.Username,
+			Password: cfg.Newsletter.SMTP.Password,
+			From:     cfg.Newsletter.From,
+		})
+		if err != nil {
+			logger.Fatalf("Failed to open newsletter: %v", err)
+		}
+		newsletter.Start(time.Duration(cfg.Newsletter.CheckInterval) * time.Second)
+		defer newsletter.Close()
+		handlers.SetNewsletter(newsletter)
+	}
+
 	// Create and run server
 	srv := server.NewServer(cfg)
 	if err := srv.Run(); err != nil {